@@ -1,16 +1,26 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"katydid-mp-user/api/app"
 	"katydid-mp-user/configs"
+	"katydid-mp-user/internal/pkg/msg"
 	"katydid-mp-user/pkg/log"
 	"katydid-mp-user/pkg/middleware"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+const (
+	shutdownTimeout = 10 * time.Second // 关闭时等待请求处理完的时间
+)
+
 func Run() *gin.Engine {
 	config := configs.Get()
 	debug := config.IsDebug()
@@ -96,17 +106,24 @@ func Run() *gin.Engine {
 		middleware.DefaultCorsOptions(),
 	))
 
-	//// 认证 // TODO:GG conf自定义
-	//jwtSecret := ""
-	//engine.Use(middleware.Auth(middleware.DefaultAuthConfig(jwtSecret, []string{
-	//	"/api/v1/auth",   // 认证
-	//	"/api/v1/verify", // 验证码
-	//})))
-
-	// TODO:GG 权限 conf自定义
-	//registerRoutesToCasbin(engine)
-	//// 使用Casbin鉴权中间件
-	//engine.Use(CasbinMiddleware())
+	// 认证 (路由里声明不用登录的跳过)
+	if conf := config.MiddleWareConf.AuthConf; conf.Enable {
+		authConfig := middleware.DefaultAuthConfig(config.Auth.Token.JwtSecret, conf.IgnorePaths)
+		authConfig.Skip = app.IsPublic
+		authConfig.OnAccess = app.OnAccess                 // 访问记录
+		authConfig.CheckRoleVersion = app.CheckRoleVersion // 角色变更后旧token要刷新
+		engine.Use(middleware.Auth(authConfig))
+	}
+
+	// 鉴权 (要在认证之后，路由资源在路由注册完之后注册)
+	if conf := config.MiddleWareConf.PermissionConf; conf.Enable {
+		if !config.MiddleWareConf.AuthConf.Enable {
+			log.Fatal("■ ■ Api ■ ■ 开启鉴权必须先开启认证 (不然拿不到账号，全部401)")
+		}
+		permConfig := middleware.DefaultPermissionConfig(conf.IgnorePaths)
+		permConfig.DeniedMsg = msg.ErrIdPermDenied
+		engine.Use(middleware.Permission(permConfig))
+	}
 	// 设置示例路由
 	engine.GET("/data1", func(c *gin.Context) {
 		//form, _ := c.MultipartForm()
@@ -155,12 +172,33 @@ func Run() *gin.Engine {
 	// api路由
 	router := engine.Group("api/v1")
 	app.RouterRegister(router)
+	middleware.RegisterPermissionRoutes(engine.Routes(), router.BasePath())
 
 	host := "" // TODO:GG api.katydid.com
 	port := config.Server.ApiHttpsPort
-	err := engine.Run(fmt.Sprintf("%s:%s", host, port))
-	if err != nil {
-		log.Fatal("gin run panic", log.FError(err))
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", host, port),
+		Handler: engine,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		err := server.ListenAndServe()
+		if (err != nil) && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("gin run panic", log.FError(err))
+		}
+	}()
+
+	// 优雅关闭 (等请求处理完，再停后台任务，访问记录要写完)
+	<-ctx.Done()
+	stop()
+	log.Info("■ ■ Api ■ ■ 关闭中...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("■ ■ Api ■ ■ 关闭超时", log.FError(err))
 	}
+	app.Shutdown()
+	log.Info("■ ■ Api ■ ■ 已关闭")
 	return engine
 }
@@ -1,12 +1,48 @@
 package app
 
 import (
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"katydid-mp-user/configs"
 	accountHandler "katydid-mp-user/internal/api/auth/handler"
+	authModel "katydid-mp-user/internal/api/auth/model"
+	accountStorage "katydid-mp-user/internal/api/auth/repo/storage"
+	accountService "katydid-mp-user/internal/api/auth/service"
 	clientHandler "katydid-mp-user/internal/api/client/handler"
-	permHandler "katydid-mp-user/internal/api/role/handler"
+	roleHandler "katydid-mp-user/internal/api/role/handler"
+	roleStorage "katydid-mp-user/internal/api/role/repo/storage"
+	roleService "katydid-mp-user/internal/api/role/service"
+	userHandler "katydid-mp-user/internal/api/user/handler"
+	userStorage "katydid-mp-user/internal/api/user/repo/storage"
+	userService "katydid-mp-user/internal/api/user/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/auth"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/oss"
+	"katydid-mp-user/pkg/perm"
+	"katydid-mp-user/pkg/words"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	accessHandler *accountHandler.Access // 访问记录 (认证中间件要用)
+	tokenService  *accountService.Token  // token服务 (认证中间件要用)
+
+	recorder *accountService.AccessRecorder // 访问记录写入 (关闭时要写完)
+	purger   *accountService.AccountPurger  // 注销账号清除 (关闭时要停)
+
+	publicRoutes = make(map[string]bool) // 不用登录的路由 [method path] (认证中间件要用，注册完之后只读)
 )
 
 type FormAccount struct {
@@ -25,6 +61,7 @@ func RouterRegister(r *gin.RouterGroup) {
 	//swagger.SwaggerInfo.Schemes = []string{"http", "https"}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	skip(r, http.MethodGet, "/swagger/*any")
 	//ginSwagger.WrapHandler()
 
 	// 注册验证器
@@ -38,16 +75,96 @@ func RouterRegister(r *gin.RouterGroup) {
 	// TODO:GG 可以做多级缓存，http-cache(自带的session存储)，redis-cache，mysql-cache
 	// TODO:GG 关键操作还得让+verify(email/phone)
 
+	// access
+	conf := configs.Get().Auth.Access
+	dbsAccess := accountStorage.NewAccess()
+	recorder = accountService.NewAccessRecorder(dbsAccess,
+		conf.BatchSize, conf.QueueSize, time.Duration(conf.FlushInterval)*time.Second,
+	)
+	recorder.Start()
+	accessHandler = accountHandler.NewAccess(accountService.NewAccess(dbsAccess, accountStorage.NewAccount(), recorder))
+	riskService := accountService.NewRisk(dbsAccess)
+	riskService.OnNotify = newNotice(configs.Get().Auth.Notice).OnLoginRisk // 异地/新设备登录 通知用户
+
 	// auth
+	var accountSvc *accountService.Account // user模块要关联账号
 	{
-		AH := accountHandler.NewAccount(nil, nil, nil, nil)
+		nicknameConf := configs.Get().Auth.Nickname
+		matcher, err := words.LoadMatcher(nicknameConf.WordsFile, nicknameConf.Words...)
+		if err != nil {
+			log.Warn("■ ■ Router ■ ■ 敏感词文件读取失败", log.FString("file", nicknameConf.WordsFile), log.FError(err))
+			matcher = words.NewMatcher(nicknameConf.Words)
+		}
+		nicknameService := accountService.NewNickname(accountStorage.NewNickname(), matcher)
+
+		svc := accountService.NewAccount(
+			accountStorage.NewAccount(), accountStorage.NewAuth(), accountStorage.NewToken(),
+			recorder, riskService, nicknameService,
+		)
+		accountSvc = svc
+		purgeConf := configs.Get().Auth.Purge
+		purger = accountService.NewAccountPurger(svc,
+			purgeConf.BatchSize, time.Duration(purgeConf.Interval)*time.Second,
+		)
+		purger.Start()
+
+		verifySvc := accountService.NewVerify(accountStorage.NewVerify())
+		AH := accountHandler.NewAccount(svc, verifySvc)
 		account := r.Group("auth")
 		account.POST("", AH.Handler(AH.Post))
+		skip(account, http.MethodPost, "") // 注册
 		//auth.PUT(":id/*action", AH.Handler(AH.Put))
 		account.DELETE(":id", AH.Handler(AH.Del))
 		account.PUT(":id", AH.Handler(AH.Put))
+		account.PUT(":id/unblock", AH.Handler(AH.Unblock))
+		account.PUT(":id/restore", AH.Handler(AH.Restore))
+		skip(account, http.MethodPut, ":id/restore") // 注销后不能登录，用验证码恢复
+		account.PUT(":id/nickname", AH.Handler(AH.PutNickname))
+		account.PUT(":id/nickname/reset", AH.Handler(AH.ResetNickname))
+		declare(account, http.MethodPut, ":id/unblock", "admin/auth/account", perm.PolicyActMod) // 服务里还要own/all的数据范围
+		declare(account, http.MethodPut, ":id/nickname/reset", "admin/auth/account", perm.PolicyActMod)
 		account.GET("", AH.Handler(AH.Get))
 		account.GET(":id", AH.Handler(AH.Get))
+		account.GET(":id/access", accessHandler.Handler(accessHandler.Get))
+		account.POST("access/entry", accessHandler.Handler(handler.Bind(accessHandler.PostEntry)))
+
+		tokenConf := configs.Get().Auth.Token
+		tokenService = accountService.NewToken(
+			accountStorage.NewToken(), accountStorage.NewAccount(), accountStorage.NewAuth(), svc, verifySvc,
+			tokenConf.Issuer, tokenConf.JwtSecret, tokenConf.AccessExpires, tokenConf.RefreshExpires,
+		)
+		TH := accountHandler.NewToken(tokenService)
+		account.POST("token", TH.Handler(handler.Bind(TH.Login)))
+		skip(account, http.MethodPost, "token") // 登录
+		account.POST("token/exchange", TH.Handler(handler.Bind(TH.Exchange)))
+		skip(account, http.MethodPost, "token/exchange")
+		account.POST("token/refresh", TH.Handler(handler.Bind(TH.Refresh)))
+		skip(account, http.MethodPost, "token/refresh") // 用刷新token
+		account.DELETE("token", TH.Handler(TH.Del))
+
+		exportConf := configs.Get().Auth.Export
+		EH := accountHandler.NewExport(accountService.NewExport(
+			accountStorage.NewExport(), accountStorage.NewAccount(), accountStorage.NewAuth(),
+			accountStorage.NewToken(), dbsAccess, accountStorage.NewVerify(),
+			exportConf.Dir, exportConf.SecretKey,
+			time.Duration(exportConf.Expires)*time.Second, exportConf.Concurrency,
+		))
+		account.POST(":id/export", EH.Handler(EH.Post))
+		account.GET(":id/export/:exportId", EH.Handler(EH.Get))
+		account.GET("export/download", EH.Handler(EH.Download))
+		skip(account, http.MethodGet, "export/download") // 签名链接
+
+		avatarConf := configs.Get().Auth.Avatar
+		avatarStore, avatarLocal := newAvatarStore(avatarConf)
+		AVH := accountHandler.NewAvatar(accountService.NewAvatar(
+			accountStorage.NewAccount(), avatarStore,
+			avatarConf.MaxSize, avatarConf.Sizes, avatarConf.Quality,
+			time.Duration(avatarConf.Expires)*time.Second,
+		), avatarLocal)
+		account.PUT(":id/avatar", AVH.Handler(AVH.Put))
+		account.GET(":id/avatar", AVH.Handler(AVH.Get))
+		account.GET("avatar/file", AVH.Handler(AVH.File))
+		skip(account, http.MethodGet, "avatar/file") // 签名链接
 	}
 
 	// verify
@@ -55,7 +172,9 @@ func RouterRegister(r *gin.RouterGroup) {
 		VH := accountHandler.NewVerify()
 		verify := r.Group("verify")
 		verify.POST("", VH.Handler(VH.Post))
+		skip(verify, http.MethodPost, "")
 		verify.PUT("", VH.Handler(VH.Put))
+		skip(verify, http.MethodPut, "")
 	}
 
 	//// 登录接口 - 不需要认证
@@ -89,37 +208,156 @@ func RouterRegister(r *gin.RouterGroup) {
 	{
 	}
 
-	// auth.permission
-	r.Group("auth/permission")
+	// organization
 	{
-	}
+		dbsOrg, dbsMember, dbsInvite := roleStorage.NewOrganization(), roleStorage.NewMember(), roleStorage.NewInvite()
+		memberSvc := roleService.NewMember(dbsMember, dbsOrg)
+		inviteSvc := roleService.NewInvite(dbsInvite, accountStorage.NewAccount(), memberSvc,
+			time.Duration(configs.Get().Role.Invite.Expires)*time.Second,
+		)
+		// TODO:GG inviteSvc.OnSend 发送邀请 (email/sms)
+		orgSvc := roleService.NewOrganization(dbsOrg, dbsMember, dbsInvite, memberSvc, inviteSvc)
+		accountSvc.OnCheckRegister = orgSvc.CheckRegister
+		accountSvc.OnRegistered = orgSvc.OnRegistered
 
-	// orga
-	oh := permHandler.NewOrganization(nil)
-	r.Use(func(context *gin.Context) {
+		watcher := newPermissionWatcher(configs.Get().Role)
+		enforcer, err := perm.Init(roleStorage.NewPolicy(), watcher)
+		if err != nil {
+			log.Error("■ ■ Router ■ ■ 权限初始化失败，不开启策略管理", log.FError(err))
+		} else {
+			permissionSvc := roleService.NewPermission(enforcer, memberSvc)
+			orgSvc.OnDeleted = permissionSvc.OnOrgDeleted
+			memberSvc.OnRemoved = permissionSvc.OnMemberRemoved
+			permissionSvc.OnRolesChanged = func(accountIDs []uint64) { tokenService.BumpRoleVersion(accountIDs...) }
+			if redisWatcher, ok := watcher.(*perm.RedisWatcher); ok {
+				watchRoleVersion(redisWatcher)
+				watchLimits(redisWatcher)
+			}
+			tokenService.OnRoles = newTokenRoles(enforcer, configs.Get().Auth.Token.Scopes)
 
-	})
-	{
-		team := r.Group("organization")
-		team.POST("", oh.Handler(oh.PostTeam))
-		//team.POST("", ctx.Post)
+			PH := roleHandler.NewPermission(permissionSvc)
+			permission := r.Group("auth/permission")
+			perm.DeclareSkipGroup(permission.BasePath()) // 服务里按域鉴权
+			permission.GET("resources", PH.Handler(PH.GetResources))
+			permission.GET(":ownKind/:ownId/policies", PH.Handler(PH.GetPolicies))
+			permission.POST(":ownKind/:ownId/policies", PH.Handler(PH.PostPolicy))
+			permission.DELETE(":ownKind/:ownId/policies", PH.Handler(PH.DelPolicy))
+			permission.GET(":ownKind/:ownId/groupings", PH.Handler(PH.GetGroupings))
+			permission.POST(":ownKind/:ownId/groupings", PH.Handler(PH.PostGrouping))
+			permission.DELETE(":ownKind/:ownId/groupings", PH.Handler(PH.DelGrouping))
+			permission.GET(":ownKind/:ownId/roles/:accountId", PH.Handler(PH.GetRoles))
+		}
+
+		OH := roleHandler.NewOrganization(orgSvc)
+		MH := roleHandler.NewMember(memberSvc)
+		IH := roleHandler.NewInvite(inviteSvc)
+		org := r.Group("organization")
+		perm.DeclareSkipGroup(org.BasePath()) // 服务里按组织角色鉴权
+		org.POST("", OH.Handler(OH.Post))
+		org.GET("mine", MH.Handler(MH.GetMine))
+		org.POST("invite/accept", IH.Handler(IH.PostAccept))
+		org.GET(":id", OH.Handler(OH.Get))
+		org.PUT(":id", OH.Handler(OH.Put))
+		org.DELETE(":id", OH.Handler(OH.Del))
+		org.PUT(":id/owner", OH.Handler(OH.PutOwner))
+		org.GET(":id/members", MH.Handler(MH.Get))
+		org.PUT(":id/members/:accountId/role", MH.Handler(MH.PutRole))
+		org.DELETE(":id/members/:accountId", MH.Handler(MH.Del))
+		org.POST(":id/invites", IH.Handler(IH.Post))
+		org.GET(":id/invites", IH.Handler(IH.Get))
+		org.DELETE(":id/invites/:inviteId", IH.Handler(IH.Del))
 	}
 
 	// client
 	client := r.Group("client")
 	{
 		client.POST("", clientHandler.PostClient)
+
+		LH := clientHandler.NewLimits(service.GetLimitsRegistry())
+		client.GET("limits/:ownKind/:ownId", LH.Handler(LH.Get))
+		client.PUT("limits/:ownKind/:ownId", LH.Handler(LH.Put))
+		client.GET("limits/:ownKind/:ownId/effective", LH.Handler(LH.GetEffective))
+		client.PUT("limits/:ownKind/:ownId/parent", LH.Handler(LH.PutParent))
+		declare(client, http.MethodGet, "limits/:ownKind/:ownId", "client/limits", perm.PolicyActGet)
+		declare(client, http.MethodPut, "limits/:ownKind/:ownId", "client/limits", perm.PolicyActMod)
+		declare(client, http.MethodGet, "limits/:ownKind/:ownId/effective", "client/limits", perm.PolicyActGet)
+		declare(client, http.MethodPut, "limits/:ownKind/:ownId/parent", "client/limits", perm.PolicyActMod)
 	}
 
 	// user
-	r.Group("user")
 	{
+		userSvc := userService.NewUser(userStorage.NewUser(), accountSvc)
+		accountSvc.OnCheckUser = userSvc.CheckInfo
+
+		UH := userHandler.NewUser(userSvc)
+		user := r.Group("user")
+		user.POST("", UH.Handler(UH.Post))
+		user.GET(":id", UH.Handler(UH.Get))
+		user.PUT(":id", UH.Handler(UH.Put))
+		user.DELETE(":id", UH.Handler(UH.Del))
+		user.GET(":id/accounts", UH.Handler(UH.GetAccounts))
+		user.POST(":id/accounts/:accountId", UH.Handler(UH.PostAccount))
+		user.DELETE(":id/accounts/:accountId", UH.Handler(UH.DelAccount))
+
+		identityConf := configs.Get().User.Identity
+		if provider := newIdentityProvider(identityConf); provider != nil {
+			identitySvc, err := userService.NewIdentity(
+				userStorage.NewIdentity(), userStorage.NewUser(), userSvc, provider,
+				identityConf.SecretKey, time.Duration(identityConf.Timeout)*time.Second,
+			)
+			if err != nil {
+				log.Warn("■ ■ Router ■ ■ 实名认证没有配置加密密钥，不开启", log.FError(err))
+			} else {
+				IH := userHandler.NewIdentity(identitySvc)
+				user.POST(":id/identity", IH.Handler(IH.Post))
+				user.GET(":id/identity", IH.Handler(IH.Get))
+				user.PUT(":id/identity/retry", IH.Handler(IH.PutRetry))
+				if configs.Get().MiddleWareConf.PermissionConf.Enable {
+					user.PUT(":id/identity/review", IH.Handler(IH.PutReview))
+					declare(user, http.MethodPut, ":id/identity/review", userService.AdminIdentity, perm.PolicyActMod)
+				} else {
+					log.Warn("■ ■ Router ■ ■ 没有开启鉴权，不开启实名认证人工审核")
+				}
+			}
+		}
 	}
 
 	// stats TODO:GG 要放这里吗?
 
 }
 
+// Shutdown 停止后台任务 (请求都处理完之后调用)
+func Shutdown() {
+	if purger != nil {
+		purger.Stop()
+	}
+	if recorder != nil {
+		recorder.Stop() // 队列里剩余的访问记录写完
+	}
+}
+
+// IsPublic 不用登录的请求 (给认证中间件用)，没注册的(404)也跳过，交给路由
+func IsPublic(c *gin.Context) bool {
+	fullPath := c.FullPath()
+	return (len(fullPath) <= 0) || publicRoutes[c.Request.Method+" "+fullPath]
+}
+
+// OnAccess 认证通过后的访问记录 (给认证中间件用)
+func OnAccess(c *gin.Context, claims *auth.TokenClaims) {
+	if accessHandler == nil {
+		return
+	}
+	accessHandler.OnAccess(c, claims)
+}
+
+// CheckRoleVersion token的角色版本是不是最新的 (给认证中间件用)
+func CheckRoleVersion(claims *auth.TokenClaims) bool {
+	if tokenService == nil {
+		return true
+	}
+	return tokenService.CheckRoleVersion(claims)
+}
+
 //func RegisterClient(r *gin.RouterGroup) {
 //	// team
 //	r = r.Group("team")
@@ -137,3 +375,165 @@ func RouterRegister(r *gin.RouterGroup) {
 //		//r.GET(":id", handler.GetClient)
 //	}
 //}
+
+// newTokenRoles 签发token时，从策略里解析账号在自己域里的角色 (含继承的)，scopes开启时带上权限 "资源:动作"
+func newTokenRoles(enforcer *casbin.SyncedEnforcer, scopes bool) func(*authModel.Account) ([]string, []string) {
+	return func(account *authModel.Account) ([]string, []string) {
+		sub, dom := perm.SubAccount(account.ID), perm.Domain(int(account.OwnKind), account.OwnID)
+		subs, err := enforcer.GetImplicitRolesForUser(sub, dom)
+		if err != nil {
+			log.Warn("■ ■ Router ■ ■ 解析账号角色失败", log.FUint64("accountId", account.ID), log.FError(err))
+			return nil, nil
+		}
+		roles := make([]string, 0, len(subs))
+		for _, role := range subs {
+			roles = append(roles, perm.RoleName(role))
+		}
+		if !scopes {
+			return roles, nil
+		}
+		permissions, err := enforcer.GetImplicitPermissionsForUser(sub, dom)
+		if err != nil {
+			log.Warn("■ ■ Router ■ ■ 解析账号权限失败", log.FUint64("accountId", account.ID), log.FError(err))
+			return roles, nil
+		}
+		list := make([]string, 0, len(permissions))
+		for _, rule := range permissions {
+			if scope := rule[2] + ":" + rule[3]; !slices.Contains(list, scope) {
+				list = append(list, scope)
+			}
+		}
+		return roles, list
+	}
+}
+
+// newAvatarStore 头像存储，s3配置错误时退回本地
+func newAvatarStore(conf configs.AvatarConf) (oss.Store, *oss.Local) {
+	if conf.Store == "s3" {
+		store, err := oss.NewS3(oss.S3Options{
+			Endpoint:  conf.S3.Endpoint,
+			Region:    conf.S3.Region,
+			Bucket:    conf.S3.Bucket,
+			AccessKey: conf.S3.AccessKey,
+			SecretKey: conf.S3.SecretKey,
+			PathStyle: conf.S3.PathStyle,
+		})
+		if err == nil {
+			return store, nil
+		}
+		log.Error("■ ■ Router ■ ■ 头像s3配置错误，使用本地存储", log.FError(err))
+	}
+	if len(conf.Local.SecretKey) <= 0 {
+		log.Warn("■ ■ Router ■ ■ 头像没有配置签名密钥，重启后链接失效")
+	}
+	local := oss.NewLocal(conf.Local.Dir, conf.Local.BaseURL, conf.Local.SecretKey)
+	return local, local
+}
+
+// declare 声明路由需要的权限 (鉴权中间件用，多个路由可以共用一个资源)
+func declare(group *gin.RouterGroup, method, relativePath, obj, act string) {
+	perm.Declare(method, path.Join(group.BasePath(), relativePath), obj, act)
+}
+
+// skip 声明路由不鉴权 (不用登录的)，认证中间件也跳过
+func skip(group *gin.RouterGroup, method, relativePath string) {
+	fullPath := path.Join(group.BasePath(), relativePath)
+	perm.DeclareSkip(method, fullPath)
+	publicRoutes[method+" "+fullPath] = true
+}
+
+// newNotice 账号通知 (没配置网关的只打日志)
+func newNotice(conf configs.NoticeConf) *accountService.Notice {
+	timeout := time.Duration(conf.Timeout) * time.Second
+	var sender accountService.INoticeSender
+	if len(conf.Webhook) > 0 {
+		sender = accountService.NewNoticeWebhook(conf.Webhook, timeout)
+	}
+	return accountService.NewNotice(accountStorage.NewAuth(), sender, timeout)
+}
+
+// newPermissionWatcher 策略变更通知 (没配置redis或连不上时返回nil，策略只在本节点生效)
+func newPermissionWatcher(conf configs.RoleConf) persist.Watcher {
+	if (conf.Redis == nil) || (len(conf.Redis.Host) <= 0 && len(conf.Redis.Clusters) <= 0) {
+		log.Warn("■ ■ Router ■ ■ 权限没有配置redis，策略变更不会同步到其他节点")
+		return nil
+	}
+	addrs := conf.Redis.Clusters
+	if len(addrs) <= 0 {
+		addrs = []string{net.JoinHostPort(conf.Redis.Host, strconv.Itoa(conf.Redis.Port))}
+	}
+	db, _ := strconv.Atoi(conf.Redis.DBName)
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:        addrs,
+		DB:           db,
+		Password:     conf.Redis.Pwd,
+		MaxRetries:   conf.Redis.MaxRetries,
+		PoolSize:     conf.Redis.PoolSize,
+		MinIdleConns: conf.Redis.MinIdle,
+	})
+	watcher, err := perm.NewRedisWatcher(client, conf.Permission.Channel)
+	if err != nil {
+		log.Error("■ ■ Router ■ ■ 权限redis订阅失败，策略变更不会同步到其他节点", log.FError(err))
+		_ = client.Close()
+		return nil
+	}
+	return watcher
+}
+
+// watchRoleVersion 账号角色版本变更通知其他节点清缓存 (旧token马上要刷新)，消息是逗号分隔的账号ID
+func watchRoleVersion(watcher *perm.RedisWatcher) {
+	const topic = "rolever"
+	tokenService.OnRoleVerChanged = func(accountIDs []uint64) {
+		ids := make([]string, 0, len(accountIDs))
+		for _, accountID := range accountIDs {
+			ids = append(ids, strconv.FormatUint(accountID, 10))
+		}
+		if err := watcher.Publish(topic, strings.Join(ids, ",")); err != nil {
+			log.Warn("■ ■ Router ■ ■ 角色版本通知失败，其他节点等缓存过期", log.FError(err))
+		}
+	}
+	err := watcher.Subscribe(topic, func(payload string) {
+		for _, id := range strings.Split(payload, ",") {
+			if accountID, e := strconv.ParseUint(id, 10, 64); e == nil {
+				tokenService.ForgetRoleVersion(accountID)
+			}
+		}
+	})
+	if err != nil {
+		log.Error("■ ■ Router ■ ■ 角色版本订阅失败，其他节点的变更等缓存过期", log.FError(err))
+	}
+}
+
+// watchLimits 限制覆盖项变更通知其他节点清缓存 (下级都继承了，全部清掉)
+func watchLimits(watcher *perm.RedisWatcher) {
+	const topic = "limits"
+	registry := service.GetLimitsRegistry()
+	registry.OnChanged = func() {
+		if err := watcher.Publish(topic, ""); err != nil {
+			log.Warn("■ ■ Router ■ ■ 限制变更通知失败，其他节点等缓存过期", log.FError(err))
+		}
+	}
+	err := watcher.Subscribe(topic, func(string) {
+		registry.InvalidateAll()
+	})
+	if err != nil {
+		log.Error("■ ■ Router ■ ■ 限制变更订阅失败，其他节点的变更等缓存过期", log.FError(err))
+	}
+}
+
+// newIdentityProvider 实名认证供应商 (目前只有mock，接真实供应商时在这里加)
+// 没配置/不支持的返回nil，不开启实名认证，mock只能在开发环境用 (全部通过)
+func newIdentityProvider(conf configs.IdentityConf) userService.IIdentityProvider {
+	switch conf.Provider {
+	case "":
+		log.Warn("■ ■ Router ■ ■ 实名认证没有配置供应商，不开启")
+	case "mock":
+		if configs.Get().IsDebug() {
+			return userService.NewMockIdentityProvider(conf.Rejects)
+		}
+		log.Error("■ ■ Router ■ ■ 实名认证mock供应商只能在开发环境用，不开启", log.FString("env", configs.Get().Env))
+	default:
+		log.Error("■ ■ Router ■ ■ 实名认证供应商不支持，不开启", log.FString("provider", conf.Provider))
+	}
+	return nil
+}
@@ -17,6 +17,9 @@ type (
 		Auth   AuthConf   `toml:"auth" mapstructure:"auth"`
 		Client ClientConf `toml:"client" mapstructure:"client"`
 		User   UserConf   `toml:"user" mapstructure:"user"`
+		Role   RoleConf   `toml:"role" mapstructure:"role"`
+
+		Limits map[string]any `toml:"limits" mapstructure:"limits"` // 限制默认值 (service.Limits)
 	}
 
 	RemoteConf struct {
@@ -68,6 +71,14 @@ type (
 		XSSConf struct {
 			Enable bool `toml:"enable" mapstructure:"enable"`
 		} `toml:"xss" mapstructure:"xss"`
+		AuthConf struct {
+			Enable      bool     `toml:"enable" mapstructure:"enable"`
+			IgnorePaths []string `toml:"ignore_paths" mapstructure:"ignore_paths"`
+		} `toml:"auth" mapstructure:"auth"`
+		PermissionConf struct {
+			Enable      bool     `toml:"enable" mapstructure:"enable"`
+			IgnorePaths []string `toml:"ignore_paths" mapstructure:"ignore_paths"`
+		} `toml:"permission" mapstructure:"permission"`
 	}
 
 	AppConf struct {
@@ -85,6 +96,76 @@ type (
 
 	AuthConf struct {
 		ModuleConf `mapstructure:",squash"`
+
+		Access   AccessConf   `toml:"access" mapstructure:"access"`
+		Purge    PurgeConf    `toml:"purge" mapstructure:"purge"`
+		Export   ExportConf   `toml:"export" mapstructure:"export"`
+		Token    TokenConf    `toml:"token" mapstructure:"token"`
+		Nickname NicknameConf `toml:"nickname" mapstructure:"nickname"`
+		Avatar   AvatarConf   `toml:"avatar" mapstructure:"avatar"`
+		Notice   NoticeConf   `toml:"notice" mapstructure:"notice"`
+	}
+
+	NoticeConf struct {
+		Webhook string `toml:"webhook" mapstructure:"webhook"` // 通知网关地址 (POST json，为空只打日志)
+		Timeout int    `toml:"timeout" mapstructure:"timeout"` // 发送超时(s)
+	}
+
+	AvatarConf struct {
+		MaxSize int64  `toml:"max_size" mapstructure:"max_size"` // 上传大小上限(byte)
+		Sizes   []int  `toml:"sizes" mapstructure:"sizes"`       // 标准尺寸(px)
+		Quality int    `toml:"quality" mapstructure:"quality"`   // jpeg质量
+		Expires int    `toml:"expires" mapstructure:"expires"`   // 访问链接有效期(s)
+		Store   string `toml:"store" mapstructure:"store"`       // local/s3
+
+		Local AvatarLocalConf `toml:"local" mapstructure:"local"`
+		S3    AvatarS3Conf    `toml:"s3" mapstructure:"s3"`
+	}
+
+	AvatarLocalConf struct {
+		Dir       string `toml:"dir" mapstructure:"dir"`               // 存储目录
+		BaseURL   string `toml:"base_url" mapstructure:"base_url"`     // 访问接口地址
+		SecretKey string `toml:"secret_key" mapstructure:"secret_key"` // 链接签名密钥
+	}
+
+	AvatarS3Conf struct {
+		Endpoint  string `toml:"endpoint" mapstructure:"endpoint"`
+		Region    string `toml:"region" mapstructure:"region"`
+		Bucket    string `toml:"bucket" mapstructure:"bucket"`
+		AccessKey string `toml:"access_key" mapstructure:"access_key"`
+		SecretKey string `toml:"secret_key" mapstructure:"secret_key"`
+		PathStyle bool   `toml:"path_style" mapstructure:"path_style"` // MinIO等用路径风格
+	}
+
+	NicknameConf struct {
+		WordsFile string   `toml:"words_file" mapstructure:"words_file"` // 敏感词文件 (一行一个)
+		Words     []string `toml:"words" mapstructure:"words"`           // 额外的敏感词
+	}
+
+	TokenConf struct {
+		Issuer         string `toml:"issuer" mapstructure:"issuer"`                   // 签发者
+		JwtSecret      string `toml:"jwt_secret" mapstructure:"jwt_secret"`           // JWT密钥
+		AccessExpires  int64  `toml:"access_expires" mapstructure:"access_expires"`   // 访问token有效期(s) (limit没配置时)
+		RefreshExpires int64  `toml:"refresh_expires" mapstructure:"refresh_expires"` // 刷新token有效期(h) (limit没配置时)
+		Scopes         bool   `toml:"scopes" mapstructure:"scopes"`                   // token里带上粗粒度权限 (资源:动作)
+	}
+
+	ExportConf struct {
+		Dir         string `toml:"dir" mapstructure:"dir"`                 // 导出文件目录
+		SecretKey   string `toml:"secret_key" mapstructure:"secret_key"`   // 下载链接签名密钥
+		Expires     int    `toml:"expires" mapstructure:"expires"`         // 下载链接有效期(s)
+		Concurrency int    `toml:"concurrency" mapstructure:"concurrency"` // 同时打包的数量
+	}
+
+	PurgeConf struct {
+		BatchSize int `toml:"batch_size" mapstructure:"batch_size"` // 单次清除数量
+		Interval  int `toml:"interval" mapstructure:"interval"`     // 清除间隔(s)
+	}
+
+	AccessConf struct {
+		BatchSize     int `toml:"batch_size" mapstructure:"batch_size"`         // 批量写入数量
+		QueueSize     int `toml:"queue_size" mapstructure:"queue_size"`         // 队列长度(满了丢弃)
+		FlushInterval int `toml:"flush_interval" mapstructure:"flush_interval"` // 写入间隔(s)
 	}
 
 	ClientConf struct {
@@ -93,6 +174,30 @@ type (
 
 	UserConf struct {
 		ModuleConf `mapstructure:",squash"`
+
+		Identity IdentityConf `toml:"identity" mapstructure:"identity"`
+	}
+
+	IdentityConf struct {
+		Provider  string            `toml:"provider" mapstructure:"provider"`     // 供应商 (mock只能开发环境用，不配置不开启)
+		SecretKey string            `toml:"secret_key" mapstructure:"secret_key"` // 证件信息加密密钥 (不能改，改了旧数据解不开)
+		Timeout   int               `toml:"timeout" mapstructure:"timeout"`       // 供应商超时(s)
+		Rejects   map[string]string `toml:"rejects" mapstructure:"rejects"`       // mock拒绝的证件号 [证件号]原因
+	}
+
+	RoleConf struct {
+		ModuleConf `mapstructure:",squash"`
+
+		Invite     InviteConf     `toml:"invite" mapstructure:"invite"`
+		Permission PermissionConf `toml:"permission" mapstructure:"permission"`
+	}
+
+	InviteConf struct {
+		Expires int `toml:"expires" mapstructure:"expires"` // 组织邀请有效期(s)
+	}
+
+	PermissionConf struct {
+		Channel string `toml:"channel" mapstructure:"channel"` // 策略变更通知频道 (redis，没配置redis时只在本节点生效)
 	}
 
 	ModuleConf struct {
@@ -173,4 +278,5 @@ func (m *Config) merge() {
 	m.Auth.ModuleConf = m.AppConf.ModuleConf
 	m.Client.ModuleConf = m.AppConf.ModuleConf
 	m.User.ModuleConf = m.AppConf.ModuleConf
+	m.Role.ModuleConf = m.AppConf.ModuleConf
 }
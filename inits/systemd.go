@@ -6,6 +6,8 @@ import (
 	"gorm.io/gorm/logger"
 	"katydid-mp-user/configs"
 	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	internalStorage "katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
 	"katydid-mp-user/pkg/i18n"
 	"katydid-mp-user/pkg/log"
@@ -154,6 +156,14 @@ func System() {
 		}
 	}
 
+	// limits (toml默认值 + db覆盖项)
+	service.InitLimits(internalStorage.NewLimits(), config.Limits)
+	configs.Subscribe("limits", func(v any) {
+		if defs, ok := v.(map[string]any); ok {
+			service.GetLimitsRegistry().SetDefaults(defs)
+		}
+	})
+
 	log.InfoMust(!config.IsDebug(), "■ ■ System ■ ■ 初始化完成")
 }
 
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/service"
+	"katydid-mp-user/internal/pkg/handler"
+	pkgModel "katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/pkg/auth"
+	"katydid-mp-user/pkg/middleware"
+	"strconv"
+	"strings"
+)
+
+type (
+	Access struct {
+		*handler.Base
+		service *service.Access
+	}
+
+	// AccessEntry 进入app的请求 (启动/唤醒)
+	AccessEntry struct {
+		Kind model.AccessKind `json:"kind" form:"kind" binding:"required"`
+	}
+)
+
+func NewAccess(
+	svc *service.Access,
+) *Access {
+	return &Access{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// OnAccess 认证中间件的访问回调 (api访问记录)
+func (a *Access) OnAccess(c *gin.Context, claims *auth.TokenClaims) {
+	access := NewAccessByRequest(c, model.EntryKindAccess,
+		model.OwnKind(claims.OwnKind), claims.OwnID, claims.AccountID, claims.UserID,
+	)
+	a.service.Record(access)
+}
+
+// PostEntry 进入app (启动/唤醒)，app在启动和后台切前台时调用
+func (a *Access) PostEntry(c *handler.Ctx, bind *AccessEntry) {
+	if (bind.Kind != model.EntryKindStart) && (bind.Kind != model.EntryKindWake) {
+		c.Response400("invalid_request_format", nil)
+		return
+	} else if c.AccID == nil {
+		c.Response401(nil)
+		return
+	}
+	ctx := c.ServiceCtx()
+	access := NewAccessByRequest(c.GCtx(), bind.Kind, model.OwnKind(ctx.OwnKind), ctx.OwnID, *c.AccID, c.UserID)
+	a.service.Record(access)
+	c.Response200(nil)
+}
+
+func (a *Access) Get(c *handler.Ctx) {
+	accountID, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (accountID <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能查自己的，或有权限的own/all (查询时还会按数据范围过滤)
+	allow, err := a.service.Allow(c.ServiceCtx(), accountID)
+	if err != nil {
+		c.Response400("查询访问记录失败", err)
+		return
+	} else if !allow {
+		c.Response403(msg.ErrIdPermDenied)
+		return
+	}
+
+	startStr, _ := c.RequestQuery("startAt", "0")
+	endStr, _ := c.RequestQuery("endAt", "0")
+	startAt, _ := strconv.ParseInt(startStr, 10, 64)
+	endAt, _ := strconv.ParseInt(endStr, 10, 64)
+
+	var kinds []model.AccessKind
+	if kindsStr, ok := c.RequestQuery("kinds", ""); ok && (len(kindsStr) > 0) {
+		for _, kindStr := range strings.Split(kindsStr, ",") {
+			kind, e := strconv.Atoi(strings.TrimSpace(kindStr))
+			if e != nil {
+				c.Response400("invalid_request_format", nil)
+				return
+			}
+			kinds = append(kinds, model.AccessKind(kind))
+		}
+	}
+
+	page, size := c.RequestPagination()
+	field, order := c.RequestSorting("createAt", "desc", model.AccessSorts)
+	list, err := a.service.Histories(c.ServiceCtx(), accountID, kinds, startAt, endAt, field, order, page, size)
+	if err != nil {
+		c.Response400("查询访问记录失败", err)
+		return
+	}
+	c.Response200(list)
+}
+
+// NewAccessByRequest 根据请求头生成访问记录
+func NewAccessByRequest(
+	c *gin.Context, kind model.AccessKind,
+	ownKind model.OwnKind, ownID uint64, accountID uint64, userID *uint64,
+) *model.Access {
+	deviceID := c.GetHeader(middleware.XDeviceIDHeader)
+	access := model.NewAccess(kind, ownKind, ownID, deviceID, accountID, userID, nil)
+
+	access.IP = c.ClientIP()
+	access.Method = c.Request.Method
+	access.Path = c.FullPath()
+	access.DeviceName = c.GetHeader(middleware.XDeviceNameHeader)
+	access.Market = c.GetHeader(middleware.XMarketHeader)
+	access.Language = c.GetHeader(middleware.LanguageKey)
+	access.Platform = c.GetHeader(middleware.XPlatformHeader)
+	access.OsVersion = c.GetHeader(middleware.XOsVersionHeader)
+	if appVersion, e := strconv.Atoi(c.GetHeader(middleware.XAppVersionHeader)); e == nil {
+		access.AppVersion = appVersion
+	}
+	if locStr := c.GetHeader(middleware.XLocationHeader); len(locStr) > 0 {
+		if location, e := pkgModel.ParseLocation(locStr); e == nil && location.IsValid() {
+			access.Location = location
+		}
+	}
+	return access
+}
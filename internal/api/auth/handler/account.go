@@ -2,33 +2,30 @@ package handler
 
 import (
 	"katydid-mp-user/internal/api/auth/model"
-	"katydid-mp-user/internal/api/auth/repo/cache"
-	"katydid-mp-user/internal/api/auth/repo/db"
 	"katydid-mp-user/internal/api/auth/service"
 	"katydid-mp-user/internal/pkg/handler"
 	"katydid-mp-user/pkg/data"
-	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/middleware"
+	"strconv"
 )
 
 type Account struct {
 	*handler.Base
 	service *service.Account
+	verify  *service.Verify
 }
 
 func NewAccount(
-	db *db.Account, cache *cache.Account,
-	getMaxNumByOwner func(ownKind model.TokenOwn, ownID uint64) (int, *errs.CodeErrs),
-	isOwnerAuthEnable func(ownKind model.TokenOwn, ownID uint64, kind model.AuthKind) (bool, *errs.CodeErrs),
+	svc *service.Account, verify *service.Verify,
 ) *Account {
 	return &Account{
-		Base: handler.NewBase(nil),
-		service: service.NewAccount(
-			db, cache, isOwnerAuthEnable, getMaxNumByOwner,
-		),
+		Base:    handler.NewBase(nil),
+		service: svc,
+		verify:  verify,
 	}
 }
 
-func (a *Account) Post() {
+func (a *Account) Post(c *handler.Ctx) {
 	bind := &struct {
 		OwnType  int     `json:"ownType" form:"ownType" binding:"required"`
 		OwnId    uint64  `json:"ownId" form:"ownId" binding:"required"`
@@ -38,50 +35,138 @@ func (a *Account) Post() {
 		AuthKind int        `json:"authKind" form:"authKind" binding:"required"`
 		Extra    data.KSMap `json:"extra" form:"extra" binding:"required"`
 	}{}
-	err := a.RequestBind(bind, true)
+	err := c.RequestBind(bind, true)
 	if err != nil {
-		a.Response400("", err)
+		c.Response400("", err)
 		return
 	}
-	ownKind := model.TokenOwn(rune(bind.OwnType))
-	//authKind := model.TokenOwn(rune(param.AuthKind))
+	add := model.NewAccountEmpty()
+	add.OwnKind = model.OwnKind(bind.OwnType)
+	add.OwnID = bind.OwnId
+	add.UserID = bind.UserId
+	if len(bind.Nickname) > 0 {
+		add.Nickname = &bind.Nickname
+	}
+	//authKind := model.AuthKind(bind.AuthKind)
 
 	// TODO:GG 先check Verify？
 
-	// TODO:GG 再Add Auth?
+	// TODO:GG 再Add Auth? (根据authKind+extra生成auth)
 
-	add, err := a.service.AddAccount(ownKind, bind.OwnId, bind.UserId, bind.Nickname, nil)
+	err = a.service.Register(add)
 	if err != nil {
-		a.Response400("添加account失败", err)
+		c.Response400("添加account失败", err)
 		return
 	}
 
-	a.Response200(add)
+	c.Response200(add)
 }
 
-func (a *Account) Del() {
-	//name := c.Param("id")
-	//u, err := strconv.ParseUint(name, 10, 64)
-	//if err != nil {
-	//	c.FString(http.StatusBadRequest, "Invalid Id")
-	//	return
-	//}
-	//drop := c.DefaultPostForm("drop", "0")
-	//ok := false
-	//var codeError *utils.CodeError
-	//if drop == "1" {
-	//	ok, codeError = service.DropClient(u)
-	//} else {
-	//	// TODO:GG by
-	//	ok, codeError = service.DelClient(u, 0)
-	//}
-	//if codeError != nil {
-	//	c.JSON(http.StatusNotFound, codeError)
-	//}
-	//c.JSON(http.StatusOK, ok)
+// Unblock 管理员解锁账号
+func (a *Account) Unblock(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 管理员权限走鉴权中间件，数据范围服务里限制
+
+	err := a.service.Unblock(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("解锁account失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// PutNickname 修改昵称
+func (a *Account) PutNickname(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能改数据范围里的 (自己的，或有权限的own/all)
+
+	bind := &struct {
+		Nickname string `json:"nickname" form:"nickname" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	err = a.service.ChangeNickname(c.ServiceCtx(), id, bind.Nickname)
+	if err != nil {
+		c.Response400("修改昵称失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// ResetNickname 重置昵称 (违规昵称)
+func (a *Account) ResetNickname(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 管理员权限走鉴权中间件，数据范围服务里限制
+
+	err := a.service.ResetNickname(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("重置昵称失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// Del 注销账号 (冷静期内可恢复)
+func (a *Account) Del(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能注销数据范围里的 (自己的，或有权限的own/all)
+
+	verified, ok := a.bindVerified(c, id, model.VerifyApplyUnregister)
+	if !ok {
+		return
+	}
+	revokes, err := a.service.UnRegister(c.ServiceCtx(), id, verified)
+	if err != nil {
+		c.Response400("注销account失败", err)
+		return
+	}
+	// 注销即下线，已经签发的访问token也要失效
+	if len(revokes) > 0 {
+		middleware.BlacklistTokens(revokes...)
+	}
+	c.Response200(nil)
+}
+
+// Restore 冷静期内恢复注销的账号 (注销后不能登录，不走认证，凭证是账号绑定认证的验证码)
+func (a *Account) Restore(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+
+	verified, ok := a.bindVerified(c, id, model.VerifyApplyUnregister)
+	if !ok {
+		return
+	}
+	err := a.service.Restore(c.ServiceCtx(), id, verified)
+	if err != nil {
+		c.Response400("恢复account失败", err)
+		return
+	}
+	c.Response200(nil)
 }
 
-func (a *Account) Put() {
+func (a *Account) Put(c *handler.Ctx) {
 
 	//// TODO:GG 验证之后再设置密码，防止被别人注册但是没验证就知道密码了
 	//
@@ -91,19 +176,12 @@ func (a *Account) Put() {
 	//// TODO:GG 先获取account
 	//account := model.NewAccountEmpty()
 	//
-	//if action == "/avatarUrl" {
-	//	// TODO:GG 修改avatarUrl
-	//	avatarUrl := c.DefaultQuery("avatarUrl", "")
-	//	account.SetAvatarUrl(&avatarUrl)
-	//	// 修改url
-	//	//ok, codeError := service.UpdClientAvatarUrl(u, avatarUrl)
-	//	log.Debug("UpdAccount", log.FAny("avatarUrl", account))
-	//}
+	//// 头像走 PUT :id/avatar (Avatar.Put)
 	//
 	//c.String(http.StatusOK, "account:%s by %s", id, action)
 }
 
-func (a *Account) Get() {
+func (a *Account) Get(c *handler.Ctx) {
 	//id := c.Param("id")
 	//
 	////firstname := c.DefaultQuery("firstname", "Guest")
@@ -123,3 +201,31 @@ func (a *Account) Get() {
 	//}
 	//c.JSON(http.StatusOK, client)
 }
+
+// bindVerified 校验请求里带的验证码 (没带则是未验证)，验证码的目标要是账号id绑定的认证
+func (a *Account) bindVerified(c *handler.Ctx, id uint64, apply model.VerifyApply) (bool, bool) {
+	bind := model.NewVerifyEmpty()
+	err := c.RequestBind(bind, false)
+	if err != nil {
+		c.Response400("", err)
+		return false, false
+	}
+	if _, ok := bind.GetBody(); !ok {
+		return false, true
+	} else if bind.Apply != apply {
+		c.Response400("invalid_request_format", nil)
+		return false, false
+	}
+	// 先检查目标，别人的验证码不能拿来用 (也不消耗验证次数)
+	err = a.service.CheckVerifyTarget(id, bind)
+	if err != nil {
+		c.Response400("验证失败", err)
+		return false, false
+	}
+	err = a.verify.Valid(bind)
+	if err != nil {
+		c.Response400("验证失败", err)
+		return false, false
+	}
+	return true, true
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"katydid-mp-user/internal/api/auth/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/pkg/oss"
+	"net/http"
+	"strconv"
+)
+
+const (
+	avatarFormOverhead = 16 << 10 // multipart的边界/头等额外大小
+)
+
+type Avatar struct {
+	*handler.Base
+	service *service.Avatar
+	local   *oss.Local // 本地存储时，由这里校验签名返回文件
+}
+
+func NewAvatar(
+	svc *service.Avatar, local *oss.Local,
+) *Avatar {
+	return &Avatar{
+		Base:    handler.NewBase(nil),
+		service: svc,
+		local:   local,
+	}
+}
+
+// Put 上传头像 (multipart file)
+func (a *Avatar) Put(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能改数据范围里的 (自己的，或有权限的own/all)
+
+	// 整个请求体限制大小 (multipart解析会先落盘，不能只限制读文件)
+	req := c.GCtx().Request
+	req.Body = http.MaxBytesReader(c.GCtx().Writer, req.Body, a.service.MaxSize()+avatarFormOverhead)
+	header, e := c.GCtx().FormFile("file")
+	if e != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(e, &maxErr) {
+			c.Response400(msg.ErrIdAccountAvatarLarge, nil)
+			return
+		}
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	f, e := header.Open()
+	if e != nil {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	defer f.Close()
+	// 多读1byte，超过上限的由service报错
+	data, e := io.ReadAll(io.LimitReader(f, a.service.MaxSize()+1))
+	if e != nil {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+
+	urls, err := a.service.Upload(c.ServiceCtx(), id, data)
+	if err != nil {
+		c.Response400("上传头像失败", err)
+		return
+	}
+	c.Response200(urls)
+}
+
+// Get 头像的访问链接 (各尺寸)
+func (a *Avatar) Get(c *handler.Ctx) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+
+	urls, err := a.service.Get(id)
+	if err != nil {
+		c.Response400("查询头像失败", err)
+		return
+	}
+	c.Response200(urls)
+}
+
+// File 本地存储的头像文件 (签名链接，不需要登录)
+func (a *Avatar) File(c *handler.Ctx) {
+	if a.local == nil {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	key, _ := c.RequestQuery("key", "")
+	expiresStr, _ := c.RequestQuery("expires", "")
+	sign, _ := c.RequestQuery("sign", "")
+	expires, e := strconv.ParseInt(expiresStr, 10, 64)
+	if (e != nil) || (len(key) <= 0) || (len(sign) <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+
+	path, ok := a.local.Verify(key, expires, sign)
+	if !ok {
+		c.Response400("链接无效", nil)
+		return
+	}
+	c.GCtx().File(path)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"katydid-mp-user/internal/api/auth/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"strconv"
+	"strings"
+)
+
+type Export struct {
+	*handler.Base
+	service *service.Export
+}
+
+func NewExport(
+	svc *service.Export,
+) *Export {
+	return &Export{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Post 申请导出个人数据
+func (a *Export) Post(c *handler.Ctx) {
+	accountID, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (accountID <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能导出数据范围里的 (自己的，或有权限的own/all)
+
+	add, err := a.service.Apply(c.ServiceCtx(), accountID)
+	if err != nil {
+		c.Response400("申请导出失败", err)
+		return
+	}
+	c.Response200(add)
+}
+
+// Get 查询导出进度 (完成后返回下载链接)
+func (a *Export) Get(c *handler.Ctx) {
+	accountID, e1 := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	exportID, e2 := strconv.ParseUint(c.RequestParam("exportId", ""), 10, 64)
+	if (e1 != nil) || (e2 != nil) || (accountID <= 0) || (exportID <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 只能查数据范围里的 (自己的，或有权限的own/all)
+
+	exist, err := a.service.Get(c.ServiceCtx(), exportID, accountID)
+	if err != nil {
+		c.Response400("查询导出失败", err)
+		return
+	}
+	result := map[string]any{"export": exist}
+	if exist.IsFinished() && (exist.ExpireAt > 0) {
+		// .../auth/:id/export/:exportId -> .../auth/export/download
+		path := strings.TrimSuffix(c.GCtx().FullPath(), ":id/export/:exportId") + "export/download"
+		result["url"] = fmt.Sprintf("%s?id=%d&expireAt=%d&sign=%s",
+			path, exist.ID, exist.ExpireAt, a.service.Sign(exist))
+	}
+	c.Response200(result)
+}
+
+// Download 下载导出文件 (签名链接，不需要登录)
+func (a *Export) Download(c *handler.Ctx) {
+	idStr, _ := c.RequestQuery("id", "")
+	expireAtStr, _ := c.RequestQuery("expireAt", "")
+	sign, _ := c.RequestQuery("sign", "")
+	id, e1 := strconv.ParseUint(idStr, 10, 64)
+	expireAt, e2 := strconv.ParseInt(expireAtStr, 10, 64)
+	if (e1 != nil) || (e2 != nil) || (len(sign) <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+
+	exist, err := a.service.Download(id, expireAt, sign)
+	if err != nil {
+		c.Response400("下载失败", err)
+		return
+	}
+	c.GCtx().FileAttachment(exist.FilePath, fmt.Sprintf("export_%d.zip", exist.AccountID))
+}
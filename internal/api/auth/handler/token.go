@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/pkg/middleware"
+	"strings"
+)
+
+type (
+	Token struct {
+		*handler.Base
+		service *service.Token
+	}
+
+	// TokenLogin 登录的请求 (密码登录传password，验证码登录传code)
+	TokenLogin struct {
+		OwnKind  int16    `json:"ownKind" form:"ownKind" binding:"required"`
+		OwnID    uint64   `json:"ownId" form:"ownId" binding:"required"`
+		AuthKind int16    `json:"authKind" form:"authKind" binding:"required"`
+		Target   []string `json:"target" form:"target" binding:"required"` // 密码[username] 手机[code, number] 邮箱[username, domain]
+		Password string   `json:"password" form:"password"`                // md5
+		Code     string   `json:"code" form:"code"`
+	}
+
+	// TokenExchange SSO交换的请求
+	TokenExchange struct {
+		OwnKind int16  `json:"ownKind" form:"ownKind" binding:"required"`
+		OwnID   uint64 `json:"ownId" form:"ownId" binding:"required"`
+	}
+
+	// TokenRefresh 刷新token的请求
+	TokenRefresh struct {
+		RefreshToken string `json:"refreshToken" form:"refreshToken" binding:"required"`
+	}
+)
+
+func NewToken(
+	svc *service.Token,
+) *Token {
+	return &Token{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Login 登录 (密码/验证码)，返回token
+func (a *Token) Login(c *handler.Ctx, bind *TokenLogin) {
+	iAuth, ok := model.NewAuthByTarget(model.AuthKind(bind.AuthKind), bind.Target)
+	if !ok {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	ownKind := model.OwnKind(bind.OwnKind)
+	deviceID := c.GCtx().GetHeader(middleware.XDeviceIDHeader)
+	access := NewAccessByRequest(c.GCtx(), model.EntryKindLogin, ownKind, bind.OwnID, 0, nil)
+
+	token, err := a.service.Login(ownKind, bind.OwnID, iAuth, bind.Password, bind.Code, access, deviceID)
+	if err != nil {
+		c.Response400("登录失败", err)
+		return
+	}
+	c.Response200(token)
+}
+
+// Exchange SSO交换 (用当前token换取共享应用的token)
+func (a *Token) Exchange(c *handler.Ctx, bind *TokenExchange) {
+	accessToken := a.accessToken(c)
+	if len(accessToken) <= 0 {
+		c.Response401(nil)
+		return
+	}
+	deviceID := c.GCtx().GetHeader(middleware.XDeviceIDHeader)
+
+	token, err := a.service.Exchange(accessToken, model.OwnKind(bind.OwnKind), bind.OwnID, deviceID)
+	if err != nil {
+		c.Response400("交换token失败", err)
+		return
+	}
+	c.Response200(token)
+}
+
+// Refresh 刷新token (角色变更后要刷新，旧的访问token加黑名单)
+func (a *Token) Refresh(c *handler.Ctx, bind *TokenRefresh) {
+	deviceID := c.GCtx().GetHeader(middleware.XDeviceIDHeader)
+
+	token, revoke, err := a.service.Refresh(bind.RefreshToken, deviceID)
+	if err != nil {
+		c.Response400("刷新token失败", err)
+		return
+	}
+	if len(revoke) > 0 {
+		middleware.BlacklistTokens(revoke)
+	}
+	c.Response200(token)
+}
+
+// Del 登出 (级联登出SSO关联的token)
+func (a *Token) Del(c *handler.Ctx) {
+	accessToken := a.accessToken(c)
+	if len(accessToken) <= 0 {
+		c.Response401(nil)
+		return
+	}
+	access := NewAccessByRequest(c.GCtx(), model.EntryKindLogout, 0, 0, 0, nil)
+	revokes, err := a.service.Logout(accessToken, access)
+	if len(revokes) > 0 {
+		middleware.BlacklistTokens(revokes...)
+	}
+	if err != nil {
+		c.Response400("登出失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+func (a *Token) accessToken(c *handler.Ctx) string {
+	authStr := c.GCtx().GetHeader(middleware.AuthHeaderToken)
+	return strings.TrimPrefix(authStr, middleware.AuthHeaderPrefix)
+}
@@ -23,11 +23,11 @@ func NewVerify(
 	}
 }
 
-func (v *Verify) Post() {
+func (v *Verify) Post(c *handler.Ctx) {
 	bind := model.NewVerifyEmpty()
-	err := v.RequestBind(bind, true)
+	err := c.RequestBind(bind, true)
 	if err != nil {
-		v.Response400("绑定失败", err)
+		c.Response400("绑定失败", err)
 		return
 	}
 
@@ -38,7 +38,7 @@ func (v *Verify) Post() {
 	// 添加记录
 	err = v.service.Add(bind)
 	if err != nil {
-		v.Response400("添加验证码失败", err)
+		c.Response400("添加验证码失败", err)
 		return
 	}
 
@@ -53,34 +53,34 @@ func (v *Verify) Post() {
 		err = v.service.OnSendFail(bind)
 	}
 	if err != nil {
-		v.Response400("发送验证码失败", err)
+		c.Response400("发送验证码失败", err)
 		return
 	}
-	v.Response200(bind) // TODO:GG 不应该返回，只有200就行，或者过期时间,code_len等
+	c.Response200(bind) // TODO:GG 不应该返回，只有200就行，或者过期时间,code_len等
 }
 
-func (v *Verify) Del() {
+func (v *Verify) Del(c *handler.Ctx) {
 
 }
 
-func (v *Verify) Put() {
+func (v *Verify) Put(c *handler.Ctx) {
 	bind := model.NewVerifyEmpty()
-	err := v.RequestBind(bind, true)
+	err := c.RequestBind(bind, true)
 	if err != nil {
-		v.Response400("绑定失败", err)
+		c.Response400("绑定失败", err)
 		return
 	}
 	valid, err := v.service.Valid(bind)
 	if err != nil {
-		v.Response400("验证失败", err)
+		c.Response400("验证失败", err)
 		return
 	} else if !valid {
-		v.Response400("验证失败", nil)
+		c.Response400("验证失败", nil)
 		return
 	}
-	v.Response200(nil)
+	c.Response200(nil)
 }
 
-func (v *Verify) Get() {
+func (v *Verify) Get(c *handler.Ctx) {
 
 }
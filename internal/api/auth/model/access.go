@@ -1,45 +1,56 @@
 package model
 
-import "katydid-mp-user/internal/pkg/model"
+import (
+	"katydid-mp-user/internal/pkg/model"
+	"time"
+)
 
 type (
 	// Access 访问记录
 	Access struct {
 		*model.Base
 
-		Kind AccessKind
+		Kind AccessKind `json:"kind"` // 访问类型
+
+		OwnKind   OwnKind `json:"ownKind"`   // 账号拥有者类型
+		OwnID     uint64  `json:"ownId"`     // 账号拥有者ID
+		DeviceID  string  `json:"deviceId"`  // 设备标识，用于身份验证
+		AccountID uint64  `json:"accountId"` // 账号ID
+
+		UserID *uint64 `json:"userId"` // 用户ID
+		RoleID *uint64 `json:"roleId"` // 角色ID
 
-		OwnKind   OwnKind
-		OwnID     uint64
-		DeviceID  string
-		AccountID uint64
+		IP     string `json:"ip"`     // 客户端ip
+		Method string `json:"method"` // 请求方法 (api)
+		Path   string `json:"path"`   // 请求路径 (api)
 
-		UserID *uint64
-		RoleID *uint64
+		DeviceName string `json:"deviceName"` // 设备名称+型号，用于兼容设备
+		Market     string `json:"market"`     // 渠道，用于统计下载来源
+		Language   string `json:"language"`   // 语言
+		Platform   string `json:"platform"`   // WeChat，IOS，Android，用于统计平台
+		OsVersion  string `json:"osVersion"`  // weChat/android/ios版本，用于统计兼容版本
+		AppVersion int    `json:"appVersion"` // 软件versionCode，用于统计升级率，409 低版本升级
 
-		// ip
-		// time
-		// TODO:GG isLogin
+		Location *model.Location `json:"location" gorm:"serializer:json"` // 定位信息
+
+		RiskScore int        `json:"riskScore"`                        // 风险分 (登录)
+		RiskFlags []RiskFlag `json:"riskFlags" gorm:"serializer:json"` // 风险标记 (登录)
 
 		// TODO:GG 不信任的设备登录是，需要refresh JWTToken? client定
 
 		// TODO:GG 小游戏和单机，都是不需要account的，只有device？
-
-		// TODO:GG ip, device, location
-		// 		UserId     int64  `json:"userId"`     // --
-		//		DeviceId   string `json:"deviceId"`   // 设备标识，用于身份验证
-		//		DeviceName string `json:"deviceName"` // 设备名称+型号，用于兼容设备
-		//		Market     string `json:"market"`     // 渠道，用于统计下载来源
-		//		Language   string `json:"language"`   // 语言
-		//		Platform   string `json:"platform"`   // WeChat，IOS，Android，用于统计平台
-		//		OsVersion  string `json:"osVersion"`  // weChat/android/ios版本，用于统计兼容版本
-		//		AppVersion int    `json:"appVersion"` // 软件versionCode，用于统计升级率，409 低版本升级
 	}
 
 	// AccessKind 访问类型
 	AccessKind int8
+
+	// RiskFlag 登录风险标记
+	RiskFlag string
 )
 
+// AccessSorts 访问记录可排序的字段
+var AccessSorts = []string{"createAt", "kind"}
+
 const (
 	EntryKindLogin  AccessKind = 1 // 登录
 	EntryKindStart  AccessKind = 2 // 启动(重新打开app)
@@ -47,3 +58,60 @@ const (
 	EntryKindAccess AccessKind = 4 // 访问(api)
 	EntryKindLogout AccessKind = 5 // 登出
 )
+
+const (
+	RiskFlagTravel  RiskFlag = "travel"  // 不可能的移动 (距离/时间 超速)
+	RiskFlagCountry RiskFlag = "country" // 新的国家
+	RiskFlagDevice  RiskFlag = "device"  // 新的设备
+)
+
+func NewAccessEmpty() *Access {
+	return &Access{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewAccess(
+	kind AccessKind,
+	ownKind OwnKind, ownID uint64, deviceID string, accountID uint64,
+	userID, roleID *uint64,
+) *Access {
+	base := model.NewBaseEmpty()
+	base.CreateAt = time.Now().UnixMilli() // 异步写入，以产生时间为准
+	return &Access{
+		Base:    base,
+		Kind:    kind,
+		OwnKind: ownKind, OwnID: ownID, DeviceID: deviceID, AccountID: accountID,
+		UserID: userID, RoleID: roleID,
+	}
+}
+
+// IsLogin 是否是登录记录
+func (a *Access) IsLogin() bool {
+	return a.Kind == EntryKindLogin
+}
+
+// IsEntry 是否是进入记录 (启动/唤醒)
+func (a *Access) IsEntry() bool {
+	return (a.Kind == EntryKindStart) || (a.Kind == EntryKindWake)
+}
+
+// IsAccess 是否是访问记录 (api)
+func (a *Access) IsAccess() bool {
+	return a.Kind == EntryKindAccess
+}
+
+// HasRisk 是否有风险标记
+func (a *Access) HasRisk(flag RiskFlag) bool {
+	for _, f := range a.RiskFlags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTime 访问时间
+func (a *Access) AccessTime() time.Time {
+	return time.UnixMilli(a.CreateAt)
+}
@@ -2,8 +2,10 @@ package model
 
 import (
 	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/data"
 	"katydid-mp-user/pkg/valid"
 	"reflect"
+	"time"
 )
 
 type (
@@ -15,12 +17,12 @@ type (
 		Number   *uint64 `json:"number" validate:"format-number"`       // 账号标识 (自定义数字，防止暴露ID)
 		Nickname *string `json:"nickname" validate:"format-nickname"`   // 昵称 (没有user的app/org会用这个，放外面是方便搜索)
 
-		UserID *uint64            `json:"userId"`          // 认证用户Id (有些org/app不填user，这里是第一绑定)
-		Auths  map[AuthKind]IAuth `json:"auths,omitempty"` // 认证方式列表 (多对多)
+		UserID *uint64            `json:"userId"`                   // 认证用户Id (有些org/app不填user，这里是第一绑定)
+		Auths  map[AuthKind]IAuth `json:"auths,omitempty" gorm:"-"` // 认证方式列表 (多对多，account_auth表)
 
-		LoginHistory  []any `json:"loginHistory,omitempty"`  // 登录历史(login)
-		EntryHistory  []any `json:"entryHistory,omitempty"`  // 进入历史(entry)
-		AccessHistory []any `json:"accessHistory,omitempty"` // 访问历史(api)
+		LoginHistory  []*Access `json:"loginHistory,omitempty" gorm:"-"`  // 登录历史(login)
+		EntryHistory  []*Access `json:"entryHistory,omitempty" gorm:"-"`  // 进入历史(entry)
+		AccessHistory []*Access `json:"accessHistory,omitempty" gorm:"-"` // 访问历史(api)
 	}
 )
 
@@ -139,11 +141,7 @@ func (a *Account) AddAuth(auth IAuth) bool {
 	a.Auths[auth.GetKind()] = auth
 	auth.SetAccount(a)
 
-	if (a.Status >= AccountStatusInit) && (a.Status < AccountStatusActive) {
-		a.Status = AccountStatusActive
-		return true
-	}
-	return false
+	return a.transit(accEventActivate, AccountStatusActive)
 }
 
 // DelAuth 删除认证方式
@@ -157,11 +155,118 @@ func (a *Account) DelAuth(auth IAuth) bool {
 		delete(a.Auths, auth.GetKind())
 	}
 
-	if (len(a.Auths) == 0) && (a.Status >= AccountStatusActive) {
-		a.Status = AccountStatusInit
-		return true
+	return (len(a.Auths) == 0) && a.transit(accEventDeactivate, AccountStatusInit)
+}
+
+// IsLocked 是否锁定中 (Locked/Blocked)
+func (a *Account) IsLocked() bool {
+	return (a.Status == AccountStatusLocked) || (a.Status == AccountStatusBlocked)
+}
+
+// Lock 锁定账号 (until锁定到的时间ms，block是否需要管理员解锁)，状态机不允许的返回false
+func (a *Account) Lock(until int64, block bool) bool {
+	locked := a.IsLocked()
+	status := int(a.Status)
+	if block {
+		if !a.transit(accEventBlock, AccountStatusBlocked) {
+			return false
+		}
+		a.Extra.SetInt64(accExtraKeyLockUntil, nil)
+	} else {
+		if !a.transit(accEventLock, AccountStatusLocked) {
+			return false
+		}
+		a.Extra.SetInt64(accExtraKeyLockUntil, &until)
+	}
+	if !locked {
+		a.Extra.SetInt(accExtraKeyLockStatus, &status)
+	}
+	return true
+}
+
+// Unlock 解锁账号，恢复锁定前的状态
+func (a *Account) Unlock() bool {
+	if !a.IsLocked() {
+		return false
+	}
+	to := AccountStatusInit
+	if status, ok := a.Extra.GetInt(accExtraKeyLockStatus); ok {
+		to = model.Status(status)
+	} else if len(a.Auths) > 0 {
+		to = AccountStatusActive
+	}
+	if !a.transit(accEventUnlock, to) {
+		return false
+	}
+	a.Extra.SetInt(accExtraKeyLockStatus, nil)
+	a.Extra.SetInt64(accExtraKeyLockUntil, nil)
+	return true
+}
+
+// UnRegister 注销账号 (purgeAt之前可恢复，之后清除)
+func (a *Account) UnRegister(purgeAt int64) bool {
+	status := int(a.Status)
+	if !a.transit(accEventUnRegister, AccountStatusUnRegister) {
+		return false
+	}
+	now := time.Now().UnixMilli()
+	a.Extra.SetInt(accExtraKeyUnRegisterStatus, &status)
+	a.Extra.SetInt64(accExtraKeyUnRegisterAt, &now)
+	a.Extra.SetInt64(accExtraKeyPurgeAt, &purgeAt)
+	return true
+}
+
+// Restore 恢复注销的账号，恢复注销前的状态
+func (a *Account) Restore() bool {
+	if a.Status != AccountStatusUnRegister {
+		return false
+	}
+	to := AccountStatusInit
+	if status, ok := a.Extra.GetInt(accExtraKeyUnRegisterStatus); ok {
+		to = model.Status(status)
+	}
+	if !a.transit(accEventRestore, to) {
+		return false
+	}
+	a.Extra.SetInt(accExtraKeyUnRegisterStatus, nil)
+	a.Extra.SetInt64(accExtraKeyUnRegisterAt, nil)
+	a.Extra.SetInt64(accExtraKeyPurgeAt, nil)
+	return true
+}
+
+// transit 按状态机通过event转换状态，不允许的返回false (状态不变)
+func (a *Account) transit(event string, to model.Status) bool {
+	return accountFSM.CanEvent(a, event, to) && (accountFSM.FireEvent(a, event, to) == nil)
+}
+
+// Anonymize 匿名化 (清除个人信息，保留ID/Number等统计用)
+func (a *Account) Anonymize() {
+	a.Nickname = nil
+	a.UserID = nil
+	a.Auths = make(map[AuthKind]IAuth)
+	a.LoginHistory = nil
+	a.EntryHistory = nil
+	a.AccessHistory = nil
+	unRegisterAt, _ := a.GetUnRegisterAt()
+	a.Extra = make(data.KSMap)
+	a.Extra.SetInt64(accExtraKeyUnRegisterAt, &unRegisterAt)
+}
+
+// AddHistory 按类型填充访问历史
+func (a *Account) AddHistory(accesses ...*Access) {
+	for _, access := range accesses {
+		if access == nil {
+			continue
+		}
+		switch {
+		case access.IsLogin():
+			a.LoginHistory = append(a.LoginHistory, access)
+		case access.IsEntry():
+			a.EntryHistory = append(a.EntryHistory, access)
+		case access.IsAccess():
+			a.AccessHistory = append(a.AccessHistory, access)
+		}
 	}
-	return false
 }
 
 // GetAuthKinds 获取认证方式种类列表
@@ -216,6 +321,7 @@ func (a *Account) AddRole(role string) {
 	}
 	roles = append(roles, role)
 	a.SetRoles(&roles)
+	a.BumpRoleVer()
 }
 
 // HasRole 检查是否拥有指定角色 TODO:GG 在这里?
@@ -236,6 +342,18 @@ const (
 	accExtraKeyAvatarID  = "avatarId"  // 头像ID
 	accExtraKeyAvatarUrl = "avatarUrl" // 头像URL
 	accExtraKeyRoles     = "roles"     // 角色列表 (默认只有org下的用户有) TODO:GG 放在extra？还是这里外键关联？还是不放？
+	accExtraKeyRoleVer   = "roleVer"   // 角色版本ms (角色变更时更新，旧版本的token要刷新)
+
+	accExtraKeyLoginFails = "loginFails" // 连续登录失败次数
+	accExtraKeyLoginLocks = "loginLocks" // 连续锁定次数 (锁定时长递增)
+	accExtraKeyLockUntil  = "lockUntil"  // 锁定到的时间ms
+	accExtraKeyLockStatus = "lockStatus" // 锁定前的状态
+
+	accExtraKeyUnRegisterAt     = "unRegisterAt"     // 注销时间ms
+	accExtraKeyUnRegisterStatus = "unRegisterStatus" // 注销前的状态
+	accExtraKeyPurgeAt          = "purgeAt"          // 清除时间ms (冷静期结束)
+
+	accExtraKeyNicknameAt = "nicknameAt" // 上次修改昵称时间ms
 )
 
 func (a *Account) SetAvatarID(avatarId *int64) {
@@ -254,6 +372,14 @@ func (a *Account) GetAvatarUrl() (string, bool) {
 	return a.Extra.GetString(accExtraKeyAvatarUrl)
 }
 
+func (a *Account) SetNicknameAt(nicknameAt *int64) {
+	a.Extra.SetInt64(accExtraKeyNicknameAt, nicknameAt)
+}
+
+func (a *Account) GetNicknameAt() (int64, bool) {
+	return a.Extra.GetInt64(accExtraKeyNicknameAt)
+}
+
 func (a *Account) SetRoles(roles *[]string) {
 	a.Extra.SetStringSlice(accExtraKeyRoles, roles)
 }
@@ -261,3 +387,52 @@ func (a *Account) SetRoles(roles *[]string) {
 func (a *Account) GetRoles() ([]string, bool) {
 	return a.Extra.GetStringSlice(accExtraKeyRoles)
 }
+
+func (a *Account) GetRoleVer() int64 {
+	ver, _ := a.Extra.GetInt64(accExtraKeyRoleVer)
+	return ver
+}
+
+// BumpRoleVer 角色变更，更新角色版本
+func (a *Account) BumpRoleVer() int64 {
+	ver := time.Now().UnixMilli()
+	if old := a.GetRoleVer(); ver <= old {
+		ver = old + 1
+	}
+	a.Extra.SetInt64(accExtraKeyRoleVer, &ver)
+	return ver
+}
+
+func (a *Account) IncLoginFails() int {
+	fails, _ := a.Extra.GetInt(accExtraKeyLoginFails)
+	fails++
+	a.Extra.SetInt(accExtraKeyLoginFails, &fails)
+	return fails
+}
+
+func (a *Account) ClearLoginFails() {
+	a.Extra.SetInt(accExtraKeyLoginFails, nil)
+}
+
+func (a *Account) IncLoginLocks() int {
+	locks, _ := a.Extra.GetInt(accExtraKeyLoginLocks)
+	locks++
+	a.Extra.SetInt(accExtraKeyLoginLocks, &locks)
+	return locks
+}
+
+func (a *Account) ClearLoginLocks() {
+	a.Extra.SetInt(accExtraKeyLoginLocks, nil)
+}
+
+func (a *Account) GetLockUntil() (int64, bool) {
+	return a.Extra.GetInt64(accExtraKeyLockUntil)
+}
+
+func (a *Account) GetUnRegisterAt() (int64, bool) {
+	return a.Extra.GetInt64(accExtraKeyUnRegisterAt)
+}
+
+func (a *Account) GetPurgeAt() (int64, bool) {
+	return a.Extra.GetInt64(accExtraKeyPurgeAt)
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/crypto"
 	"katydid-mp-user/pkg/data"
 	"katydid-mp-user/pkg/valid"
 	"reflect"
@@ -17,21 +18,33 @@ type (
 	IAuth interface {
 		Wash() IAuth // 清洗数据
 
-		SetStatus(model.Status) // 设置认证状态
-		IsBlocked() bool        // 检查认证方式是否被封禁
-		IsEnabled() bool        // 检查认证方式是否启用
-		IsActive() bool         // 检查认证方式是否已激活过
-		IsBind() bool           // 检查认证方式是否已绑定账号
-		TryActive() bool        // 尝试激活认证方式 (如果未激活过，则激活)
-		TryBind() bool          // 尝试绑定认证方式 (如果未绑定过，则绑定)
+		IsBlocked() bool // 检查认证方式是否被封禁
+		IsEnabled() bool // 检查认证方式是否启用
+		IsActive() bool  // 检查认证方式是否已激活过
+		IsBind() bool    // 检查认证方式是否已绑定账号
+		TryActive() bool // 尝试激活认证方式 (如果未激活过，则激活)
+		TryBind() bool   // 尝试绑定认证方式 (如果未绑定过，则绑定)
+		TryUnbind() bool // 尝试解绑认证方式 (如果绑定了，则回到激活)
 
+		GetID() uint64     // 获取认证ID
 		GetKind() AuthKind // 获取认证类型
+		GetTarget() string // 获取认证标识 (同kind下唯一，如手机号/邮箱)
+
+		IncLoginFails() int          // 连续登录失败次数+1
+		ClearLoginFails()            // 清空连续登录失败次数
+		IncLoginLocks() int          // 连续锁定次数+1
+		ClearLoginLocks()            // 清空连续锁定次数
+		SetLockUntil(*int64)         // 设置锁定到的时间ms
+		GetLockUntil() (int64, bool) // 获取锁定到的时间ms
 
 		SetAccount(*Account)                             // 关联账号信息
 		SetAccounts(map[OwnKind]map[uint64]*Account)     // 关联账号信息
 		DelAccount(OwnKind, uint64)                      // 删除关联账号信息
 		GetAccAccounts() map[OwnKind]map[uint64]*Account // 获取关联的账号ID
 		GetAccount(OwnKind, uint64) *Account             // 获取关联的账号ID
+
+		GetVersion() int64 // 获取版本号 (乐观锁)
+		SetVersion(int64)  // 设置版本号 (乐观锁)
 	}
 
 	// Auth 可验证账号基础
@@ -41,7 +54,14 @@ type (
 
 		// implements
 
-		Accounts map[OwnKind]map[uint64]*Account `json:"-"` // 账户Id (多对多表)
+		Accounts map[OwnKind]map[uint64]*Account `json:"-" gorm:"-"` // 账户Id (多对多表)
+	}
+
+	// AccountAuth 账号和认证的关联 (多对多)
+	AccountAuth struct {
+		AccountID uint64 `json:"accountId" gorm:"primaryKey"`          // 账号
+		AuthID    uint64 `json:"authId" gorm:"primaryKey;index"`       // 认证
+		CreateAt  int64  `json:"createAt" gorm:"autoCreateTime:milli"` // 绑定时间
 	}
 
 	// AuthPassword 用户名+密码
@@ -74,13 +94,20 @@ type (
 	// OwnKind 认证拥有者类型
 	OwnKind int16
 
+	// Own 拥有者 (类型+ID)
+	Own struct {
+		Kind OwnKind
+		ID   uint64
+	}
+
 	// AuthKind 认证类型
 	AuthKind int16
 )
 
 func NewAuthEmpty() *Auth {
 	return &Auth{
-		Base: model.NewBaseEmpty(),
+		Base:     model.NewBaseEmpty(),
+		Accounts: make(map[OwnKind]map[uint64]*Account),
 	}
 }
 
@@ -102,6 +129,54 @@ func NewAuthEmailEmpty() *AuthEmail {
 	}
 }
 
+// NewAuthByKind 按认证类型生成空的认证 (仓储查询时用)
+func NewAuthByKind(kind AuthKind) (IAuth, bool) {
+	var auth IAuth
+	switch kind {
+	case AuthKindPassword:
+		auth = NewAuthPasswordEmpty()
+	case AuthKindCellphone:
+		auth = NewAuthCellphoneEmpty()
+	case AuthKindEmail:
+		auth = NewAuthEmailEmpty()
+	default:
+		return nil, false
+	}
+	return auth, true
+}
+
+// NewAuthByTarget 按认证类型+标识生成认证 (登录时查找用)
+// target: 密码[username] / 手机[code, number] / 邮箱[username, domain]
+func NewAuthByTarget(kind AuthKind, target []string) (IAuth, bool) {
+	switch kind {
+	case AuthKindPassword:
+		if (len(target) != 1) || (len(target[0]) <= 0) {
+			return nil, false
+		}
+		auth := NewAuthPasswordEmpty()
+		auth.Kind = kind
+		auth.Username = &target[0]
+		return auth, true
+	case AuthKindCellphone:
+		if (len(target) != 2) || (len(target[0]) <= 0) || (len(target[1]) <= 0) {
+			return nil, false
+		}
+		auth := NewAuthCellphoneEmpty()
+		auth.Kind = kind
+		auth.Code, auth.Number = target[0], target[1]
+		return auth, true
+	case AuthKindEmail:
+		if (len(target) != 2) || (len(target[0]) <= 0) || (len(target[1]) <= 0) {
+			return nil, false
+		}
+		auth := NewAuthEmailEmpty()
+		auth.Kind = kind
+		auth.Username, auth.Domain = target[0], target[1]
+		return auth, true
+	}
+	return nil, false
+}
+
 func (a *Auth) Wash() IAuth {
 	a.Base = a.Base.Wash(AuthStatusInit)
 	a.Accounts = make(map[OwnKind]map[uint64]*Account)
@@ -331,10 +406,7 @@ const (
 	AuthKindThirdFB     AuthKind = 105 // 三方平台-Facebook
 )
 
-func (a *Auth) SetStatus(status model.Status) {
-	a.Status = status
-}
-
+// SetStatus 按状态机转换状态，不允许的返回false (状态不变)
 func (a *Auth) IsBlocked() bool {
 	return a.Status <= AuthStatusBlock
 }
@@ -352,25 +424,45 @@ func (a *Auth) IsBind() bool {
 }
 
 func (a *Auth) TryActive() bool {
-	if (a.Status >= AuthStatusInit) && (a.Status != AuthStatusActive) {
-		a.Status = AuthStatusActive
-		return true
-	}
-	return false
+	return a.transit(authEventActivate, AuthStatusActive)
 }
 
 func (a *Auth) TryBind() bool {
-	if (a.Status >= AuthStatusActive) && (a.Status != AuthStatusBind) {
-		a.Status = AuthStatusBind
-		return true
-	}
-	return false
+	return a.transit(authEventBind, AuthStatusBind)
+}
+
+func (a *Auth) TryUnbind() bool {
+	return a.transit(authEventUnbind, AuthStatusActive)
+}
+
+// transit 按状态机通过event转换状态，不允许的返回false (状态不变)
+func (a *Auth) transit(event string, to model.Status) bool {
+	return authFSM.CanEvent(a, event, to) && (authFSM.FireEvent(a, event, to) == nil)
 }
 
 func (a *Auth) GetKind() AuthKind {
 	return a.Kind
 }
 
+func (a *Auth) GetTarget() string {
+	return ""
+}
+
+func (a *AuthPassword) GetTarget() string {
+	if a.Username == nil {
+		return ""
+	}
+	return *a.Username
+}
+
+func (a *AuthCellphone) GetTarget() string {
+	return "+" + a.Code + " " + a.Number
+}
+
+func (a *AuthEmail) GetTarget() string {
+	return a.Username + "@" + a.Domain
+}
+
 func (a *Auth) SetAccount(account *Account) {
 	if _, ok := a.Accounts[account.OwnKind]; !ok {
 		a.Accounts[account.OwnKind] = make(map[uint64]*Account)
@@ -429,6 +521,10 @@ func (a *AuthEmail) EmailAddress() string {
 
 const (
 	authExtraKeyPasswordSalt = "passwordSalt" // 密码盐 TODO:GG 不response
+
+	authExtraKeyLoginFails = "loginFails" // 连续登录失败次数
+	authExtraKeyLoginLocks = "loginLocks" // 连续锁定次数 (锁定时长递增)
+	authExtraKeyLockUntil  = "lockUntil"  // 锁定到的时间ms
 )
 
 func (a *Auth) SetPasswordSalt(salt *string) {
@@ -438,3 +534,48 @@ func (a *Auth) SetPasswordSalt(salt *string) {
 func (a *Auth) GetPasswordSalt() (string, bool) {
 	return a.Extra.GetString(authExtraKeyPasswordSalt)
 }
+
+// HashPassword 把客户端传的passwordMD5换成bcrypt哈希 (注册/改密码时，入库前调用)
+func (a *AuthPassword) HashPassword() error {
+	hash, err := crypto.HashPassword(a.PasswordMD5)
+	if err != nil {
+		return err
+	}
+	a.PasswordMD5 = hash
+	return nil
+}
+
+// CheckPassword 校验密码 (存的是bcrypt(passwordMD5)，常量时间比较)
+func (a *AuthPassword) CheckPassword(passwordMD5 string) bool {
+	return crypto.CheckPassword(a.PasswordMD5, passwordMD5)
+}
+
+func (a *Auth) IncLoginFails() int {
+	fails, _ := a.Extra.GetInt(authExtraKeyLoginFails)
+	fails++
+	a.Extra.SetInt(authExtraKeyLoginFails, &fails)
+	return fails
+}
+
+func (a *Auth) ClearLoginFails() {
+	a.Extra.SetInt(authExtraKeyLoginFails, nil)
+}
+
+func (a *Auth) IncLoginLocks() int {
+	locks, _ := a.Extra.GetInt(authExtraKeyLoginLocks)
+	locks++
+	a.Extra.SetInt(authExtraKeyLoginLocks, &locks)
+	return locks
+}
+
+func (a *Auth) ClearLoginLocks() {
+	a.Extra.SetInt(authExtraKeyLoginLocks, nil)
+}
+
+func (a *Auth) SetLockUntil(until *int64) {
+	a.Extra.SetInt64(authExtraKeyLockUntil, until)
+}
+
+func (a *Auth) GetLockUntil() (int64, bool) {
+	return a.Extra.GetInt64(authExtraKeyLockUntil)
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+)
+
+type (
+	// Export 个人数据导出 (GDPR/PIPL)
+	Export struct {
+		*model.Base
+
+		OwnKind   OwnKind `json:"ownKind"`   // 账号拥有者类型
+		OwnID     uint64  `json:"ownId"`     // 账号拥有者ID
+		AccountID uint64  `json:"accountId"` // 账号ID
+
+		Progress int    `json:"progress"` // 进度 (0~100)
+		FilePath string `json:"-"`        // 文件路径 (不返回)
+		FileSize int64  `json:"fileSize"` // 文件大小
+		ExpireAt int64  `json:"expireAt"` // 下载过期时间ms (完成后才有)
+	}
+)
+
+const (
+	ExportStatusFail    model.Status = -1 // 失败
+	ExportStatusInit    model.Status = 0  // 排队中
+	ExportStatusRunning model.Status = 1  // 打包中
+	ExportStatusDone    model.Status = 2  // 完成 (可下载)
+
+	exportMask = "******" // 敏感信息掩码
+)
+
+func NewExportEmpty() *Export {
+	return &Export{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewExport(ownKind OwnKind, ownID uint64, accountID uint64) *Export {
+	base := model.NewBaseEmpty()
+	base.Status = ExportStatusInit
+	return &Export{
+		Base:    base,
+		OwnKind: ownKind, OwnID: ownID, AccountID: accountID,
+	}
+}
+
+// IsFinished 是否结束 (完成/失败)
+func (e *Export) IsFinished() bool {
+	return (e.Status == ExportStatusDone) || (e.Status == ExportStatusFail)
+}
+
+// IsDownloadable 是否可下载
+func (e *Export) IsDownloadable(now int64) bool {
+	return (e.Status == ExportStatusDone) && (e.ExpireAt > now)
+}
+
+// MaskSecret 敏感信息掩码 (保留前keep位)
+func MaskSecret(secret string, keep int) string {
+	if len(secret) <= keep {
+		return exportMask
+	}
+	return secret[:keep] + exportMask
+}
+
+// ExportAuth 导出的认证信息 (密码/盐等掩码)
+func ExportAuth(iAuth IAuth) map[string]any {
+	var base *Auth
+	result := map[string]any{"kind": iAuth.GetKind()}
+	switch a := iAuth.(type) {
+	case *AuthPassword:
+		base = a.Auth
+		result["username"] = a.Username
+		result["password"] = exportMask
+	case *AuthCellphone:
+		base = a.Auth
+		result["code"] = a.Code
+		result["number"] = a.Number
+		result["operator"] = a.Operator
+	case *AuthEmail:
+		base = a.Auth
+		result["email"] = a.EmailAddress()
+		result["entity"] = a.Entity
+	case *Auth:
+		base = a
+	}
+	if (base != nil) && (base.Base != nil) {
+		extra := base.Extra.Clone()
+		if extra.Has(authExtraKeyPasswordSalt) {
+			extra.Set(authExtraKeyPasswordSalt, exportMask)
+		}
+		result["status"] = base.Status
+		result["createAt"] = base.CreateAt
+		result["updateAt"] = base.UpdateAt
+		result["extra"] = extra
+	}
+	return result
+}
+
+// ExportToken 导出的会话信息 (token掩码)
+func ExportToken(t *Token) map[string]any {
+	result := map[string]any{
+		"deviceId":        t.DeviceID,
+		"accessToken":     MaskSecret(t.AccessToken, 6),
+		"accessExpireAt":  t.AccessExpireAt,
+		"refreshExpireAt": t.RefreshExpireAt,
+	}
+	if t.Base != nil {
+		result["status"] = t.Status
+		result["createAt"] = t.CreateAt
+	}
+	if t.RefreshToken != nil {
+		result["refreshToken"] = MaskSecret(*t.RefreshToken, 6)
+	}
+	return result
+}
+
+// ExportVerify 导出的验证记录 (验证码掩码)
+func ExportVerify(v *Verify) map[string]any {
+	result := map[string]any{
+		"authKind":   v.AuthKind,
+		"apply":      v.Apply,
+		"target":     v.Target,
+		"sendAt":     v.SendAt,
+		"validAt":    v.ValidAt,
+		"validTimes": v.ValidTimes,
+	}
+	if v.Base != nil {
+		extra := v.Extra.Clone()
+		if extra.Has(verifyExtraKeyBody) {
+			extra.Set(verifyExtraKeyBody, exportMask)
+		}
+		result["status"] = v.Status
+		result["createAt"] = v.CreateAt
+		result["extra"] = extra
+	}
+	return result
+}
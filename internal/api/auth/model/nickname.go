@@ -0,0 +1,30 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+)
+
+type (
+	// Nickname 昵称占用 (own下归一化后的昵称唯一，靠唯一索引防并发)
+	Nickname struct {
+		*model.Base
+
+		OwnKind   OwnKind `json:"ownKind" gorm:"uniqueIndex:idx_nickname_own"` // 拥有者类型
+		OwnID     uint64  `json:"ownId" gorm:"uniqueIndex:idx_nickname_own"`   // 拥有者ID
+		Normal    string  `json:"normal" gorm:"uniqueIndex:idx_nickname_own"`  // 归一化后的昵称
+		AccountID uint64  `json:"accountId" gorm:"index"`                      // 占用的账号
+	}
+)
+
+func NewNicknameEmpty() *Nickname {
+	return &Nickname{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewNickname(ownKind OwnKind, ownID uint64, normal string, accountID uint64) *Nickname {
+	return &Nickname{
+		Base:    model.NewBaseEmpty(),
+		OwnKind: ownKind, OwnID: ownID, Normal: normal, AccountID: accountID,
+	}
+}
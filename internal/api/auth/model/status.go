@@ -0,0 +1,123 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/fsm"
+	"time"
+)
+
+// 状态机 (声明允许的状态转换)，每次转换都记到Base里，保存时写状态历史表
+
+var accountFSM = newAccountFSM()
+
+var authFSM = newAuthFSM()
+
+var verifyFSM = newVerifyFSM()
+
+const (
+	accEventActivate   = "activate"   // 添加了认证
+	accEventDeactivate = "deactivate" // 认证都删了
+	accEventLock       = "lock"       // 登录失败锁定
+	accEventBlock      = "block"      // 需要管理员解锁的锁定
+	accEventUnlock     = "unlock"     // 解锁
+	accEventUnRegister = "unregister" // 注销
+	accEventRestore    = "restore"    // 恢复注销
+	accEventBan        = "ban"        // 封禁
+)
+
+func newAccountFSM() *fsm.Machine[model.Status, *Account] {
+	m := fsm.New("account",
+		func(a *Account) model.Status { return a.Status },
+		func(a *Account, status model.Status) { a.Status = status },
+	).
+		State(AccountStatusBanned, "banned").
+		State(AccountStatusUnRegister, "unregister").
+		State(AccountStatusBlocked, "blocked").
+		State(AccountStatusLocked, "locked").
+		State(AccountStatusInit, "init").
+		State(AccountStatusActive, "active").
+		OnChange(func(a *Account, from, to model.Status, event string) {
+			a.AddStatusChange(from, to, event)
+		})
+
+	m.Allow(accEventActivate, AccountStatusActive, AccountStatusInit)
+	m.Allow(accEventDeactivate, AccountStatusInit, AccountStatusActive)
+	// 登录锁定可以续期，管理员锁定不能降级成登录锁定
+	m.Allow(accEventLock, AccountStatusLocked, AccountStatusInit, AccountStatusActive, AccountStatusLocked)
+	m.Allow(accEventBlock, AccountStatusBlocked, AccountStatusInit, AccountStatusActive, AccountStatusLocked)
+	m.Allow(accEventUnlock, AccountStatusInit, AccountStatusLocked, AccountStatusBlocked)
+	m.Allow(accEventUnlock, AccountStatusActive, AccountStatusLocked, AccountStatusBlocked)
+	m.Allow(accEventUnRegister, AccountStatusUnRegister,
+		AccountStatusInit, AccountStatusActive, AccountStatusLocked, AccountStatusBlocked)
+	// 已经清除的 (DeleteAt) 不能恢复
+	notPurged := func(a *Account) bool { return a.DeleteAt == nil }
+	for _, to := range []model.Status{AccountStatusInit, AccountStatusActive, AccountStatusLocked, AccountStatusBlocked} {
+		m.Allow(accEventRestore, to, AccountStatusUnRegister).Guard(notPurged)
+	}
+	m.Allow(accEventBan, AccountStatusBanned,
+		AccountStatusUnRegister, AccountStatusBlocked, AccountStatusLocked, AccountStatusInit, AccountStatusActive)
+	return m
+}
+
+const (
+	authEventActivate = "activate" // 验证通过
+	authEventBind     = "bind"     // 绑定了账号
+	authEventUnbind   = "unbind"   // 账号都解绑了
+	authEventBlock    = "block"    // 封禁
+	authEventUnblock  = "unblock"  // 解封
+)
+
+func newAuthFSM() *fsm.Machine[model.Status, *Auth] {
+	m := fsm.New("auth",
+		func(a *Auth) model.Status { return a.Status },
+		func(a *Auth, status model.Status) { a.Status = status },
+	).
+		State(AuthStatusBlock, "block").
+		State(AuthStatusInit, "init").
+		State(AuthStatusActive, "active").
+		State(AuthStatusBind, "bind").
+		OnChange(func(a *Auth, from, to model.Status, event string) {
+			a.AddStatusChange(from, to, event)
+		})
+
+	m.Allow(authEventActivate, AuthStatusActive, AuthStatusInit)
+	m.Allow(authEventBind, AuthStatusBind, AuthStatusActive)
+	m.Allow(authEventUnbind, AuthStatusActive, AuthStatusBind)
+	m.Allow(authEventBlock, AuthStatusBlock, AuthStatusInit, AuthStatusActive, AuthStatusBind)
+	m.Allow(authEventUnblock, AuthStatusInit, AuthStatusBlock)
+	return m
+}
+
+func newVerifyFSM() *fsm.Machine[model.Status, *Verify] {
+	m := fsm.New("verify",
+		func(v *Verify) model.Status { return v.Status },
+		func(v *Verify, status model.Status) { v.Status = status },
+	).
+		State(VerifyStatusInit, "init").
+		State(VerifyStatusPending, "pending").
+		State(VerifyStatusReject, "reject").
+		State(VerifyStatusSuccess, "success").
+		OnChange(func(v *Verify, from, to model.Status, event string) {
+			v.AddStatusChange(from, to, event)
+		})
+
+	// 重发也是pending (包括验证成功后再发的)，重置验证结果
+	m.Allow("send", VerifyStatusPending,
+		VerifyStatusInit, VerifyStatusPending, VerifyStatusReject, VerifyStatusSuccess).
+		Do(func(v *Verify, _, _ model.Status, _ string) {
+			nowUnix := time.Now().Unix()
+			if (v.SendAt == nil) || (*v.SendAt > nowUnix) {
+				v.SendAt = &nowUnix
+			}
+			v.ValidAt = nil  // reset
+			v.ValidTimes = 0 // reset
+		})
+	validated := func(v *Verify, _, _ model.Status, _ string) {
+		nowUnix := time.Now().Unix()
+		v.ValidAt = &nowUnix
+		v.ValidTimes++
+	}
+	m.Allow("success", VerifyStatusSuccess, VerifyStatusPending, VerifyStatusReject).Do(validated)
+	m.Allow("reject", VerifyStatusReject, VerifyStatusPending, VerifyStatusReject).Do(validated)
+	return m
+}
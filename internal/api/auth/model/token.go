@@ -20,14 +20,16 @@ type (
 		DeviceID  string  `json:"deviceId" validate:"required"`          // 设备ID
 		AccountID uint64  `json:"accountId" validate:"required"`         // 账号ID
 
-		UserID *uint64 `json:"userId"` // 用户ID auths传过来的
-		RoleID *uint64 `json:"roleId"` // 角色ID TODO:GG account传过来的，可以传到token里面
+		UserID  *uint64  `json:"userId"`                        // 用户ID auths传过来的
+		Roles   []string `json:"roles" gorm:"serializer:json"`  // 角色 (签发时解析，写进token)
+		Scopes  []string `json:"scopes" gorm:"serializer:json"` // 粗粒度权限 (签发时解析，写进token)
+		RoleVer int64    `json:"roleVer"`                       // 签发时的账号角色版本
 		// TODO:GG 很多ID都要绑定token，方便获取，记得更新也要关联
 
 		AccessExpireAt  int64  `json:"accessExpireAt"`  // 访问token过期时间
 		RefreshExpireAt *int64 `json:"refreshExpireAt"` // 刷新token过期时间
 
-		Account *Account `json:"account"` // 账号信息
+		Account *Account `json:"account" gorm:"-"` // 账号信息
 	}
 )
 
@@ -39,17 +41,24 @@ func NewTokenEmpty() *Token {
 
 func NewToken(
 	ownKind OwnKind, ownID uint64, deviceID string, accountID uint64,
-	userID, roleID *uint64,
+	userID *uint64,
 ) *Token {
 	base := model.NewBase(make(map[string]any))
 	base.Status = model.StatusInit
 	return &Token{
 		Base:    base,
 		OwnKind: ownKind, OwnID: ownID, DeviceID: deviceID, AccountID: accountID,
-		UserID: userID, RoleID: roleID,
+		UserID: userID,
 	}
 }
 
+// SetRoles 设置角色/权限/角色版本 (生成之前)
+func (t *Token) SetRoles(roles, scopes []string, roleVer int64) {
+	t.Roles = roles
+	t.Scopes = scopes
+	t.RoleVer = roleVer
+}
+
 func (t *Token) ValidFieldRules() valid.FieldValidRules {
 	return valid.FieldValidRules{
 		valid.SceneAll: valid.FieldValidRule{
@@ -112,7 +121,8 @@ func (t *Token) Generate(
 	// 创建新的Access，生成JWT令牌 (传旧的token进去)
 	var accessToken *auth.Token
 	if accessExpireSec != 0 {
-		accessToken = auth.NewToken(int16(t.OwnKind), t.OwnID, t.AccountID, t.UserID, issuer, accessExpireSec)
+		accessToken = auth.NewToken(int16(t.OwnKind), t.OwnID, t.AccountID, t.UserID, issuer, accessExpireSec).
+			WithRoles(t.Roles, t.Scopes, t.RoleVer)
 		if err := accessToken.GenerateJWTTokens(jwtSecret, &t.AccessToken); err != nil {
 			return nil, nil, false
 		}
@@ -129,7 +139,8 @@ func (t *Token) Generate(
 	if refreshExpireHou != 0 {
 		// 刷新令牌通常比访问令牌有更长的有效期
 		refreshExpireSec := refreshExpireHou * 3600
-		refreshToken = auth.NewToken(int16(t.OwnKind), t.OwnID, t.AccountID, t.UserID, issuer, refreshExpireSec)
+		refreshToken = auth.NewToken(int16(t.OwnKind), t.OwnID, t.AccountID, t.UserID, issuer, refreshExpireSec).
+			WithRoles(t.Roles, t.Scopes, t.RoleVer)
 		if err := refreshToken.GenerateJWTTokens(jwtSecret, t.RefreshToken); err != nil {
 			return nil, nil, false
 		}
@@ -194,3 +205,44 @@ func (t *Token) IsRefreshExpired() bool {
 	}
 	return time.Now().Unix() > *t.RefreshExpireAt
 }
+
+const (
+	tokenExtraKeyLinks = "links" // 关联的token (SSO交换)，登出级联
+)
+
+// AddLink 关联token (SSO交换出来的/交换来源)
+func (t *Token) AddLink(id uint64) {
+	links, _ := t.GetLinks()
+	for _, link := range links {
+		if link == id {
+			return
+		}
+	}
+	links = append(links, id)
+	t.Extra.SetUint64Slice(tokenExtraKeyLinks, &links)
+}
+
+// ReplaceLink 关联的token换了 (刷新后ID变了)，没有关联old的返回false
+func (t *Token) ReplaceLink(old, id uint64) bool {
+	links, _ := t.GetLinks()
+	replaced := make([]uint64, 0, len(links))
+	found := false
+	for _, link := range links {
+		if link == old {
+			found = true
+			continue
+		} else if link != id {
+			replaced = append(replaced, link)
+		}
+	}
+	if !found {
+		return false
+	}
+	replaced = append(replaced, id)
+	t.Extra.SetUint64Slice(tokenExtraKeyLinks, &replaced)
+	return true
+}
+
+func (t *Token) GetLinks() ([]uint64, bool) {
+	return t.Extra.GetUint64Slice(tokenExtraKeyLinks)
+}
@@ -12,11 +12,11 @@ type (
 	// Verify 验证内容
 	Verify struct {
 		*model.Base
-		OwnKind  OwnKind     `json:"ownKind" validate:"required,range-own"`   // 验证平台 (组织/应用)
-		OwnID    uint64      `json:"ownId"`                                   // 认证拥有者Id (组织/应用)
-		AuthKind AuthKind    `json:"authKind" validate:"required,range-auth"` // 认证类型 (手机号/邮箱/...)
-		Apply    VerifyApply `json:"apply" validate:"required,range-apply"`   // 申请类型 (注册/登录/修改密码/...)
-		Target   []string    `json:"target" validate:"required"`              // 标识，用户名/手机号/邮箱/生物特征/第三方平台 // TODO:GG DB存{"cellphone:86_12345678901"}/{"email:address@domain"}
+		OwnKind  OwnKind     `json:"ownKind" validate:"required,range-own"`                       // 验证平台 (组织/应用)
+		OwnID    uint64      `json:"ownId"`                                                       // 认证拥有者Id (组织/应用)
+		AuthKind AuthKind    `json:"authKind" validate:"required,range-auth"`                     // 认证类型 (手机号/邮箱/...)
+		Apply    VerifyApply `json:"apply" validate:"required,range-apply"`                       // 申请类型 (注册/登录/修改密码/...)
+		Target   []string    `json:"target" validate:"required" gorm:"type:text;serializer:json"` // 标识，手机[code, number]/邮箱[username, domain]/生物特征/第三方平台
 
 		SendAt     *int64 `json:"sendAt"`     // 发送时间(发送成功时间)
 		ValidAt    *int64 `json:"validAt"`    // 验证时间
@@ -180,6 +180,17 @@ const (
 	VerifyApplyChangeThird VerifyApply = 7  // 修改第三方平台
 )
 
+// VerifyTarget 认证对应的验证码标识 (和发验证码时的target一致)，没有验证码的认证返回nil
+func VerifyTarget(iAuth IAuth) []string {
+	switch auth := iAuth.(type) {
+	case *AuthCellphone:
+		return []string{auth.Code, auth.Number}
+	case *AuthEmail:
+		return []string{auth.Username, auth.Domain}
+	}
+	return nil
+}
+
 // IsExpired 检查验证是否已过期
 func (v *Verify) IsExpired(expireSec int64) bool {
 	if v.SendAt == nil {
@@ -194,33 +205,24 @@ func (v *Verify) IsVerified() bool {
 	return v.Status == VerifyStatusSuccess && v.ValidAt != nil
 }
 
-func (v *Verify) SetPending() {
-	v.Status = VerifyStatusPending
-	nowUnix := time.Now().Unix()
-	if (v.SendAt == nil) || (*v.SendAt > nowUnix) {
-		v.SendAt = &nowUnix
-	}
-	v.ValidAt = nil  // reset
-	v.ValidTimes = 0 // reset
+// SetPending 发送成功，等待验证 (重发会重置验证结果)
+func (v *Verify) SetPending() bool {
+	return verifyFSM.Fire(v, VerifyStatusPending) == nil
 }
 
-func (v *Verify) SetSuccess() {
-	v.Status = VerifyStatusSuccess
-	nowUnix := time.Now().Unix()
-	v.ValidAt = &nowUnix
-	v.ValidTimes++
+// SetSuccess 验证成功
+func (v *Verify) SetSuccess() bool {
+	return verifyFSM.Fire(v, VerifyStatusSuccess) == nil
 }
 
-func (v *Verify) SetReject() {
-	v.Status = VerifyStatusReject
-	nowUnix := time.Now().Unix()
-	v.ValidAt = &nowUnix
-	v.ValidTimes++
+// SetReject 验证失败
+func (v *Verify) SetReject() bool {
+	return verifyFSM.Fire(v, VerifyStatusReject) == nil
 }
 
 // CanValid 检查是否可以验证
 func (v *Verify) CanValid(expireSec int64, maxValidTimes int) bool {
-	if v.Status < VerifyStatusPending || v.Status >= VerifyStatusSuccess {
+	if !verifyFSM.Can(v, VerifyStatusSuccess) {
 		return false
 	} else if v.IsExpired(expireSec) {
 		return false
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Access 访问记录仓储
+	Access struct {
+		*storage.Repo[model.Access]
+	}
+)
+
+func NewAccess() *Access {
+	return &Access{
+		Repo: storage.NewRepo(storage.TableAuthAccess, "访问记录", model.NewAccessEmpty,
+			storage.NewSort("createAt", storage.SortDesc), model.AccessSorts...),
+	}
+}
+
+// System 系统访问的仓储 (上下文里没有操作者时不限制数据范围)
+func (sto *Access) System() *Access {
+	return &Access{
+		Repo: sto.Repo.System(),
+	}
+}
+
+// WithScope 带数据范围的仓储 (查询只返回范围里的)
+func (sto *Access) WithScope(scope *storage.Scope) *Access {
+	return &Access{
+		Repo: sto.Repo.WithScope(scope),
+	}
+}
+
+// Inserts 批量添加访问记录
+func (sto *Access) Inserts(beans []*model.Access, batchSize int) *errs.CodeErrs {
+	if len(beans) <= 0 {
+		return nil
+	}
+	result := sto.Psql().Table(string(storage.TableAuthAccess)).CreateInBatches(beans, batchSize)
+	if result.Error != nil {
+		log.Error("DB_批量添加访问记录", log.FInt("count", len(beans)), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// SelectsPageByAccount 根据账号+时间范围分页查询访问记录
+func (sto *Access) SelectsPageByAccount(
+	accountID uint64, kinds []model.AccessKind,
+	startAt, endAt int64, sort *storage.Sort, page, size int,
+) (*storage.List[model.Access], *errs.CodeErrs) {
+	return sto.SelectsPage(sto.filter(accountID, kinds, startAt, endAt), sort, page, size)
+}
+
+// SelectsByAccount 查询账号最近的访问记录 (时间倒序)
+func (sto *Access) SelectsByAccount(accountID uint64, kinds []model.AccessKind, limit int) ([]*model.Access, *errs.CodeErrs) {
+	return sto.Selects(sto.filter(accountID, kinds, 0, 0), nil, limit)
+}
+
+// SelectsCursorByAccount 游标分页查询账号的访问记录 (id倒序)
+func (sto *Access) SelectsCursorByAccount(accountID uint64, cursor uint64, size int) (*storage.List[model.Access], *errs.CodeErrs) {
+	return sto.SelectsCursor(sto.filter(accountID, nil, 0, 0), cursor, size)
+}
+
+func (sto *Access) filter(
+	accountID uint64, kinds []model.AccessKind,
+	startAt, endAt int64,
+) *storage.Filter {
+	return storage.NewFilter().
+		Eq("account_id", accountID).
+		In("kind", kinds).
+		Gte("create_at", startAt).
+		Lt("create_at", endAt)
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"gorm.io/gorm"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
@@ -10,39 +12,190 @@ import (
 type (
 	// Account 账号仓储
 	Account struct {
-		*storage.Base
+		*storage.Repo[model.Account]
 	}
 )
 
 func NewAccount() *Account {
 	return &Account{
-		Base: storage.NewBase(),
+		Repo: storage.NewRepo(storage.TableAuthAccount, "账号", model.NewAccountEmpty, nil).Versioned(),
 	}
 }
 
-func (sto *Account) Insert(bean *model.Account) *errs.CodeErrs {
-	// TODO:GG 雪花ID 10+9 or 分布ID 5+14
-	//sto.W.Create(&Account{})
-	log.Debug("DB_添加账号", log.FAny("account", bean))
-	return nil
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Account) WithContext(ctx context.Context) *Account {
+	return &Account{
+		Repo: sto.Repo.WithContext(ctx),
+	}
 }
 
-func (sto *Account) Delete(id uint64, deleteBy *uint64) *errs.CodeErrs {
-	return nil
+// System 系统访问的仓储 (上下文里没有操作者时不限制数据范围)
+func (sto *Account) System() *Account {
+	return &Account{
+		Repo: sto.Repo.System(),
+	}
 }
 
-func (sto *Account) Update(bean *model.Account) *errs.CodeErrs {
-	return nil
+// WithScope 带数据范围的仓储 (查询/修改只作用于范围里的)
+func (sto *Account) WithScope(scope *storage.Scope) *Account {
+	return &Account{
+		Repo: sto.Repo.WithScope(scope),
+	}
+}
+
+// SelectByOwnAuth 查询own下绑定了认证的账号 (含注销冷静期的，注册查重用)，没有返回nil
+func (sto *Account) SelectByOwnAuth(ownKind model.OwnKind, ownID uint64, iAuth model.IAuth) (*model.Account, *errs.CodeErrs) {
+	target, args := targetCond("t.", iAuth)
+	var beans []*model.Account
+	result := sto.Psql().Table(string(storage.TableAuthAccount)+" AS a").
+		Select("a.*").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.account_id = a.id").
+		Joins("JOIN "+string(storage.TableAuthAuth)+" AS t ON t.id = aa.auth_id").
+		Where(target, args...).
+		Where("t.delete_at IS NULL").
+		Where("a.own_kind = ? AND a.own_id = ?", ownKind, ownID).
+		Where("a.delete_at IS NULL").
+		Order("a.id").
+		Limit(1).
+		Find(&beans)
+	if result.Error != nil {
+		log.Error("DB_own认证账号", log.FInt16("ownKind", int16(ownKind)), log.FUint64("ownId", ownID), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	} else if len(beans) <= 0 {
+		return nil, nil
+	}
+	return beans[0], nil
 }
 
-func (sto *Account) Select(bean *model.Account) (*model.Account, *errs.CodeErrs) {
-	return nil, nil
+// SelectByOwnAuths 查询own下，绑定了这些认证之一的账号
+func (sto *Account) SelectByOwnAuths(ownKind model.OwnKind, ownID uint64, auths []model.IAuth) (*model.Account, *errs.CodeErrs) {
+	if len(auths) <= 0 {
+		return nil, nil
+	}
+	targets := sto.Psql()
+	for _, iAuth := range auths {
+		target, args := targetCond("t.", iAuth)
+		targets = targets.Or(target, args...)
+	}
+	var beans []*model.Account
+	result := sto.Psql().Table(string(storage.TableAuthAccount)+" AS a").
+		Select("a.*").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.account_id = a.id").
+		Joins("JOIN "+string(storage.TableAuthAuth)+" AS t ON t.id = aa.auth_id").
+		Where(targets).
+		Where("t.delete_at IS NULL").
+		Where("a.own_kind = ? AND a.own_id = ?", ownKind, ownID).
+		Where("a.status > ?", model.AccountStatusUnRegister).
+		Where("a.delete_at IS NULL").
+		Order("a.id").
+		Limit(1).
+		Find(&beans)
+	if result.Error != nil {
+		log.Error("DB_own认证账号", log.FInt16("ownKind", int16(ownKind)), log.FUint64("ownId", ownID), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	} else if len(beans) <= 0 {
+		return nil, nil
+	}
+	return beans[0], nil
 }
 
-func (sto *Account) Selects(bean *model.Account) ([]*model.Account, *errs.CodeErrs) {
-	return nil, nil
+// SelectsPurge 查询冷静期已过的注销账号 (purgeAt <= now)
+func (sto *Account) SelectsPurge(now int64, limit int) ([]*model.Account, *errs.CodeErrs) {
+	var beans []*model.Account
+	result := sto.Psql().Table(string(storage.TableAuthAccount)).
+		Where("status = ?", model.AccountStatusUnRegister).
+		Where("delete_at IS NULL").
+		Where("(extra->>'purgeAt')::bigint <= ?", now).
+		Order("id").
+		Limit(limit).
+		Find(&beans)
+	if result.Error != nil {
+		log.Error("DB_查询待清除账号", log.FInt64("now", now), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
 }
 
-func (sto *Account) SelectCount(bean *model.Account) (int, *errs.CodeErrs) {
-	return 1, nil
+// SelectCountByAuth 查询绑定了认证的账号数 (owns里的，排除excludeID)
+func (sto *Account) SelectCountByAuth(iAuth model.IAuth, owns []model.Own, excludeID uint64) (int, *errs.CodeErrs) {
+	var count int64
+	target, args := targetCond("t.", iAuth)
+	query := sto.Psql().Table(string(storage.TableAuthAccount)+" AS a").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.account_id = a.id").
+		Joins("JOIN "+string(storage.TableAuthAuth)+" AS t ON t.id = aa.auth_id").
+		Where(target, args...).
+		Where("t.delete_at IS NULL").
+		Where("a.id <> ?", excludeID).
+		Where("a.status > ?", model.AccountStatusUnRegister).
+		Where("a.delete_at IS NULL")
+	result := sto.whereOwns(query, "a.", owns).Distinct("a.id").Count(&count)
+	if result.Error != nil {
+		log.Error("DB_认证账号数", log.FString("target", iAuth.GetTarget()), log.FError(result.Error))
+		return 0, storage.TranslateErr(result.Error)
+	}
+	return int(count), nil
+}
+
+// SelectCountByUser 查询用户的账号数 (owns里的，排除excludeID)
+func (sto *Account) SelectCountByUser(userID uint64, owns []model.Own, excludeID uint64) (int, *errs.CodeErrs) {
+	var count int64
+	query := sto.Psql().Table(string(storage.TableAuthAccount)).
+		Where("user_id = ?", userID).
+		Where("id <> ?", excludeID).
+		Where("status > ?", model.AccountStatusUnRegister).
+		Where("delete_at IS NULL")
+	result := sto.whereOwns(query, "", owns).Count(&count)
+	if result.Error != nil {
+		return 0, storage.TranslateErr(result.Error)
+	}
+	return int(count), nil
+}
+
+// whereOwns 账号的own在owns里 (prefix是表别名)，owns为空不加条件
+func (sto *Account) whereOwns(query *gorm.DB, prefix string, owns []model.Own) *gorm.DB {
+	if len(owns) <= 0 {
+		return query
+	}
+	scope := sto.Psql()
+	for _, own := range owns {
+		scope = scope.Or(prefix+"own_kind = ? AND "+prefix+"own_id = ?", own.Kind, own.ID)
+	}
+	return query.Where(scope)
+}
+
+// SelectsByUser 查询用户关联的账号 (所有own，不含注销的)
+func (sto *Account) SelectsByUser(userID uint64) ([]*model.Account, *errs.CodeErrs) {
+	var beans []*model.Account
+	result := sto.Psql().Table(string(storage.TableAuthAccount)).
+		Where("user_id = ?", userID).
+		Where("status > ?", model.AccountStatusUnRegister).
+		Where("delete_at IS NULL").
+		Order("id").
+		Find(&beans)
+	if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
+}
+
+// LockQuota 事务级咨询锁 (同key串行)，fn里完成检查+写入 (仓储用ctx绑定事务)，fn返回错误时回滚
+// 已经在事务里的嵌套成savepoint，锁持有到最外层事务结束
+func (sto *Account) LockQuota(key string, fn func(ctx context.Context) *errs.CodeErrs) *errs.CodeErrs {
+	var cErr *errs.CodeErrs
+	err := storage.Transaction(sto.Context(), func(ctx context.Context) error {
+		if e := storage.TxFrom(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; e != nil {
+			return e
+		}
+		if cErr = fn(ctx); cErr != nil {
+			return cErr
+		}
+		return nil
+	})
+	if cErr != nil {
+		return cErr
+	} else if err != nil {
+		log.Error("DB_配额加锁", log.FString("key", key), log.FError(err))
+		return storage.TranslateErr(err)
+	}
+	return nil
 }
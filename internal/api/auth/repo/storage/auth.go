@@ -1,51 +1,180 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
 )
 
 type (
-	// Auth 认证仓储
+	// Auth 认证仓储 (一张表存所有类型，查询时按kind还原成具体类型)
 	Auth struct {
-		*storage.Base
+		*storage.Repo[model.Auth]
+	}
+
+	// authKindRow 认证的ID+类型 (先查类型，再按类型查具体的)
+	authKindRow struct {
+		ID   uint64
+		Kind model.AuthKind
 	}
 )
 
 func NewAuth() *Auth {
 	return &Auth{
-		Base: storage.NewBase(),
+		Repo: storage.NewRepo(storage.TableAuthAuth, "认证", model.NewAuthEmpty, nil).Versioned(),
 	}
 }
 
-func (sto *Auth) Insert(bean model.IAuth) *errs.CodeErrs {
-	// TODO:GG 雪花ID 10+9 or 分布ID 5+14
-	//sto.W.Create(&Auth{})
-	return nil
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Auth) WithContext(ctx context.Context) *Auth {
+	return &Auth{
+		Repo: sto.Repo.WithContext(ctx),
+	}
 }
 
-func (sto *Auth) Delete(id uint64, deleteBy *uint64) *errs.CodeErrs {
-	return nil
+// Insert 添加 (具体类型)，添加前的状态变更 (例如直接激活) 同时写状态历史
+func (sto *Auth) Insert(bean model.IAuth) *errs.CodeErrs {
+	return sto.InsertHistory(storage.TableAuthAuth, bean)
 }
 
+// Update 修改 (乐观锁，版本不对返回冲突错误)
 func (sto *Auth) Update(bean model.IAuth) *errs.CodeErrs {
-	return nil
+	return sto.UpdateVersion(storage.TableAuthAuth, bean)
 }
 
-func (sto *Auth) Select(bean model.IAuth) (model.IAuth, *errs.CodeErrs) {
-	return nil, nil
+// Select 根据类型+标识查询认证 (带上绑定的账号)，没有返回nil
+// 密码的用户名只在own里唯一，要用SelectByOwn
+func (sto *Auth) Select(param model.IAuth) (model.IAuth, *errs.CodeErrs) {
+	if param == nil {
+		return nil, nil
+	}
+	query, args := targetCond("", param)
+	return sto.take(param.GetKind(), sto.Psql().Table(string(storage.TableAuthAuth)).
+		Where(query, args...).
+		Where("delete_at IS NULL"))
 }
 
-func (sto *Auth) Selects(bean model.IAuth) ([]model.IAuth, *errs.CodeErrs) {
-	return nil, nil
+// SelectByOwn 查询own下的账号绑定的认证 (含注销冷静期的账号，用户名查重用)，没有返回nil
+func (sto *Auth) SelectByOwn(ownKind model.OwnKind, ownID uint64, param model.IAuth) (model.IAuth, *errs.CodeErrs) {
+	query, args := targetCond("t.", param)
+	return sto.take(param.GetKind(), sto.Psql().Table(string(storage.TableAuthAuth)+" AS t").
+		Select("DISTINCT t.*").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.auth_id = t.id").
+		Joins("JOIN "+string(storage.TableAuthAccount)+" AS a ON a.id = aa.account_id").
+		Where(query, args...).
+		Where("t.delete_at IS NULL").
+		Where("a.own_kind = ? AND a.own_id = ?", ownKind, ownID).
+		Where("a.delete_at IS NULL"))
 }
 
-func (sto *Auth) SelectCount(bean model.IAuth) (int, *errs.CodeErrs) {
-	return 1, nil
+// SelectByAccount 查询账号绑定的某类认证 (带上绑定的账号)，没有返回nil
+func (sto *Auth) SelectByAccount(accountID uint64, kind model.AuthKind) (model.IAuth, *errs.CodeErrs) {
+	return sto.take(kind, sto.Psql().Table(string(storage.TableAuthAuth)+" AS t").
+		Select("t.*").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.auth_id = t.id").
+		Where("aa.account_id = ? AND t.kind = ?", accountID, kind).
+		Where("t.delete_at IS NULL"))
 }
 
-func (sto *Auth) Login() {
-	// TODO:GG 登录的时候，也是先看有没有当前的account，有就校验密码/验证码，没有就校验share的(激活其他平台)
+// SelectsByAccount 查询账号绑定的认证 (多对多表，带上绑定的账号)
+func (sto *Auth) SelectsByAccount(accountID uint64) ([]model.IAuth, *errs.CodeErrs) {
+	var rows []*authKindRow
+	result := sto.Psql().Table(string(storage.TableAuthAuth)+" AS t").
+		Select("t.id, t.kind").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.auth_id = t.id").
+		Where("aa.account_id = ?", accountID).
+		Where("t.delete_at IS NULL").
+		Order("t.id").
+		Find(&rows)
+	if result.Error != nil {
+		log.Error("DB_账号认证", log.FUint64("accountId", accountID), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	}
+	list := make([]model.IAuth, 0, len(rows))
+	for _, row := range rows {
+		iAuth, err := sto.take(row.Kind, sto.Psql().Table(string(storage.TableAuthAuth)).Where("id = ?", row.ID))
+		if err != nil {
+			return nil, err
+		} else if iAuth != nil {
+			list = append(list, iAuth)
+		}
+	}
+	return list, nil
+}
 
+// Bind 账号绑定认证 (多对多表，已经绑定的不报错)
+func (sto *Auth) Bind(accountID, authID uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthAccountAuth)).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.AccountAuth{AccountID: accountID, AuthID: authID})
+	if result.Error != nil {
+		log.Error("DB_绑定认证", log.FUint64("accountId", accountID), log.FUint64("authId", authID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// Unbind 账号解绑认证 (多对多表)
+func (sto *Auth) Unbind(accountID, authID uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthAccountAuth)).
+		Where("account_id = ? AND auth_id = ?", accountID, authID).
+		Delete(&model.AccountAuth{})
+	if result.Error != nil {
+		log.Error("DB_解绑认证", log.FUint64("accountId", accountID), log.FUint64("authId", authID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// take 按类型查询一条具体的认证，再带上绑定的账号
+func (sto *Auth) take(kind model.AuthKind, query *gorm.DB) (model.IAuth, *errs.CodeErrs) {
+	bean, ok := model.NewAuthByKind(kind)
+	if !ok {
+		return nil, nil
+	}
+	result := query.Take(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		log.Error("DB_查询认证", log.FInt16("kind", int16(kind)), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, sto.loadAccounts(bean)
+}
+
+// loadAccounts 带上认证绑定的账号 (不含删除的)
+func (sto *Auth) loadAccounts(bean model.IAuth) *errs.CodeErrs {
+	var accounts []*model.Account
+	result := sto.Psql().Table(string(storage.TableAuthAccount)+" AS a").
+		Select("a.*").
+		Joins("JOIN "+string(storage.TableAuthAccountAuth)+" AS aa ON aa.account_id = a.id").
+		Where("aa.auth_id = ?", bean.GetID()).
+		Where("a.delete_at IS NULL").
+		Find(&accounts)
+	if result.Error != nil {
+		log.Error("DB_认证账号", log.FUint64("authId", bean.GetID()), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	for _, account := range accounts {
+		bean.SetAccount(account)
+	}
+	return nil
+}
+
+// targetCond 认证标识的查询条件 (标识是按类型的列算出来的，表里没有target列)，prefix是表别名
+func targetCond(prefix string, iAuth model.IAuth) (string, []any) {
+	switch auth := iAuth.(type) {
+	case *model.AuthPassword:
+		return prefix + "kind = ? AND " + prefix + "username = ?", []any{auth.GetKind(), auth.GetTarget()}
+	case *model.AuthCellphone:
+		return prefix + "kind = ? AND " + prefix + "code = ? AND " + prefix + "number = ?", []any{auth.GetKind(), auth.Code, auth.Number}
+	case *model.AuthEmail:
+		return prefix + "kind = ? AND " + prefix + "username = ? AND " + prefix + "domain = ?", []any{auth.GetKind(), auth.Username, auth.Domain}
+	}
+	return "1 = 0", nil
 }
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Export 数据导出仓储
+	Export struct {
+		*storage.Base
+	}
+)
+
+func NewExport() *Export {
+	return &Export{
+		Base: storage.NewBase(nil),
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Export) WithContext(ctx context.Context) *Export {
+	return &Export{
+		Base: sto.Base.WithContext(ctx),
+	}
+}
+
+func (sto *Export) Insert(bean *model.Export) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthExport)).Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加数据导出", log.FUint64("accountId", bean.AccountID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *Export) Update(bean *model.Export) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthExport)).Save(bean)
+	if result.Error != nil {
+		log.Error("DB_修改数据导出", log.FUint64("id", bean.ID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *Export) SelectByID(id uint64) (*model.Export, *errs.CodeErrs) {
+	bean := model.NewExportEmpty()
+	result := sto.Psql().Table(string(storage.TableAuthExport)).
+		Where("id = ?", id).
+		Where("delete_at IS NULL").
+		First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// SelectRunning 查询账号排队/打包中的导出 (since之后申请的，更早的当作中断了)，没有返回nil
+func (sto *Export) SelectRunning(accountID uint64, since int64) (*model.Export, *errs.CodeErrs) {
+	bean := model.NewExportEmpty()
+	result := sto.Psql().Table(string(storage.TableAuthExport)).
+		Where("account_id = ?", accountID).
+		Where("status IN ?", []any{model.ExportStatusInit, model.ExportStatusRunning}).
+		Where("create_at >= ?", since).
+		Where("delete_at IS NULL").
+		Order("id DESC").
+		Take(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		log.Error("DB_查询进行中的数据导出", log.FUint64("accountId", accountID), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// SelectsExpired 查询下载已过期、文件还没清理的导出
+func (sto *Export) SelectsExpired(now int64, limit int) ([]*model.Export, *errs.CodeErrs) {
+	var beans []*model.Export
+	result := sto.Psql().Table(string(storage.TableAuthExport)).
+		Where("status = ?", model.ExportStatusDone).
+		Where("expire_at <= ?", now).
+		Where("file_path <> ''").
+		Where("delete_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&beans)
+	if result.Error != nil {
+		log.Error("DB_查询过期的数据导出", log.FInt64("now", now), log.FError(result.Error))
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"gorm.io/gorm/clause"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Nickname 昵称占用仓储
+	Nickname struct {
+		*storage.Repo[model.Nickname]
+	}
+)
+
+func NewNickname() *Nickname {
+	return &Nickname{
+		Repo: storage.NewRepo(storage.TableAuthNickname, "昵称", model.NewNicknameEmpty, nil),
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Nickname) WithContext(ctx context.Context) *Nickname {
+	return &Nickname{
+		Repo: sto.Repo.WithContext(ctx),
+	}
+}
+
+// Reserve 占用昵称，已被占用返回false (唯一索引冲突不报错)
+func (sto *Nickname) Reserve(bean *model.Nickname) (bool, *errs.CodeErrs) {
+	result := sto.Psql().Table(string(storage.TableAuthNickname)).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(bean)
+	if result.Error != nil {
+		log.Error("DB_占用昵称", log.FUint64("accountId", bean.AccountID), log.FError(result.Error))
+		return false, storage.TranslateErr(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SelectByNormal 查询昵称的占用
+func (sto *Nickname) SelectByNormal(ownKind model.OwnKind, ownID uint64, normal string) (*model.Nickname, *errs.CodeErrs) {
+	return sto.Select(storage.NewFilter().
+		Eq("own_kind", ownKind).
+		Eq("own_id", ownID).
+		Eq("normal", normal))
+}
+
+// ReleaseByAccount 释放账号占用的昵称 (保留keep)，直接删除 (唯一索引，软删除的还会占着)
+func (sto *Nickname) ReleaseByAccount(ownKind model.OwnKind, ownID uint64, accountID uint64, keep string) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthNickname)).
+		Where("own_kind = ? AND own_id = ? AND account_id = ?", ownKind, ownID, accountID).
+		Where("normal <> ?", keep).
+		Delete(model.NewNicknameEmpty())
+	if result.Error != nil {
+		log.Error("DB_释放昵称", log.FUint64("accountId", accountID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
@@ -1,42 +1,65 @@
 package storage
 
 import (
+	"context"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"time"
 )
 
 type (
-	// Auth 认证仓储
+	// Token 令牌仓储
 	Token struct {
-		*storage.Base
+		*storage.Repo[model.Token]
 	}
 )
 
 func NewToken() *Token {
 	return &Token{
-		Base: storage.NewBase(),
+		Repo: storage.NewRepo(storage.TableAuthToken, "令牌", model.NewTokenEmpty, nil),
 	}
 }
 
-func (sto *Token) Insert(bean model.Token) *errs.CodeErrs {
-	// TODO:GG 雪花ID 10+9 or 分布ID 5+14
-	//sto.W.Create(&Token{})
-	return nil
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Token) WithContext(ctx context.Context) *Token {
+	return &Token{
+		Repo: sto.Repo.WithContext(ctx),
+	}
 }
 
-func (sto *Token) Delete(id uint64, deleteBy *uint64) *errs.CodeErrs {
-	return nil
+// System 系统访问的仓储 (上下文里没有操作者时不限制数据范围)
+func (sto *Token) System() *Token {
+	return &Token{
+		Repo: sto.Repo.System(),
+	}
 }
 
-func (sto *Token) Update(bean model.Token) *errs.CodeErrs {
+// DeleteByAccount 删除账号下的所有token (吊销)
+func (sto *Token) DeleteByAccount(accountID uint64, deleteBy int64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableAuthToken)).
+		Where("account_id = ?", accountID).
+		Where("delete_at IS NULL").
+		Updates(map[string]any{"delete_at": time.Now().UnixMilli(), "delete_by": deleteBy})
+	if result.Error != nil {
+		log.Error("DB_吊销账号令牌", log.FUint64("accountId", accountID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
 	return nil
 }
 
-func (sto *Token) Select(bean model.Token) (*model.Token, *errs.CodeErrs) {
-	return nil, nil
+// SelectByAccess 根据访问token查询，没有返回nil
+func (sto *Token) SelectByAccess(accessToken string) (*model.Token, *errs.CodeErrs) {
+	return sto.Select(storage.NewFilter().Eq("access_token", accessToken))
+}
+
+// SelectByRefresh 根据刷新token查询，没有返回nil
+func (sto *Token) SelectByRefresh(refreshToken string) (*model.Token, *errs.CodeErrs) {
+	return sto.Select(storage.NewFilter().Eq("refresh_token", refreshToken))
 }
 
-func (sto *Token) Selects(bean model.Token) ([]*model.Token, *errs.CodeErrs) {
-	return nil, nil
+// SelectsByAccount 查询账号下的所有token (会话)
+func (sto *Token) SelectsByAccount(accountID uint64) ([]*model.Token, *errs.CodeErrs) {
+	return sto.Selects(storage.NewFilter().Eq("account_id", accountID), nil, 0)
 }
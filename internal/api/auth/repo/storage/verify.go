@@ -1,51 +1,94 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
-	"katydid-mp-user/pkg/log"
 )
 
 type (
 	// Verify 验证码仓储
 	Verify struct {
-		*storage.Base
+		*storage.Repo[model.Verify]
 	}
 )
 
 func NewVerify() *Verify {
 	return &Verify{
-		Base: storage.NewBase(),
+		Repo: storage.NewRepo(storage.TableAuthVerify, "验证", model.NewVerifyEmpty,
+			storage.NewSort("createAt", storage.SortDesc)).Versioned(),
 	}
 }
 
-func (sto *Verify) Insert(bean *model.Verify) *errs.CodeErrs {
-	// TODO:GG 雪花ID 10+9 or 分布ID 5+14
-	//d.W.Create(&Verify{})
-	log.Debug("DB_添加验证", log.FAny("verify", bean))
-	return nil
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Verify) WithContext(ctx context.Context) *Verify {
+	return &Verify{
+		Repo: sto.Repo.WithContext(ctx),
+	}
 }
 
-func (sto *Verify) Delete(id uint64, deleteBy *uint64) *errs.CodeErrs {
-	log.Debug("DB_删除验证", log.FAny("verify", id))
-	return nil
+// SelectLast 查询认证目标最近的验证码 (同own+认证类型+申请类型)，没有返回nil
+func (sto *Verify) SelectLast(
+	ownKind model.OwnKind, ownID uint64, authKind model.AuthKind,
+	apply model.VerifyApply, target []string,
+) (*model.Verify, *errs.CodeErrs) {
+	list, err := sto.Selects(sto.filter(ownKind, ownID, authKind, target).Eq("apply", apply), nil, 1)
+	if (err != nil) || (len(list) <= 0) {
+		return nil, err
+	}
+	return list[0], nil
 }
 
-func (sto *Verify) Update(bean *model.Verify) *errs.CodeErrs {
-	log.Debug("DB_修改验证", log.FAny("verify", bean))
-	return nil
+// SelectLastSuccess 查询认证目标最近验证成功的验证码 (所有own/申请类型)，没有返回nil
+func (sto *Verify) SelectLastSuccess(authKind model.AuthKind, target []string) (*model.Verify, *errs.CodeErrs) {
+	filter := storage.NewFilter().
+		Eq("auth_kind", authKind).
+		Eq("target", targetValue(target)).
+		Eq("status", model.VerifyStatusSuccess)
+	list, err := sto.Selects(filter, nil, 1)
+	if (err != nil) || (len(list) <= 0) {
+		return nil, err
+	}
+	return list[0], nil
 }
 
-func (sto *Verify) Select(bean *model.Verify) (*model.Verify, *errs.CodeErrs) {
-	log.Debug("DB_获取验证", log.FAny("verify", bean))
-	return bean, nil
+// SelectCountSince 查询认证目标从since(ms)开始添加的验证码数量
+func (sto *Verify) SelectCountSince(
+	ownKind model.OwnKind, ownID uint64, authKind model.AuthKind,
+	apply model.VerifyApply, target []string, since int64,
+) (int64, *errs.CodeErrs) {
+	return sto.SelectCount(sto.filter(ownKind, ownID, authKind, target).Eq("apply", apply).Gte("create_at", since))
+}
+
+// SelectsByAuths 查询认证目标的验证记录 (时间倒序)
+func (sto *Verify) SelectsByAuths(ownKind model.OwnKind, ownID uint64, auths []model.IAuth) ([]*model.Verify, *errs.CodeErrs) {
+	list := make([]*model.Verify, 0)
+	for _, iAuth := range auths {
+		target := model.VerifyTarget(iAuth)
+		if len(target) <= 0 {
+			continue // 密码之类的没有验证码
+		}
+		verifies, err := sto.Selects(sto.filter(ownKind, ownID, iAuth.GetKind(), target), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, verifies...)
+	}
+	return list, nil
 }
 
-func (sto *Verify) Selects(bean *model.Verify) ([]*model.Verify, *errs.CodeErrs) {
-	return nil, nil
+func (sto *Verify) filter(ownKind model.OwnKind, ownID uint64, authKind model.AuthKind, target []string) *storage.Filter {
+	return storage.NewFilter().
+		Eq("own_kind", ownKind).
+		Eq("own_id", ownID).
+		Eq("auth_kind", authKind).
+		Eq("target", targetValue(target))
 }
 
-func (sto *Verify) SelectCount(bean *model.Verify) (int, *errs.CodeErrs) {
-	return 0, nil
+// targetValue 验证码标识在表里的值 (和serializer:json存的一致)
+func targetValue(target []string) string {
+	bytes, _ := json.Marshal(target)
+	return string(bytes)
 }
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/service"
+	pkgStorage "katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"sync"
+	"time"
+)
+
+const (
+	dataAccess = pkgStorage.DataResAccess // 访问记录的数据范围资源
+
+	accessBatchSizeDef     = 200             // 默认批量写入数量
+	accessFlushIntervalDef = 3 * time.Second // 默认批量写入间隔
+	accessQueueSizeDef     = 10_000          // 默认队列长度
+)
+
+type (
+	// Access 访问记录服务
+	Access struct {
+		*service.Base
+
+		dbs        *storage.Access
+		dbsAccount *storage.Account
+
+		recorder *AccessRecorder
+	}
+
+	// AccessRecorder 访问记录异步批量写入器
+	AccessRecorder struct {
+		dbs *storage.Access
+
+		batchSize     int                // 单批写入数量
+		flushInterval time.Duration      // 写入间隔
+		queue         chan *model.Access // 待写入队列
+
+		stopCh chan struct{}
+		doneCh chan struct{}
+		once   sync.Once
+	}
+)
+
+func NewAccess(db *storage.Access, dbAccount *storage.Account, recorder *AccessRecorder) *Access {
+	return &Access{
+		Base:       service.NewBase(nil),
+		dbs:        db.System(), // 按操作者的查询都指定了范围 (WithScope)
+		dbsAccount: dbAccount.System(),
+		recorder:   recorder,
+	}
+}
+
+// NewAccessRecorder 创建访问记录写入器 (需要Start)
+func NewAccessRecorder(
+	db *storage.Access,
+	batchSize, queueSize int, flushInterval time.Duration,
+) *AccessRecorder {
+	if batchSize <= 0 {
+		batchSize = accessBatchSizeDef
+	}
+	if queueSize <= 0 {
+		queueSize = accessQueueSizeDef
+	}
+	if flushInterval <= 0 {
+		flushInterval = accessFlushIntervalDef
+	}
+	return &AccessRecorder{
+		dbs:           db,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan *model.Access, queueSize),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+// Record 记录访问 (异步)
+func (svc *Access) Record(access *model.Access) {
+	if svc.recorder == nil {
+		return
+	}
+	svc.recorder.Add(access)
+}
+
+// Allow 操作者能不能查账号的访问记录 (自己的，或者有own/all数据权限的)
+func (svc *Access) Allow(ctx *service.Ctx, accountID uint64) (bool, *errs.CodeErrs) {
+	scope := ctx.DataScope(dataAccess)
+	switch scope.Kind {
+	case pkgStorage.DataScopeAll:
+		return true, nil
+	case pkgStorage.DataScopeSelf:
+		return scope.Allow(0, 0, accountID), nil
+	case pkgStorage.DataScopeOwn:
+		account, err := svc.dbsAccount.SelectByID(accountID)
+		if (err != nil) || (account == nil) {
+			return false, err
+		}
+		return scope.Allow(int16(account.OwnKind), account.OwnID, account.ID), nil
+	}
+	return false, nil
+}
+
+// Histories 分页查询账号的访问历史 (时间范围ms，左闭右开)，只能查操作者数据范围里的
+func (svc *Access) Histories(
+	ctx *service.Ctx,
+	accountID uint64, kinds []model.AccessKind,
+	startAt, endAt int64, sortBy, sortOrder string, page, size int,
+) (*pkgStorage.List[model.Access], *errs.CodeErrs) {
+	if (startAt > 0) && (endAt > 0) && (startAt >= endAt) {
+		return nil, errs.Match2("查询时间范围错误")
+	}
+	dbs := svc.dbs.WithScope(ctx.DataScope(dataAccess))
+	sort := pkgStorage.NewSort(sortBy, sortOrder)
+	return dbs.SelectsPageByAccount(accountID, kinds, startAt, endAt, sort, page, size)
+}
+
+// FillHistories 填充账号最近的访问历史
+func (svc *Access) FillHistories(account *model.Account, size int) *errs.CodeErrs {
+	list, err := svc.dbs.SelectsByAccount(account.ID, nil, size)
+	if err != nil {
+		return err
+	}
+	account.AddHistory(list...)
+	return nil
+}
+
+// Start 启动写入协程
+func (r *AccessRecorder) Start() {
+	go r.loop()
+}
+
+// Stop 停止写入，并把队列里剩余的记录写完
+func (r *AccessRecorder) Stop() {
+	r.once.Do(func() {
+		close(r.stopCh)
+		<-r.doneCh
+	})
+}
+
+// Add 添加访问记录，队列满了直接丢弃 (访问记录不能阻塞业务)
+func (r *AccessRecorder) Add(access *model.Access) {
+	if access == nil {
+		return
+	}
+	select {
+	case r.queue <- access:
+	default:
+		log.Warn("■ ■ Access ■ ■ 队列已满，丢弃记录",
+			log.FUint64("accountId", access.AccountID),
+			log.FInt8("kind", int8(access.Kind)),
+		)
+	}
+}
+
+func (r *AccessRecorder) loop() {
+	defer close(r.doneCh)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.Access, 0, r.batchSize)
+	for {
+		select {
+		case access := <-r.queue:
+			batch = append(batch, access)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stopCh:
+			// 写完剩余的
+			for {
+				select {
+				case access := <-r.queue:
+					batch = append(batch, access)
+					if len(batch) >= r.batchSize {
+						batch = r.flush(batch)
+					}
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *AccessRecorder) flush(batch []*model.Access) []*model.Access {
+	if len(batch) <= 0 {
+		return batch
+	}
+	if err := r.dbs.Inserts(batch, r.batchSize); err != nil {
+		log.Error("■ ■ Access ■ ■ 批量写入失败", log.FInt("count", len(batch)), log.FError(err))
+	}
+	return batch[:0]
+}
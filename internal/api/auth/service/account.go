@@ -1,12 +1,23 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
 	"katydid-mp-user/internal/pkg/service"
+	pkgStorage "katydid-mp-user/internal/pkg/storage"
 	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/words"
+	"slices"
 	"strconv"
+	"time"
+)
+
+const (
+	dataAccount = pkgStorage.DataResAccount // 账号的数据范围资源
 )
 
 type (
@@ -14,19 +25,36 @@ type (
 	Account struct {
 		*service.Base
 
-		dbs     *storage.Account
-		dbsAuth *storage.Auth
+		dbs      *storage.Account
+		dbsAuth  *storage.Auth
+		dbsToken *storage.Token
+
+		recorder *AccessRecorder // 访问记录
+		risk     *Risk           // 登录风控
+		quota    *Quota          // 账号数量配额
+		nickname *Nickname       // 昵称
+
+		OnCheckUser     func(account *model.Account) *errs.CodeErrs                                         // 登录时检查用户信息 (UserInfoRequire/UserIDCardRequire，user模块注入)
+		OnCheckRegister func(account *model.Account, iAuth model.IAuth) *errs.CodeErrs                      // 注册前检查拥有者 (组织是否存在/仅邀请，org模块注入)
+		OnRegistered    func(ctx context.Context, account *model.Account, iAuth model.IAuth) *errs.CodeErrs // 注册时 (在注册的事务里，组织账号成为成员，org模块注入)
 
 		//cache *cache.Account
 	}
 )
 
 func NewAccount(
-	db *storage.Account, //cache *cache.Account,
+	db *storage.Account, dbAuth *storage.Auth, dbToken *storage.Token, //cache *cache.Account,
+	recorder *AccessRecorder, risk *Risk, nickname *Nickname,
 ) *Account {
 	return &Account{
-		Base: service.NewBase(nil),
-		dbs:  db, // cache: cache,
+		Base:     service.NewBase(nil),
+		dbs:      db.System(), // cache: cache, 注册/登录/清除没有操作者，按操作者的先selectScoped
+		dbsAuth:  dbAuth,
+		dbsToken: dbToken.System(),
+		recorder: recorder,
+		risk:     risk,
+		quota:    NewQuota(db),
+		nickname: nickname,
 	}
 }
 
@@ -49,108 +77,636 @@ func (svc *Account) Register(param *model.Account) *errs.CodeErrs {
 		return err
 	}
 
-	// nickname检查
-	err = svc.checkNickname(entity)
+	// nickname检查 (唯一的先查一下，最终以占用为准)
+	normal, err := svc.nickname.Check(entity)
+	if err != nil {
+		return err
+	}
+	err = svc.nickname.CheckExist(entity, normal)
 	if err != nil {
 		return err
 	}
 
-	// auth检查
-	err = svc.checkAuth(entity, iAuth)
+	// 拥有者检查
+	if svc.OnCheckRegister != nil {
+		err = svc.OnCheckRegister(entity, iAuth)
+		if err != nil {
+			return err
+		}
+	}
+
+	// auth检查 (同一认证并发注册时串行，配额检查+写入在锁的事务里)
+	var account *model.Account
+	err = svc.quota.LockAuth(iAuth, func(ctx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(ctx)
+		var e *errs.CodeErrs
+		account, e = tx.checkAuth(entity, iAuth)
+		if e != nil {
+			return e
+		}
+		// 占用昵称 (失败时和账号写入一起回滚)
+		if e = tx.nickname.Reserve(account, normal); e != nil {
+			return e
+		}
+		// 成为组织成员 (失败时注册整体回滚，不留没有成员身份的账号)
+		if svc.OnRegistered != nil {
+			return svc.OnRegistered(ctx, account, iAuth)
+		}
+		return nil
+	})
+	return err
+}
+
+// UnRegister 注销账号 (冷静期内可恢复，过了冷静期再清除+解绑auths)，返回被吊销的访问token (加黑名单用)
+// verified 是否已经过验证码验证 (limit.VerifyUnRegister)
+func (svc *Account) UnRegister(ctx *service.Ctx, id uint64, verified bool) ([]string, *errs.CodeErrs) {
+	exist, err := svc.selectScoped(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if exist == nil {
+		return nil, errs.Match2("账号不存在")
+	}
+	limit := svc.GetLimitAccount(int16(exist.OwnKind), exist.OwnID)
+	if limit.VerifyUnRegister && !verified {
+		return nil, errs.Match2("注销账号需要验证")
+	}
+
+	purgeAt := time.Now().Add(time.Duration(limit.UnRegisterGraceSeconds) * time.Second).UnixMilli()
+	if !exist.UnRegister(purgeAt) {
+		return nil, errs.Match2("账号已注销")
+	}
+
+	// 注销+下线(+清除) 在一个事务里，失败整体回滚
+	var revokes []string
+	err = service.Transaction(ctx.WithReason("注销账号").Context(), func(txCtx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(txCtx)
+		e := tx.dbs.Update(exist) // TODO:GG 只更新status+extra
+		if e != nil {
+			return e
+		}
+
+		// 注销即下线
+		tokens, e := tx.dbsToken.SelectsByAccount(exist.ID)
+		if e != nil {
+			return e
+		}
+		revokes = make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			revokes = append(revokes, token.AccessToken)
+		}
+		e = tx.dbsToken.DeleteByAccount(exist.ID, exist.GetDelByUserSelf())
+		if e != nil {
+			return e
+		}
+
+		// 没有冷静期，直接清除
+		if limit.UnRegisterGraceSeconds <= 0 {
+			return tx.purge(txCtx, exist)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revokes, nil
+}
+
+// Restore 冷静期内恢复注销的账号 (注销后不能登录，不走数据范围，凭证是验证码)
+// verified 是否已经过验证码验证，验证码的目标要是此账号绑定的 (CheckVerifyTarget)
+func (svc *Account) Restore(ctx *service.Ctx, id uint64, verified bool) *errs.CodeErrs {
+	if !verified {
+		return errs.Match2("恢复账号需要验证")
+	}
+	exist, err := svc.dbs.SelectByID(id)
 	if err != nil {
 		return err
+	} else if exist == nil {
+		return errs.Match2("账号不存在")
 	}
-	return nil
+	if purgeAt, ok := exist.GetPurgeAt(); ok && (purgeAt <= time.Now().UnixMilli()) {
+		return errs.Match2("账号已过注销冷静期")
+	}
+	if !exist.Restore() {
+		return errs.Match2("账号未注销")
+	}
+	return svc.dbs.WithContext(ctx.WithReason("恢复账号").Context()).Update(exist) // TODO:GG 只更新status+extra
 }
 
-// UnRegister 注销账号
-func (svc *Account) UnRegister(exist *model.Account) *errs.CodeErrs {
-	// TODO:GG 需要解绑各种auths吗？
-	// TODO:GG 解绑了的话，就找不回来了！除非有password
-	// TODO:GG 不解绑的话，auth就不能注册新账号
+// CheckVerifyTarget 检查验证码的目标是不是账号绑定的认证 (同own)，不走数据范围 (恢复账号时没登录)
+// 不匹配的和验证码错误返回一样的错误，不暴露账号绑定了什么
+func (svc *Account) CheckVerifyTarget(id uint64, verify *model.Verify) *errs.CodeErrs {
+	exist, err := svc.dbs.SelectByID(id)
+	if err != nil {
+		return err
+	} else if (exist == nil) || (exist.OwnKind != verify.OwnKind) || (exist.OwnID != verify.OwnID) {
+		return errs.Match2("验证失败")
+	}
+	iAuth, err := svc.dbsAuth.SelectByAccount(exist.ID, verify.AuthKind)
+	if err != nil {
+		return err
+	} else if (iAuth == nil) || !slices.Equal(model.VerifyTarget(iAuth), verify.Target) {
+		return errs.Match2("验证失败")
+	}
 	return nil
 }
 
-// Login 登录账号 TODO:GG 移到token里面?
-func (svc *Account) Login(param *model.Account) *errs.CodeErrs {
+// PurgeExpired 清除过了冷静期的注销账号，返回清除数量
+func (svc *Account) PurgeExpired(batchSize int) (int, *errs.CodeErrs) {
+	list, err := svc.dbs.SelectsPurge(time.Now().UnixMilli(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	ctx := service.NewCtxSystem().WithReason("注销冷静期已过").Context()
+	count := 0
+	for _, exist := range list {
+		if err = svc.purge(ctx, exist); err != nil {
+			log.Error("■ ■ Purge ■ ■ 清除账号失败", log.FUint64("accountId", exist.ID), log.FError(err))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// purge 清除账号 (解绑auths + 吊销tokens + 匿名化 + 软删除)，在一个事务里 (已经在事务里的是savepoint)
+func (svc *Account) purge(ctx context.Context, exist *model.Account) *errs.CodeErrs {
+	return service.Transaction(ctx, func(txCtx context.Context) *errs.CodeErrs {
+		return svc.withContext(txCtx).purgeTx(exist)
+	})
+}
+
+func (svc *Account) purgeTx(exist *model.Account) *errs.CodeErrs {
+	// 解绑auths，没有其他账号绑定的，就可以重新注册了
+	auths, err := svc.dbsAuth.SelectsByAccount(exist.ID)
+	if err != nil {
+		return err
+	}
+	for _, iAuth := range auths {
+		iAuth.DelAccount(exist.OwnKind, exist.OwnID)
+		if len(iAuth.GetAccAccounts()) <= 0 {
+			iAuth.TryUnbind()
+		}
+		err = svc.dbsAuth.Update(iAuth)
+		if err != nil {
+			return err
+		}
+		err = svc.dbsAuth.Unbind(exist.ID, iAuth.GetID())
+		if err != nil {
+			return err
+		}
+	}
+
+	// 吊销tokens
+	err = svc.dbsToken.DeleteByAccount(exist.ID, exist.GetDelByAdminSys())
+	if err != nil {
+		return err
+	}
+
+	// 释放昵称
+	err = svc.nickname.Release(exist)
+	if err != nil {
+		return err
+	}
+
+	// 匿名化 + 软删除
+	exist.Anonymize()
+	err = svc.dbs.Update(exist)
+	if err != nil {
+		return err
+	}
+	return svc.dbs.Delete(exist.ID, exist.GetDelByAdminSys())
+}
+
+// Login 登录账号 (凭证已校验通过) TODO:GG 移到token里面?
+// verified 本次登录是否已经过验证码验证 (风控二次验证/锁定解锁)
+func (svc *Account) Login(param *model.Account, iAuth model.IAuth, access *model.Access, verified bool) *errs.CodeErrs {
 	limit := svc.GetLimitAccount(int16(param.OwnKind), param.OwnID)
 	_ = limit.AuthLogins
 	_ = limit.AuthRequires
-	_ = limit.UserIDCardRequire
-	_ = limit.UserInfoRequire
 	_ = limit.UserBioRequire
 
-	_ = svc.checkActionLogin(param)
+	err := svc.checkActionLogin(param)
+	if err != nil {
+		return err
+	}
 
-	if param.Status == model.AccountStatusLocked {
-		// TODO:GG 解锁
+	// 锁定检查 (过了锁定时间/验证码登录 自动解锁)
+	err = svc.checkLock(param, iAuth, verified)
+	if err != nil {
+		return err
+	}
+
+	// 用户信息检查
+	err = svc.checkUser(param)
+	if err != nil {
+		return err
 	}
 
 	// TODO:GG 如果没有则注册?
 
 	_ = param.GetAuthKinds()
 
-	return nil
+	// 登录风控
+	err = svc.checkRisk(param, access, verified)
+	if err == nil {
+		err = svc.loginSucceed(param, iAuth)
+	}
+
+	// 登录记录 (拦截的也记录，供后续对比)
+	svc.recordAccess(param, access)
+	return err
+}
+
+// LoginFailed 登录失败 (凭证错误)，连续失败次数过多则锁定账号和认证
+func (svc *Account) LoginFailed(exist *model.Account, iAuth model.IAuth) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(exist.OwnKind), exist.OwnID)
+	if limit.LockFailTimes <= 0 {
+		return nil
+	}
+	now := time.Now()
+	dbs := svc.dbs.WithContext(service.NewCtxSystem().WithReason("登录连续失败").Context())
+
+	// 账号 (并发失败时，冲突的重新查询再累加，不丢失败次数)
+	err := service.RetryConflict(func(times int) *errs.CodeErrs {
+		if times > 0 {
+			reload, e := svc.dbs.SelectByID(exist.ID)
+			if (e != nil) || (reload == nil) {
+				return e
+			}
+			*exist = *reload
+		}
+		if exist.IncLoginFails() >= limit.LockFailTimes {
+			exist.ClearLoginFails()
+			locks := exist.IncLoginLocks()
+			block := (limit.LockBlockTimes > 0) && (locks >= limit.LockBlockTimes)
+			exist.Lock(now.Add(svc.lockDuration(limit, locks)).UnixMilli(), block)
+		}
+		return dbs.Update(exist) // TODO:GG 只更新status+extra
+	})
+	if err != nil {
+		return err
+	}
+
+	// 认证 (同一个认证目标，可能被用来撞多个账号)
+	if iAuth == nil {
+		return nil
+	}
+	return service.RetryConflict(func(times int) *errs.CodeErrs {
+		if times > 0 {
+			reload, e := svc.dbsAuth.Select(iAuth)
+			if (e != nil) || (reload == nil) {
+				return e
+			}
+			iAuth = reload
+		}
+		if iAuth.IncLoginFails() >= limit.LockFailTimes {
+			iAuth.ClearLoginFails()
+			locks := iAuth.IncLoginLocks()
+			until := now.Add(svc.lockDuration(limit, locks)).UnixMilli()
+			iAuth.SetLockUntil(&until)
+		}
+		return svc.dbsAuth.Update(iAuth) // TODO:GG 只更新extra
+	})
+}
+
+// Unblock 管理员解锁账号 (Locked/Blocked)，和登录失败并发时重新查询再解锁
+func (svc *Account) Unblock(ctx *service.Ctx, id uint64) *errs.CodeErrs {
+	return service.RetryConflict(func(int) *errs.CodeErrs {
+		exist, err := svc.selectManaged(ctx, id)
+		if err != nil {
+			return err
+		} else if exist == nil {
+			return errs.Match2("账号不存在")
+		} else if !exist.Unlock() {
+			return errs.Match2("账号未被锁定")
+		}
+		exist.ClearLoginFails()
+		exist.ClearLoginLocks()
+		return svc.dbs.WithContext(ctx.WithReason("管理员解锁").Context()).Update(exist) // TODO:GG 只更新status+extra
+	})
 }
 
-//case AuthKindPassword:
-// TODO:GG 在auth里检查?
-// TODO: 比较 hashedPassword 和 a.Password
-// 实际场景中应该使用安全的密码哈希比较
-// 例如: hashedPassword := HashPassword(cred.Password, salt)
-//salt, _ := a.GetPasswordSalt()
+// selectScoped 查询操作者数据范围里的账号，范围外的当不存在
+func (svc *Account) selectScoped(ctx *service.Ctx, id uint64) (*model.Account, *errs.CodeErrs) {
+	return selectAccountScoped(ctx, svc.dbs, id)
+}
+
+// selectManaged 查询操作者管理的账号 (管理员操作)，要有own/all的数据范围，不能操作自己的账号
+// 只有自己数据范围的 (普通账号) 当作没有权限，不依赖鉴权中间件
+func (svc *Account) selectManaged(ctx *service.Ctx, id uint64) (*model.Account, *errs.CodeErrs) {
+	if ctx.DataScope(dataAccount).Kind < pkgStorage.DataScopeOwn {
+		log.Warn("■ ■ Account ■ ■ 没有管理账号的权限", log.FUint64("actorId", ctx.ActorId), log.FUint64("id", id))
+		return nil, errs.Match2(msg.ErrIdPermDenied)
+	} else if (ctx.ActorType == service.ActorTypeAccount) && (ctx.ActorId == id) {
+		return nil, errs.Match2(msg.ErrIdPermDenied)
+	}
+	return svc.selectScoped(ctx, id)
+}
 
-// ResetNickname 重置昵称
-func (svc *Account) ResetNickname(exist *model.Account) *errs.CodeErrs {
-	// TODO:GG 重置昵称
+// selectAccountScoped 按操作者的数据范围查询账号，范围外的当作不存在 (导出/头像等服务也用)
+func selectAccountScoped(ctx *service.Ctx, dbs *storage.Account, id uint64) (*model.Account, *errs.CodeErrs) {
+	scope := ctx.DataScope(dataAccount)
+	exist, err := dbs.WithScope(scope).SelectByID(id)
+	if (err != nil) || (exist == nil) {
+		return nil, err
+	} else if !scope.Allow(int16(exist.OwnKind), exist.OwnID, exist.ID) {
+		log.Warn("■ ■ Account ■ ■ 越权访问账号", log.FUint64("actorId", ctx.ActorId), log.FUint64("id", id))
+		return nil, nil
+	}
+	return exist, nil
+}
+
+// checkLock 检查锁定，过了锁定时间或者验证码登录的自动解锁
+func (svc *Account) checkLock(exist *model.Account, iAuth model.IAuth, verified bool) *errs.CodeErrs {
+	now := time.Now().UnixMilli()
+	if (iAuth != nil) && !verified {
+		if until, ok := iAuth.GetLockUntil(); ok && (until > now) {
+			return errs.Match2("认证暂时被锁定")
+		}
+	}
+	if exist.Status != model.AccountStatusLocked {
+		return nil
+	}
+	if until, ok := exist.GetLockUntil(); !verified && ok && (until > now) {
+		return errs.Match2("账号暂时被锁定")
+	}
+	exist.Unlock()
+	reason := "锁定到期解锁"
+	if verified {
+		reason = "验证码登录解锁"
+	}
+	return svc.dbs.WithContext(service.NewCtxSystem().WithReason(reason).Context()).Update(exist) // TODO:GG 只更新status+extra
+}
+
+// checkLoginable 校验凭证之前的检查，封禁/注销/锁定中的不校验凭证 (锁定期间不能继续试密码)
+// 验证码登录的不拦截到期前的登录锁定 (验证通过后在checkLock里解锁)，管理员锁定的不行
+func (svc *Account) checkLoginable(exist *model.Account, iAuth model.IAuth, byCode bool) *errs.CodeErrs {
+	if !exist.CanLogin() || !iAuth.IsEnabled() {
+		return errs.Match2("账号不可登录")
+	} else if byCode {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	if until, ok := iAuth.GetLockUntil(); ok && (until > now) {
+		return errs.Match2("认证暂时被锁定")
+	}
+	if exist.Status == model.AccountStatusLocked {
+		if until, ok := exist.GetLockUntil(); ok && (until > now) {
+			return errs.Match2("账号暂时被锁定")
+		}
+	}
 	return nil
 }
 
-// checkNickname 检查昵称
-func (svc *Account) checkNickname(entity *model.Account) *errs.CodeErrs {
-	limit := svc.GetLimitAccount(int16(entity.OwnKind), entity.OwnID)
-	if !limit.NicknameRequire {
-		entity.Nickname = nil
+// loginSucceed 登录成功，清空失败/锁定次数
+func (svc *Account) loginSucceed(exist *model.Account, iAuth model.IAuth) *errs.CodeErrs {
+	exist.ClearLoginFails()
+	exist.ClearLoginLocks()
+	err := svc.dbs.Update(exist) // TODO:GG 有变化才更新
+	if err != nil {
+		return err
+	}
+	if iAuth == nil {
 		return nil
-	} else if entity.Nickname == nil {
-		return errs.Match2("昵称不能为空")
 	}
+	iAuth.ClearLoginFails()
+	iAuth.ClearLoginLocks()
+	iAuth.SetLockUntil(nil)
+	return svc.dbsAuth.Update(iAuth) // TODO:GG 有变化才更新
+}
 
-	// 查重
-	if !limit.NicknameUnique {
+// lockDuration 锁定时长，按连续锁定次数翻倍
+func (svc *Account) lockDuration(limit *service.LimitAccount, locks int) time.Duration {
+	seconds := limit.LockSeconds
+	for i := 1; (i < locks) && (seconds < limit.LockMaxSeconds); i++ {
+		seconds *= 2
+	}
+	if (limit.LockMaxSeconds > 0) && (seconds > limit.LockMaxSeconds) {
+		seconds = limit.LockMaxSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// checkUser 登录时检查用户信息 (UserInfoRequire/UserIDCardRequire，没关联用户/资料不全/没实名的不能登录)
+func (svc *Account) checkUser(account *model.Account) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if !limit.UserInfoRequire && !limit.UserIDCardRequire {
+		return nil
+	} else if account.UserID == nil {
+		return errs.Match2(msg.ErrIdUserInfoRequired)
+	} else if svc.OnCheckUser == nil {
 		return nil
 	}
-	exist, err := svc.dbs.Select(entity) // TODO:GG 根据 OwnKind + OwnId + nickname 查找account
+	return svc.OnCheckUser(account)
+}
+
+// checkRisk 登录风控检查
+func (svc *Account) checkRisk(account *model.Account, access *model.Access, verified bool) *errs.CodeErrs {
+	if (svc.risk == nil) || (access == nil) {
+		return nil
+	}
+	access.OwnKind = account.OwnKind
+	access.OwnID = account.OwnID
+	access.AccountID = account.ID
+	result, err := svc.risk.Evaluate(access)
+	if err != nil {
+		return nil // 风控失败不影响登录
+	}
+	switch result.Action {
+	case RiskActionBlock:
+		return errs.Match2("登录存在风险，已被拦截")
+	case RiskActionVerify:
+		if !verified {
+			return errs.Match2("登录存在风险，需要验证")
+		}
+		svc.risk.Notify(access, result)
+	case RiskActionNotify:
+		svc.risk.Notify(access, result)
+	}
+	return nil
+}
+
+// recordAccess 记录访问 (异步)
+func (svc *Account) recordAccess(account *model.Account, access *model.Access) {
+	if (svc.recorder == nil) || (access == nil) {
+		return
+	}
+	access.OwnKind = account.OwnKind
+	access.OwnID = account.OwnID
+	access.AccountID = account.ID
+	access.UserID = account.UserID
+	svc.recorder.Add(access)
+}
+
+// ChangeNickname 修改昵称 (限制修改频率)
+func (svc *Account) ChangeNickname(ctx *service.Ctx, id uint64, nickname string) *errs.CodeErrs {
+	exist, err := svc.selectScoped(ctx, id)
 	if err != nil {
 		return err
-	} else if exist != nil {
-		return errs.Match2("昵称已存在")
+	} else if (exist == nil) || !exist.CanLogin() {
+		return errs.Match2("账号不存在")
+	}
+	now := time.Now()
+	err = svc.nickname.CheckInterval(exist, now)
+	if err != nil {
+		return err
+	}
+
+	old := exist.Nickname
+	exist.Nickname = &nickname
+	normal, err := svc.nickname.Check(exist)
+	if err != nil {
+		exist.Nickname = old
+		return err
+	}
+	nicknameAt := now.UnixMilli()
+	exist.SetNicknameAt(&nicknameAt)
+
+	// 占用昵称+修改账号 在一个事务里 (版本冲突时占用一起回滚)
+	err = service.Transaction(ctx.Context(), func(txCtx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(txCtx)
+		if e := tx.nickname.Reserve(exist, normal); e != nil {
+			return e
+		}
+		return tx.dbs.Update(exist)
+	})
+	if err != nil {
+		exist.Nickname = old
+	}
+	return err
+}
+
+// ResetNickname 重置昵称 (管理员，违规昵称换成默认的，不影响用户的修改频率)
+func (svc *Account) ResetNickname(ctx *service.Ctx, id uint64) *errs.CodeErrs {
+	exist, err := svc.selectManaged(ctx, id)
+	if err != nil {
+		return err
+	} else if exist == nil {
+		return errs.Match2("账号不存在")
 	}
+	limit := svc.GetLimitAccount(int16(exist.OwnKind), exist.OwnID)
+	var normal string
+	if limit.NicknameRequire {
+		nickname := svc.nickname.Default(exist)
+		exist.Nickname = &nickname
+		normal = words.Normalize(nickname)
+	} else {
+		exist.Nickname = nil
+	}
+
+	// 释放/占用昵称+修改账号 在一个事务里
+	err = service.Transaction(ctx.Context(), func(txCtx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(txCtx)
+		var e *errs.CodeErrs
+		if exist.Nickname == nil {
+			e = tx.nickname.Release(exist)
+		} else {
+			e = tx.nickname.Reserve(exist, normal)
+		}
+		if e != nil {
+			return e
+		}
+		return tx.dbs.Update(exist)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ Account ■ ■ 重置昵称", log.FUint64("accountId", exist.ID))
 	return nil
 }
 
+// LinkUser 账号关联用户 (MaxPerUser配额，已关联其他用户的要先解除)
+// 只能关联数据范围里的账号 (自己的，或有权限的own/all)，用户归属由调用方检查
+func (svc *Account) LinkUser(ctx *service.Ctx, id uint64, userID uint64) *errs.CodeErrs {
+	exist, err := svc.selectScoped(ctx, id)
+	if err != nil {
+		return err
+	} else if (exist == nil) || !exist.CanLogin() {
+		return errs.Match2("账号不存在")
+	} else if exist.UserID != nil {
+		if *exist.UserID == userID {
+			return nil
+		}
+		return errs.Match2(msg.ErrIdUserAccountLinked)
+	}
+	return svc.quota.LockUser(userID, func(ctx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(ctx)
+		if e := tx.quota.CheckUser(exist, userID); e != nil {
+			return e
+		}
+		exist.UserID = &userID
+		return tx.dbs.Update(exist)
+	})
+}
+
+// UnlinkUser 账号解除关联用户 (只能是数据范围里的账号)
+func (svc *Account) UnlinkUser(ctx *service.Ctx, id uint64, userID uint64) *errs.CodeErrs {
+	exist, err := svc.selectScoped(ctx, id)
+	if err != nil {
+		return err
+	} else if (exist == nil) || (exist.UserID == nil) || (*exist.UserID != userID) {
+		return errs.Match2("账号不存在")
+	}
+	exist.UserID = nil
+	return svc.dbs.WithContext(ctx.Context()).Update(exist)
+}
+
+// IsActorUser 用户是不是操作者账号关联的用户 (不是账号操作的都不是)
+func (svc *Account) IsActorUser(ctx *service.Ctx, userID uint64) (bool, *errs.CodeErrs) {
+	if (ctx == nil) || (ctx.ActorType != service.ActorTypeAccount) || (ctx.ActorId <= 0) {
+		return false, nil
+	}
+	actor, err := svc.dbs.SelectByID(ctx.ActorId)
+	if (err != nil) || (actor == nil) || !actor.CanLogin() {
+		return false, err
+	}
+	return (actor.UserID != nil) && (*actor.UserID == userID), nil
+}
+
+// SelectsByUser 用户关联的账号
+func (svc *Account) SelectsByUser(userID uint64) ([]*model.Account, *errs.CodeErrs) {
+	return svc.dbs.SelectsByUser(userID)
+}
+
+// withContext 绑定事务上下文的副本 (仓储/配额/昵称都走ctx里的事务)
+func (svc *Account) withContext(ctx context.Context) *Account {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	tx.dbsAuth = svc.dbsAuth.WithContext(ctx)
+	tx.dbsToken = svc.dbsToken.WithContext(ctx)
+	tx.quota = svc.quota.withContext(ctx)
+	tx.nickname = svc.nickname.withContext(ctx)
+	return &tx
+}
+
 // generateNumber 生成账号标识
 func (svc *Account) generateNumber(entity *model.Account) *errs.CodeErrs {
 	// TODO:GG 生成账号标识
 	return nil
 }
 
-// checkAuth 检查认证
-func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) *errs.CodeErrs {
+// checkAuth 检查认证，返回保存的账号 (新注册的/重新注册的)
+func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) (*model.Account, *errs.CodeErrs) {
 	authKind := iAuth.GetKind()
 
 	// 检查是否是必要的AuthKind
 	if !svc.isAuthKindRequire(entity, authKind) {
-		return errs.Match2(fmt.Sprintf("不是必须的认证方式 kind: %svc", strconv.Itoa(int(authKind))))
+		return nil, errs.Match2(fmt.Sprintf("不是必须的认证方式 kind: %svc", strconv.Itoa(int(authKind))))
+	}
+
+	// 检查手机区号/邮箱域名
+	err := checkAuthTarget(svc.GetLimitAuth(int16(entity.OwnKind), entity.OwnID), iAuth)
+	if err != nil {
+		return nil, err
 	}
 
 	// 查重，固定成1了，同own下，account和auth是一对一的关系
-	exist, err := svc.dbs.Select(entity) // TODO:GG 根据 ownKind + ownID + authKind + target 查找accounts
+	exist, err := svc.dbs.SelectByOwnAuth(entity.OwnKind, entity.OwnID, iAuth)
 	if err != nil {
-		return err
+		return nil, err
 	} else if exist != nil {
 		if !exist.IsUnRegister() || !exist.CanRegister() {
-			return errs.Match2("账号已存在")
+			return nil, errs.Match2("账号已存在")
 		}
 	}
 
@@ -159,11 +715,11 @@ func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) *errs.Co
 	case model.AuthKindPassword:
 		// 查重，AuthKindPassword的username只能是owner里唯一的
 		// 不检查TokenShares了，只有share里有的，这里也可以注册
-		existAuth, err := svc.dbsAuth.Select(iAuth) // TODO:GG 根据 OwnKind + OwnId + Username 查找auth
+		existAuth, err := svc.dbsAuth.SelectByOwn(entity.OwnKind, entity.OwnID, iAuth)
 		if err != nil {
-			return err
+			return nil, err
 		} else if existAuth != nil {
-			return errs.Match2("用户名已存在")
+			return nil, errs.Match2("用户名已存在")
 		}
 
 		// 添加/重新注册账号
@@ -174,21 +730,32 @@ func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) *errs.Co
 			err = svc.dbs.Update(exist)
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// 添加关联的auth
-		err = svc.dbsAuth.Insert(iAuth) // TODO:GG 这里是多对多表的修改
+		// 添加关联的auth (密码只存哈希)
+		pwd, ok := iAuth.(*model.AuthPassword)
+		if !ok {
+			return nil, errs.Match2("认证类型错误")
+		} else if e := pwd.HashPassword(); e != nil {
+			log.Error("■ ■ Account ■ ■ 密码哈希失败", log.FError(e))
+			return nil, errs.Match2("密码格式错误")
+		}
+		err = svc.dbsAuth.Insert(iAuth)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		err = svc.dbsAuth.Bind(exist.ID, iAuth.GetID())
+		if err != nil {
+			return nil, err
 		}
 
 		// 修改实体类绑定
 		if exist.AddAuth(iAuth) {
 			// 修改account状态
-			err = svc.dbs.Update(exist) // TODO:GG 更新账号状态
+			err = svc.dbs.Update(exist)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
@@ -196,11 +763,21 @@ func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) *errs.Co
 		model.AuthKindEmail:
 		// TODO:GG 上层进行过auth的verify认证了 (如果limit.VerifyRegister=true的话)
 		// 查重，相同的auth只能有一个(全局),pwd除外
-		existAuth, err := svc.dbsAuth.Select(iAuth) // TODO:GG 根据 number/email/openID/... 查找auth
+		existAuth, err := svc.dbsAuth.Select(iAuth)
 		if err != nil {
-			return err
+			return nil, err
 		} else if (existAuth != nil) && !existAuth.IsEnabled() {
-			return errs.Match2("认证不可用")
+			return nil, errs.Match2("认证不可用")
+		}
+
+		// 配额检查 (重新注册的不算新增)
+		quotaAccount := entity
+		if exist != nil {
+			quotaAccount = exist
+		}
+		err = svc.quota.CheckAuth(quotaAccount, iAuth)
+		if err != nil {
+			return nil, err
 		}
 
 		// 添加/重新注册账号
@@ -211,35 +788,37 @@ func (svc *Account) checkAuth(entity *model.Account, iAuth model.IAuth) *errs.Co
 			err = svc.dbs.Update(exist)
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// auth的是否首次注册
 		if existAuth == nil {
 			// 添加关联的auth
-			err = svc.dbsAuth.Insert(iAuth) // TODO:GG 这里是多对多表的修改
+			err = svc.dbsAuth.Insert(iAuth)
+			if err != nil {
+				return nil, err
+			}
 			existAuth = iAuth
-		} else {
-			// 更新关联auth
-			err = svc.dbsAuth.Update(existAuth) // TODO:GG 更新accountID 这里是多对多表的修改
 		}
+		// 关联auth (多对多表，已经关联的不变)
+		err = svc.dbsAuth.Bind(exist.ID, existAuth.GetID())
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// 修改实体类绑定
 		if exist.AddAuth(existAuth) {
 			// 修改account状态
-			err = svc.dbs.Update(exist) // TODO:GG 更新账号状态
+			err = svc.dbs.Update(exist)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 	default:
-		return errs.Match2(fmt.Sprintf("不支持的认证方式 kind: %svc", strconv.Itoa(int(authKind))))
+		return nil, errs.Match2(fmt.Sprintf("不支持的认证方式 kind: %svc", strconv.Itoa(int(authKind))))
 	}
-	return nil
+	return exist, nil
 }
 
 // isAuthKindRequire 检查是否是必要的AuthKind
@@ -292,6 +871,6 @@ func (svc *Account) checkActionLogin(exist *model.Account) *errs.CodeErrs {
 			return errs.Match2("账号被拉黑")
 		}
 	} else {
-		return errs.Match2("账号暂时被锁定")
+		return errs.Match2("账号被锁定，请联系管理员解锁")
 	}
 }
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"katydid-mp-user/internal/api/auth/model"
 	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
 	"katydid-mp-user/internal/pkg/service"
 	"katydid-mp-user/pkg/errs"
 )
@@ -15,22 +17,40 @@ type (
 		dbs        *storage.Auth
 		dbsAccount *storage.Account
 		dbsVerify  *storage.Verify
+
+		quota *Quota // 账号数量配额
 	}
 )
 
-// BindAccounts 添加认证并绑定账号
+func NewAuth(db *storage.Auth, dbAccount *storage.Account, dbVerify *storage.Verify) *Auth {
+	return &Auth{
+		Base:       service.NewBase(nil),
+		dbs:        db,
+		dbsAccount: dbAccount.System(), // 绑定/解绑时已经校验过账号
+		dbsVerify:  dbVerify,
+		quota:      NewQuota(dbAccount),
+	}
+}
+
+// BindAccounts 添加认证并绑定账号 (认证写入和所有账号绑定在一个事务里，失败整体回滚)
 func (svc *Auth) BindAccounts(param model.IAuth) *errs.CodeErrs {
+	return service.Transaction(svc.dbs.Context(), func(ctx context.Context) *errs.CodeErrs {
+		return svc.withContext(ctx).bindAccounts(param)
+	})
+}
+
+func (svc *Auth) bindAccounts(param model.IAuth) *errs.CodeErrs {
 	// 记录+清洗数据
 	accounts := param.GetAccAccounts() // TODO:GG token里的的account(exist)填充到auth里
 	entity := param.Wash()
 
 	// 历史记录
-	exist, err := svc.dbs.Select(param) // TODO:GG 根据 AuthKind + Target 查找Auth
+	exist, err := svc.dbs.Select(param)
 	if err != nil {
 		return err
 	} else if exist == nil {
 		// 数据库添加 (后面的关联是多对多，需要先insertAuth)
-		entity.SetStatus(model.AuthStatusActive) // TODO:GG 上层需要验证verify
+		entity.TryActive() // TODO:GG 上层需要验证verify
 		err = svc.dbs.Insert(entity)
 		if err != nil {
 			return err
@@ -55,7 +75,21 @@ func (svc *Auth) BindAccounts(param model.IAuth) *errs.CodeErrs {
 	}
 	for _, owns := range accounts {
 		for _, acc := range owns {
-			err = svc.bindAccount(exist, acc)
+			// 手机区号/邮箱域名
+			err = checkAuthTarget(svc.GetLimitAuth(int16(acc.OwnKind), acc.OwnID), exist)
+			if err != nil {
+				return err
+			}
+			// 同一认证并发绑定时串行，配额检查+写入在锁里
+			err = svc.quota.LockAuth(exist, func(ctx context.Context) *errs.CodeErrs {
+				tx := svc.withContext(ctx)
+				if exist.GetAccount(acc.OwnKind, acc.OwnID) == nil {
+					if e := tx.quota.CheckAuth(acc, exist); e != nil {
+						return e
+					}
+				}
+				return tx.bindAccount(exist, acc)
+			})
 			if err != nil {
 				return err
 			}
@@ -66,7 +100,7 @@ func (svc *Auth) BindAccounts(param model.IAuth) *errs.CodeErrs {
 
 // BindAccount 绑定账号
 func (svc *Auth) bindAccount(exist model.IAuth, account *model.Account) *errs.CodeErrs {
-	// 检查账号是否已绑定 TODO:GG 如果不是强一致性，则查多对多表
+	// 检查账号是否已绑定 (查询时从多对多表带出来的)
 	oldBindAccount := exist.GetAccount(account.OwnKind, account.OwnID)
 	if oldBindAccount != nil {
 		limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
@@ -77,20 +111,28 @@ func (svc *Auth) bindAccount(exist model.IAuth, account *model.Account) *errs.Co
 		}
 	}
 
-	// 更新auth下的account关联 (多对多表修改)
-	// TODO:GG 更新外表accountID，新旧都会改，需要在这里更新吗？
-	// TODO:GG 被绑定的account也需要修改auth，auth只同时bind一个(当前own下)账号
-
-	// 修改实体类绑定
-	if oldBindAccount != nil {
+	// 更新auth下的account关联 (多对多表修改)，auth只同时bind一个(当前own下)账号，新旧都要改
+	if (oldBindAccount != nil) && (oldBindAccount.ID != account.ID) {
+		if err := svc.dbs.Unbind(oldBindAccount.ID, exist.GetID()); err != nil {
+			return err
+		}
 		delete(oldBindAccount.Auths, exist.GetKind())
 	}
-	if oldBindAuth := account.Auths[exist.GetKind()]; oldBindAuth != nil {
+	if oldBindAuth := account.Auths[exist.GetKind()]; (oldBindAuth != nil) && (oldBindAuth.GetID() != exist.GetID()) {
+		if err := svc.dbs.Unbind(account.ID, oldBindAuth.GetID()); err != nil {
+			return err
+		}
 		delete(account.Auths, oldBindAuth.GetKind())
 	}
+	if err := svc.dbs.Bind(account.ID, exist.GetID()); err != nil {
+		return err
+	}
+	exist.SetAccount(account)
+
+	// 修改实体类绑定
 	if account.AddAuth(exist) {
 		// 修改account状态
-		err := svc.dbsAccount.Update(account) // TODO:GG 更新账号状态
+		err := svc.dbsAccount.Update(account)
 		if err != nil {
 			return err
 		}
@@ -111,7 +153,7 @@ func (svc *Auth) UnbindAccount(param model.IAuth, account *model.Account) *errs.
 		return errs.Match2("认证不可用")
 	}
 
-	// 检查账号是否已绑定 TODO:GG 如果不是强一致性，则查多对多表
+	// 检查账号是否已绑定 (查询时从多对多表带出来的)
 	if exist.GetAccount(account.OwnKind, account.OwnID) == nil {
 		return errs.Match2("未绑定账号")
 	}
@@ -137,13 +179,16 @@ func (svc *Auth) UnbindAccount(param model.IAuth, account *model.Account) *errs.
 	}
 
 	// 更新auth下的account关联 (多对多表修改)
-	// TODO:GG 更新外表accountID，新旧都会改，需要在这里更新吗？
-	// TODO:GG 被绑定的account也需要修改auth，auth只同时bind一个(当前own下)账号(limit固定1)
+	err = svc.dbs.Unbind(account.ID, exist.GetID())
+	if err != nil {
+		return err
+	}
+	exist.DelAccount(account.OwnKind, account.OwnID)
 
 	// 修改实体类绑定
 	if account.DelAuth(exist) {
 		// 修改account状态
-		err := svc.dbsAccount.Update(account) // TODO:GG 更新账号状态
+		err := svc.dbsAccount.Update(account)
 		if err != nil {
 			return err
 		}
@@ -159,36 +204,42 @@ func (svc *Auth) ResetPassword(param *model.AuthPassword) *errs.CodeErrs {
 	return nil
 }
 
-// tryStatusActive 尝试修改成激活状态
+// tryStatusActive 尝试修改成激活状态 (并发修改冲突时，重新查询再判断)
 func (svc *Auth) tryStatusActive(exist model.IAuth) *errs.CodeErrs {
-	// 检查是否满足更新条件
-	update := false
-	if exist.IsEnabled() && !exist.IsActive() {
-		existVerify, err := svc.dbsVerify.Select(nil) // TODO:GG 根据 StatusSuccess + AuthKind + Target 查找最近的
-		if err != nil {
-			return err
-		} else if existVerify == nil {
-			return nil
+	return service.RetryConflict(func(times int) *errs.CodeErrs {
+		if times > 0 {
+			reload, err := svc.reload(exist)
+			if (err != nil) || (reload == nil) {
+				return err
+			}
+			exist = reload
 		}
-		update = true
-	} else if exist.IsBind() && len(exist.GetAccAccounts()) == 0 {
-		// TODO:GG 除非GetAccAccounts具有一致性(TX事务)，否则要在多对多的表里查accounts
-		update = true
-	}
-	// active不会回溯，除非拉黑
 
-	// 更新auth的状态
-	if update {
-		exist.SetStatus(model.AuthStatusActive)
-		err := svc.dbs.Update(exist) // TODO:GG 更新status
-		if err != nil {
-			return err
+		// 检查是否满足更新条件
+		update := false
+		if exist.IsEnabled() && !exist.IsActive() {
+			existVerify, err := svc.dbsVerify.SelectLastSuccess(exist.GetKind(), model.VerifyTarget(exist))
+			if err != nil {
+				return err
+			} else if existVerify == nil {
+				return nil
+			}
+			update = exist.TryActive()
+		} else if exist.IsBind() && len(exist.GetAccAccounts()) == 0 {
+			// 重新查询时，accounts是从多对多表带出来的
+			update = exist.TryUnbind()
 		}
-	}
-	return nil
+		// active不会回溯，除非拉黑
+
+		// 更新auth的状态
+		if update {
+			return svc.dbs.Update(exist) // TODO:GG 更新status
+		}
+		return nil
+	})
 }
 
-// tryStatusBind 尝试修改成绑定状态
+// tryStatusBind 尝试修改成绑定状态 (并发修改冲突时，重新查询再判断)
 func (svc *Auth) tryStatusBind(exist model.IAuth) *errs.CodeErrs {
 	// 先检查是否active
 	err := svc.tryStatusActive(exist)
@@ -196,20 +247,64 @@ func (svc *Auth) tryStatusBind(exist model.IAuth) *errs.CodeErrs {
 		return err
 	}
 
-	// 检查是否满足更新条件
-	update := false
-	if exist.IsActive() && !exist.IsBind() && (len(exist.GetAccAccounts()) > 0) {
-		// TODO:GG 除非GetAccAccounts具有一致性(TX事务)，否则要在多对多的表里查accounts
-		update = true
-	}
+	return service.RetryConflict(func(times int) *errs.CodeErrs {
+		if times > 0 {
+			reload, e := svc.reload(exist)
+			if (e != nil) || (reload == nil) {
+				return e
+			}
+			exist = reload
+		}
 
-	// 更新auth的状态
-	if update {
-		exist.SetStatus(model.AuthStatusBind)
-		err = svc.dbs.Update(exist) // TODO:GG 更新status
-		if err != nil {
-			return err
+		// 检查是否满足更新条件
+		update := false
+		if exist.IsActive() && !exist.IsBind() && (len(exist.GetAccAccounts()) > 0) {
+			update = exist.TryBind()
+		}
+
+		// 更新auth的状态
+		if update {
+			return svc.dbs.Update(exist) // TODO:GG 更新status
 		}
+		return nil
+	})
+}
+
+// reload 重新查询认证 (版本冲突后)，关联的账号也从多对多表重新带出来
+func (svc *Auth) reload(exist model.IAuth) (model.IAuth, *errs.CodeErrs) {
+	return svc.dbs.Select(exist)
+}
+
+// checkAuthTarget 检查认证标识是否在own允许的范围 (手机区号/邮箱域名)
+func checkAuthTarget(limit *service.LimitAuth, iAuth model.IAuth) *errs.CodeErrs {
+	switch auth := iAuth.(type) {
+	case *model.AuthCellphone:
+		return checkPhoneCode(limit, auth.Code)
+	case *model.AuthEmail:
+		return checkEmailDomain(limit, auth.Domain)
 	}
 	return nil
 }
+
+func checkPhoneCode(limit *service.LimitAuth, code string) *errs.CodeErrs {
+	if !limit.IsPhoneCodeEnable(code) {
+		return errs.Match2(msg.ErrIdAuthPhoneCodeDisabled)
+	}
+	return nil
+}
+
+func checkEmailDomain(limit *service.LimitAuth, domain string) *errs.CodeErrs {
+	if !limit.IsEmailDomainEnable(domain) {
+		return errs.Match2(msg.ErrIdAuthEmailDomainDisabled)
+	}
+	return nil
+}
+
+// withContext 绑定事务上下文的副本 (仓储/配额都走ctx里的事务)
+func (svc *Auth) withContext(ctx context.Context) *Auth {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	tx.dbsAccount = svc.dbsAccount.WithContext(ctx)
+	tx.quota = svc.quota.withContext(ctx)
+	return &tx
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/file"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/oss"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	avatarMaxSizeDef = 2 << 20   // 默认最大2M
+	avatarQualityDef = 85        // 默认jpeg质量
+	avatarExpiresDef = time.Hour // 默认访问链接有效期
+)
+
+var avatarSizesDef = []int{256, 64} // 默认尺寸 (正方形边长)
+
+type (
+	// Avatar 头像服务 (裁剪成正方形，缩放成标准尺寸，重新编码去掉EXIF)
+	Avatar struct {
+		*service.Base
+
+		dbsAccount *storage.Account
+
+		store   oss.Store
+		maxSize int64         // 上传大小上限
+		sizes   []int         // 标准尺寸 (从大到小)
+		quality int           // jpeg质量
+		expires time.Duration // 访问链接有效期
+	}
+)
+
+func NewAvatar(
+	dbAccount *storage.Account, store oss.Store,
+	maxSize int64, sizes []int, quality int, expires time.Duration,
+) *Avatar {
+	if maxSize <= 0 {
+		maxSize = avatarMaxSizeDef
+	}
+	if len(sizes) <= 0 {
+		sizes = avatarSizesDef
+	}
+	sizes = append([]int{}, sizes...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	if (quality <= 0) || (quality > 100) {
+		quality = avatarQualityDef
+	}
+	if expires <= 0 {
+		expires = avatarExpiresDef
+	}
+	return &Avatar{
+		Base:       service.NewBase(nil),
+		dbsAccount: dbAccount.System(), // 头像是公开的，修改时先selectAccountScoped
+		store:      store,
+		maxSize:    maxSize,
+		sizes:      sizes,
+		quality:    quality,
+		expires:    expires,
+	}
+}
+
+// MaxSize 上传大小上限 (上层读取时限制)
+func (svc *Avatar) MaxSize() int64 {
+	return svc.maxSize
+}
+
+// Upload 上传头像，返回各尺寸的访问链接 (旧头像删除)，只能改数据范围里的账号
+func (svc *Avatar) Upload(ctx *service.Ctx, id uint64, data []byte) (map[int]string, *errs.CodeErrs) {
+	if int64(len(data)) > svc.maxSize {
+		return nil, errs.Match2(msg.ErrIdAccountAvatarLarge)
+	}
+	// 按内容判断类型，不信任扩展名
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	if _, ok := file.SniffImage(head); !ok {
+		return nil, errs.Match2(msg.ErrIdAccountAvatarFormat)
+	}
+	img, _, e := file.DecodeImage(data)
+	if e != nil {
+		return nil, errs.Match2(msg.ErrIdAccountAvatarFormat)
+	}
+
+	exist, err := selectAccountScoped(ctx, svc.dbsAccount, id)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || !exist.CanLogin() {
+		return nil, errs.Match2("账号不存在")
+	}
+
+	// 按EXIF方向转正+裁剪+缩放+重新编码 (EXIF等元数据不会带过去)
+	img = file.OrientImage(img, file.ImageOrientation(data))
+	storeCtx := ctx.Context()
+	avatarID := time.Now().UnixMilli()
+	square := file.CropSquare(img)
+	side := square.Bounds().Dx()
+	base := ""
+	for _, size := range svc.sizes {
+		pixels := size
+		if pixels > side {
+			pixels = side // 不放大，key还是用标准尺寸
+		}
+		buf := &bytes.Buffer{}
+		mime, e := file.EncodeImage(buf, file.ResizeImage(square, pixels, pixels), svc.quality)
+		if e != nil {
+			return nil, errs.Match(e).Real()
+		}
+		if len(base) <= 0 {
+			base = svc.baseKey(exist, avatarID, mime)
+		}
+		if e = svc.store.Put(storeCtx, avatarSizeKey(base, size), buf.Bytes(), mime); e != nil {
+			log.Error("■ ■ Avatar ■ ■ 上传失败", log.FUint64("accountId", exist.ID), log.FError(e))
+			return nil, errs.Match(e).Real()
+		}
+	}
+
+	oldBase, hasOld := exist.GetAvatarUrl()
+	exist.SetAvatarID(&avatarID)
+	exist.SetAvatarUrl(&base)
+	err = svc.dbsAccount.Update(exist)
+	if err != nil {
+		return nil, err
+	}
+	if hasOld && (oldBase != base) {
+		svc.remove(storeCtx, oldBase)
+	}
+	return svc.URLs(exist), nil
+}
+
+// Get 查询头像的访问链接
+func (svc *Avatar) Get(id uint64) (map[int]string, *errs.CodeErrs) {
+	exist, err := svc.dbsAccount.SelectByID(id)
+	if err != nil {
+		return nil, err
+	} else if exist == nil {
+		return nil, errs.Match2("账号不存在")
+	}
+	return svc.URLs(exist), nil
+}
+
+// URLs 各尺寸的访问链接 (签名，有效期内可访问)，没有头像返回空
+func (svc *Avatar) URLs(account *model.Account) map[int]string {
+	urls := make(map[int]string)
+	base, ok := account.GetAvatarUrl()
+	if !ok || (len(base) <= 0) {
+		return urls
+	}
+	for _, size := range svc.sizes {
+		url, e := svc.store.SignURL(avatarSizeKey(base, size), svc.expires)
+		if e != nil {
+			log.Warn("■ ■ Avatar ■ ■ 签名失败", log.FString("key", base), log.FError(e))
+			continue
+		}
+		urls[size] = url
+	}
+	return urls
+}
+
+// remove 删除旧头像的所有尺寸 (失败不影响)
+func (svc *Avatar) remove(ctx context.Context, base string) {
+	for _, size := range svc.sizes {
+		if e := svc.store.Delete(ctx, avatarSizeKey(base, size)); e != nil {
+			log.Warn("■ ■ Avatar ■ ■ 删除旧头像失败", log.FString("key", base), log.FError(e))
+		}
+	}
+}
+
+// baseKey avatars/{ownKind}/{ownId}/{accountId}/{avatarId}.{ext}
+func (svc *Avatar) baseKey(account *model.Account, avatarID int64, mime string) string {
+	ext := ".jpg"
+	if mime == file.MimePNG {
+		ext = ".png"
+	}
+	return fmt.Sprintf("avatars/%d/%d/%d/%d%s", account.OwnKind, account.OwnID, account.ID, avatarID, ext)
+}
+
+// avatarSizeKey 各尺寸的key ({avatarId}_{size}.{ext})
+func avatarSizeKey(base string, size int) string {
+	ext := path.Ext(base)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(base, ext), size, ext)
+}
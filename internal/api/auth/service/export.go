@@ -0,0 +1,316 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/file"
+	"katydid-mp-user/pkg/log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	exportDirDef         = "exports"      // 默认导出目录
+	exportExpiresDef     = 24 * time.Hour // 默认下载链接有效期
+	exportConcurrencyDef = 2              // 默认同时打包数量
+	exportAccessPageSize = 1000           // 访问记录分页读取数量
+	exportRunTimeout     = time.Hour      // 超过这个时间还没完成的，当作中断了 (可以重新申请)
+	exportCleanBatch     = 100            // 每次清理过期文件的数量
+)
+
+type (
+	// Export 个人数据导出服务 (GDPR/PIPL)
+	Export struct {
+		*service.Base
+
+		dbs        *storage.Export
+		dbsAccount *storage.Account
+		dbsAuth    *storage.Auth
+		dbsToken   *storage.Token
+		dbsAccess  *storage.Access
+		dbsVerify  *storage.Verify
+
+		dir     string        // 导出文件目录
+		secret  []byte        // 下载链接签名密钥
+		expires time.Duration // 下载链接有效期
+		workers chan struct{} // 同时打包的数量限制
+	}
+)
+
+func NewExport(
+	db *storage.Export, dbAccount *storage.Account, dbAuth *storage.Auth,
+	dbToken *storage.Token, dbAccess *storage.Access, dbVerify *storage.Verify,
+	dir, secretKey string, expires time.Duration, concurrency int,
+) *Export {
+	if len(dir) <= 0 {
+		dir = exportDirDef
+	}
+	if expires <= 0 {
+		expires = exportExpiresDef
+	}
+	if concurrency <= 0 {
+		concurrency = exportConcurrencyDef
+	}
+	secret := []byte(secretKey)
+	if len(secret) <= 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+		log.Warn("■ ■ Export ■ ■ 没有配置签名密钥，重启后下载链接失效")
+	}
+	return &Export{
+		Base:       service.NewBase(nil),
+		dbs:        db,
+		dbsAccount: dbAccount.System(), // 后台打包没有操作者，申请时先selectAccountScoped
+		dbsAuth:    dbAuth,
+		dbsToken:   dbToken.System(),
+		dbsAccess:  dbAccess.System(),
+		dbsVerify:  dbVerify,
+		dir:        dir,
+		secret:     secret,
+		expires:    expires,
+		workers:    make(chan struct{}, concurrency),
+	}
+}
+
+// Apply 申请导出 (异步打包，轮询进度)，只能导出数据范围里的账号
+func (svc *Export) Apply(ctx *service.Ctx, accountID uint64) (*model.Export, *errs.CodeErrs) {
+	account, err := selectAccountScoped(ctx, svc.dbsAccount, accountID)
+	if err != nil {
+		return nil, err
+	} else if account == nil {
+		return nil, errs.Match2("账号不存在")
+	}
+
+	// 同一个账号，正在打包的不能重复申请 (并发申请时串行，检查+写入在锁的事务里)
+	entity := model.NewExport(account.OwnKind, account.OwnID, account.ID)
+	key := fmt.Sprintf("export:%d", account.ID)
+	err = svc.dbsAccount.LockQuota(key, func(txCtx context.Context) *errs.CodeErrs {
+		dbs := svc.dbs.WithContext(txCtx)
+		since := time.Now().Add(-exportRunTimeout).UnixMilli()
+		running, e := dbs.SelectRunning(account.ID, since)
+		if e != nil {
+			return e
+		} else if running != nil {
+			return errs.Match2("导出正在进行中")
+		}
+		return dbs.Insert(entity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	go svc.run(entity)
+	return entity, nil
+}
+
+// Get 查询导出进度，只能查数据范围里的账号的
+func (svc *Export) Get(ctx *service.Ctx, id, accountID uint64) (*model.Export, *errs.CodeErrs) {
+	account, err := selectAccountScoped(ctx, svc.dbsAccount, accountID)
+	if err != nil {
+		return nil, err
+	} else if account == nil {
+		return nil, errs.Match2("导出不存在")
+	}
+	exist, err := svc.dbs.SelectByID(id)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || (exist.AccountID != accountID) {
+		return nil, errs.Match2("导出不存在")
+	}
+	return exist, nil
+}
+
+// Sign 下载链接签名 (id+过期时间)
+func (svc *Export) Sign(exist *model.Export) string {
+	mac := hmac.New(sha256.New, svc.secret)
+	mac.Write([]byte(fmt.Sprintf("%d:%d", exist.ID, exist.ExpireAt)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Download 校验下载链接，返回导出文件
+func (svc *Export) Download(id uint64, expireAt int64, sign string) (*model.Export, *errs.CodeErrs) {
+	if expireAt <= time.Now().UnixMilli() {
+		return nil, errs.Match2("下载链接已过期")
+	}
+	exist, err := svc.dbs.SelectByID(id)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || (exist.ExpireAt != expireAt) {
+		return nil, errs.Match2("下载链接无效")
+	}
+	if !hmac.Equal([]byte(svc.Sign(exist)), []byte(sign)) {
+		return nil, errs.Match2("下载链接无效")
+	} else if !exist.IsDownloadable(time.Now().UnixMilli()) || !file.IsFile(exist.FilePath) {
+		return nil, errs.Match2("导出文件不存在")
+	}
+	return exist, nil
+}
+
+// run 打包导出 (失败了记录状态，不重试)
+func (svc *Export) run(entity *model.Export) {
+	svc.workers <- struct{}{}
+	defer func() { <-svc.workers }()
+
+	entity.Status = model.ExportStatusRunning
+	svc.progress(entity, 0)
+
+	path, err := svc.build(entity)
+	if err != nil {
+		log.Error("■ ■ Export ■ ■ 打包失败", log.FUint64("accountId", entity.AccountID), log.FError(err))
+		entity.Status = model.ExportStatusFail
+		_ = os.Remove(path)
+		svc.progress(entity, entity.Progress)
+		return
+	}
+
+	size, _ := file.GetSize(path)
+	entity.FilePath = path
+	entity.FileSize = size
+	entity.ExpireAt = time.Now().Add(svc.expires).UnixMilli()
+	entity.Status = model.ExportStatusDone
+	svc.progress(entity, 100)
+
+	// 顺便清理过期的导出文件
+	svc.cleanExpired()
+}
+
+// cleanExpired 删除下载已过期的导出文件 (记录保留，清空文件路径)
+func (svc *Export) cleanExpired() {
+	list, err := svc.dbs.SelectsExpired(time.Now().UnixMilli(), exportCleanBatch)
+	if err != nil {
+		log.Warn("■ ■ Export ■ ■ 查询过期导出失败", log.FError(err))
+		return
+	}
+	for _, exist := range list {
+		if e := os.Remove(exist.FilePath); (e != nil) && !os.IsNotExist(e) {
+			log.Warn("■ ■ Export ■ ■ 删除过期文件失败", log.FUint64("id", exist.ID), log.FError(e))
+			continue
+		}
+		exist.FilePath = ""
+		if err = svc.dbs.Update(exist); err != nil {
+			log.Warn("■ ■ Export ■ ■ 更新过期导出失败", log.FUint64("id", exist.ID), log.FError(err))
+		}
+	}
+}
+
+// build 收集账号数据，写入zip (每类数据一个json)
+func (svc *Export) build(entity *model.Export) (string, error) {
+	err := file.CreateDir(svc.dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(svc.dir, fmt.Sprintf("export_%d_%d.zip", entity.AccountID, entity.ID))
+	f, err := os.Create(path)
+	if err != nil {
+		return path, err
+	}
+	defer f.Close()
+	writer := zip.NewWriter(f)
+
+	// 账号
+	account, cErr := svc.dbsAccount.SelectByID(entity.AccountID)
+	if cErr != nil {
+		return path, cErr
+	} else if account == nil {
+		return path, errs.Match2("账号不存在")
+	}
+	extra := account.Extra
+	accountCopy := *account
+	accountCopy.Auths = nil // 认证单独导出 (掩码)
+	if err = svc.writeJson(writer, "account.json", &accountCopy); err != nil {
+		return path, err
+	} else if err = svc.writeJson(writer, "extra.json", extra); err != nil {
+		return path, err
+	}
+	svc.progress(entity, 20)
+
+	// 认证
+	auths, cErr := svc.dbsAuth.SelectsByAccount(entity.AccountID)
+	if cErr != nil {
+		return path, cErr
+	}
+	authList := make([]map[string]any, 0, len(auths))
+	for _, iAuth := range auths {
+		authList = append(authList, model.ExportAuth(iAuth))
+	}
+	if err = svc.writeJson(writer, "auths.json", authList); err != nil {
+		return path, err
+	}
+	svc.progress(entity, 40)
+
+	// 会话
+	tokens, cErr := svc.dbsToken.SelectsByAccount(entity.AccountID)
+	if cErr != nil {
+		return path, cErr
+	}
+	tokenList := make([]map[string]any, 0, len(tokens))
+	for _, token := range tokens {
+		tokenList = append(tokenList, model.ExportToken(token))
+	}
+	if err = svc.writeJson(writer, "tokens.json", tokenList); err != nil {
+		return path, err
+	}
+	svc.progress(entity, 55)
+
+	// 验证记录
+	verifies, cErr := svc.dbsVerify.SelectsByAuths(entity.OwnKind, entity.OwnID, auths)
+	if cErr != nil {
+		return path, cErr
+	}
+	verifyList := make([]map[string]any, 0, len(verifies))
+	for _, verify := range verifies {
+		verifyList = append(verifyList, model.ExportVerify(verify))
+	}
+	if err = svc.writeJson(writer, "verifies.json", verifyList); err != nil {
+		return path, err
+	}
+	svc.progress(entity, 70)
+
+	// 访问记录 (分页读取)
+	accesses := make([]*model.Access, 0)
+	for cursor := uint64(0); ; {
+		list, cErr := svc.dbsAccess.SelectsCursorByAccount(entity.AccountID, cursor, exportAccessPageSize)
+		if cErr != nil {
+			return path, cErr
+		}
+		accesses = append(accesses, list.List...)
+		if !list.More {
+			break
+		}
+		cursor = list.Cursor
+	}
+	if err = svc.writeJson(writer, "accesses.json", accesses); err != nil {
+		return path, err
+	}
+	svc.progress(entity, 90)
+
+	return path, writer.Close()
+}
+
+func (svc *Export) writeJson(writer *zip.Writer, name string, data any) error {
+	w, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// progress 更新进度 (失败不影响打包)
+func (svc *Export) progress(entity *model.Export, progress int) {
+	entity.Progress = progress
+	if err := svc.dbs.Update(entity); err != nil {
+		log.Warn("■ ■ Export ■ ■ 更新进度失败", log.FUint64("id", entity.ID), log.FError(err))
+	}
+}
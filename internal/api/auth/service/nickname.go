@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/words"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// Nickname 昵称服务 (长度/敏感词/唯一占用/修改频率)
+	Nickname struct {
+		*service.Base
+
+		dbs *storage.Nickname
+
+		matcher *words.Matcher // 敏感词 (nil不过滤)
+	}
+)
+
+func NewNickname(db *storage.Nickname, matcher *words.Matcher) *Nickname {
+	return &Nickname{
+		Base:    service.NewBase(nil),
+		dbs:     db,
+		matcher: matcher,
+	}
+}
+
+// withContext 绑定事务上下文的副本
+func (svc *Nickname) withContext(ctx context.Context) *Nickname {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	return &tx
+}
+
+// Check 检查昵称 (必填/长度/敏感词)，会去掉首尾空白，返回归一化后的昵称 (没有昵称返回空)
+func (svc *Nickname) Check(account *model.Account) (string, *errs.CodeErrs) {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if account.Nickname != nil {
+		nickname := strings.TrimSpace(*account.Nickname)
+		account.Nickname = &nickname
+		if len(nickname) <= 0 {
+			account.Nickname = nil
+		}
+	}
+	if account.Nickname == nil {
+		if limit.NicknameRequire {
+			return "", errs.Match2(msg.ErrIdAccountNicknameRequired)
+		}
+		return "", nil
+	}
+
+	length := utf8.RuneCountInString(*account.Nickname)
+	minLen, maxLen := limit.NicknameLenRange[0], limit.NicknameLenRange[1]
+	if ((minLen > 0) && (length < minLen)) || ((maxLen > 0) && (length > maxLen)) {
+		return "", errs.Match2(msg.ErrIdAccountNicknameLength)
+	}
+	if (svc.matcher != nil) && svc.matcher.Contains(*account.Nickname) {
+		return "", errs.Match2(msg.ErrIdAccountNicknameSensitive)
+	}
+
+	normal := words.Normalize(*account.Nickname)
+	if len(normal) <= 0 {
+		return "", errs.Match2(msg.ErrIdAccountNicknameRequired) // 全是不可见字符
+	}
+	return normal, nil
+}
+
+// CheckExist 提前查一下是否被别人占用 (不保证，最终以Reserve为准)
+func (svc *Nickname) CheckExist(account *model.Account, normal string) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if !limit.NicknameUnique || (len(normal) <= 0) {
+		return nil
+	}
+	exist, err := svc.dbs.SelectByNormal(account.OwnKind, account.OwnID, normal)
+	if err != nil {
+		return err
+	} else if (exist != nil) && (exist.AccountID != account.ID) {
+		return errs.Match2(msg.ErrIdAccountNicknameExists)
+	}
+	return nil
+}
+
+// Reserve 占用昵称 (靠唯一索引防并发，同一账号重复占用算成功)，并释放账号之前的昵称
+func (svc *Nickname) Reserve(account *model.Account, normal string) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if !limit.NicknameUnique {
+		return nil
+	}
+	if len(normal) > 0 {
+		ok, err := svc.dbs.Reserve(model.NewNickname(account.OwnKind, account.OwnID, normal, account.ID))
+		if err != nil {
+			return err
+		} else if !ok {
+			exist, err := svc.dbs.SelectByNormal(account.OwnKind, account.OwnID, normal)
+			if err != nil {
+				return err
+			} else if (exist == nil) || (exist.AccountID != account.ID) {
+				return errs.Match2(msg.ErrIdAccountNicknameExists)
+			}
+		}
+	}
+	return svc.dbs.ReleaseByAccount(account.OwnKind, account.OwnID, account.ID, normal)
+}
+
+// Release 释放账号占用的所有昵称 (注销清除时)
+func (svc *Nickname) Release(account *model.Account) *errs.CodeErrs {
+	return svc.dbs.ReleaseByAccount(account.OwnKind, account.OwnID, account.ID, "")
+}
+
+// CheckInterval 检查修改频率 (NicknameInterval)
+func (svc *Nickname) CheckInterval(account *model.Account, now time.Time) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if limit.NicknameInterval <= 0 {
+		return nil
+	}
+	nicknameAt, ok := account.GetNicknameAt()
+	if ok && (now.UnixMilli() < nicknameAt+limit.NicknameInterval*1000) {
+		return errs.Match2(msg.ErrIdAccountNicknameFrequent)
+	}
+	return nil
+}
+
+// Default 重置用的默认昵称 (用ID，不会重复)
+func (svc *Nickname) Default(account *model.Account) string {
+	return fmt.Sprintf("user%d", account.ID)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/pkg/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	noticeConcurrency = 16 // 同时发送的通知数 (满了丢弃，不阻塞登录)
+)
+
+type (
+	// Notice 账号通知服务 (异地/新设备登录等)，发给账号绑定的手机/邮箱
+	Notice struct {
+		dbsAuth *storage.Auth
+		sender  INoticeSender
+		timeout time.Duration
+
+		sem chan struct{}
+	}
+
+	// INoticeSender 通知发送渠道 (短信/邮件网关)
+	INoticeSender interface {
+		Send(ctx context.Context, msg *NoticeMsg) error
+	}
+
+	// NoticeMsg 通知内容
+	NoticeMsg struct {
+		Kind      string         `json:"kind"`      // 通知类型 (login_risk)
+		AuthKind  model.AuthKind `json:"authKind"`  // 发送渠道 (手机/邮箱)
+		Target    string         `json:"target"`    // 手机号/邮箱
+		AccountID uint64         `json:"accountId"` // 账号ID
+		Title     string         `json:"title"`
+		Content   string         `json:"content"`
+		Data      map[string]any `json:"data"` // 模板参数
+	}
+
+	// NoticeWebhook 通过webhook发送 (POST json，由通知网关转成短信/邮件)
+	NoticeWebhook struct {
+		url    string
+		client *http.Client
+	}
+
+	// NoticeLog 只打日志 (没配置网关时)
+	NoticeLog struct{}
+)
+
+const (
+	NoticeKindLoginRisk = "login_risk" // 登录风险提醒
+)
+
+func NewNotice(dbsAuth *storage.Auth, sender INoticeSender, timeout time.Duration) *Notice {
+	if sender == nil {
+		sender = &NoticeLog{}
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Notice{
+		dbsAuth: dbsAuth,
+		sender:  sender,
+		timeout: timeout,
+		sem:     make(chan struct{}, noticeConcurrency),
+	}
+}
+
+func NewNoticeWebhook(url string, timeout time.Duration) *NoticeWebhook {
+	return &NoticeWebhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// OnLoginRisk 登录风险通知 (给Risk.OnNotify用)，异步发送，不影响登录
+func (svc *Notice) OnLoginRisk(access *model.Access, result *RiskResult) {
+	if (access == nil) || (result == nil) {
+		return
+	}
+	select {
+	case svc.sem <- struct{}{}:
+	default:
+		log.Warn("■ ■ Notice ■ ■ 通知太多，丢弃", log.FUint64("accountId", access.AccountID))
+		return
+	}
+	go func() {
+		defer func() { <-svc.sem }()
+		svc.notifyLoginRisk(access, result)
+	}()
+}
+
+// notifyLoginRisk 发给账号绑定的手机/邮箱 (已激活的)
+func (svc *Notice) notifyLoginRisk(access *model.Access, result *RiskResult) {
+	auths, err := svc.dbsAuth.SelectsByAccount(access.AccountID)
+	if err != nil {
+		log.Warn("■ ■ Notice ■ ■ 查询账号认证失败", log.FUint64("accountId", access.AccountID), log.FError(err))
+		return
+	}
+	flags := make([]string, 0, len(result.Flags))
+	for _, flag := range result.Flags {
+		flags = append(flags, string(flag))
+	}
+	data := map[string]any{
+		"time":       access.AccessTime().Format(time.DateTime),
+		"ip":         access.IP,
+		"deviceName": access.DeviceName,
+		"platform":   access.Platform,
+		"flags":      flags,
+		"score":      result.Score,
+	}
+	content := fmt.Sprintf("您的账号于 %s 在新的环境登录 (ip: %s，设备: %s)，如非本人操作请尽快修改密码",
+		data["time"], access.IP, access.DeviceName)
+
+	sent := 0
+	for _, iAuth := range auths {
+		kind := iAuth.GetKind()
+		if ((kind != model.AuthKindCellphone) && (kind != model.AuthKindEmail)) || !iAuth.IsActive() {
+			continue
+		}
+		msg := &NoticeMsg{
+			Kind:      NoticeKindLoginRisk,
+			AuthKind:  kind,
+			Target:    iAuth.GetTarget(),
+			AccountID: access.AccountID,
+			Title:     "登录提醒",
+			Content:   content,
+			Data:      data,
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), svc.timeout)
+		err := svc.sender.Send(ctx, msg)
+		cancel()
+		if err != nil {
+			log.Warn("■ ■ Notice ■ ■ 发送通知失败",
+				log.FUint64("accountId", access.AccountID),
+				log.FInt16("authKind", int16(kind)),
+				log.FError(err),
+			)
+			continue
+		}
+		sent++
+	}
+	if sent <= 0 {
+		log.Info("■ ■ Notice ■ ■ 没有可通知的手机/邮箱", log.FUint64("accountId", access.AccountID),
+			log.FString("flags", strings.Join(flags, ",")))
+	}
+}
+
+func (s *NoticeWebhook) Send(ctx context.Context, msg *NoticeMsg) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if (resp.StatusCode < http.StatusOK) || (resp.StatusCode >= http.StatusMultipleChoices) {
+		return fmt.Errorf("notice webhook status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *NoticeLog) Send(_ context.Context, msg *NoticeMsg) error {
+	log.Info("■ ■ Notice ■ ■ 通知",
+		log.FString("kind", msg.Kind),
+		log.FUint64("accountId", msg.AccountID),
+		log.FInt16("authKind", int16(msg.AuthKind)),
+		log.FString("content", msg.Content),
+	)
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"katydid-mp-user/pkg/log"
+	"sync"
+	"time"
+)
+
+const (
+	purgeBatchSizeDef = 100       // 默认单次清除数量
+	purgeIntervalDef  = time.Hour // 默认清除间隔
+)
+
+type (
+	// AccountPurger 注销账号定时清除 (冷静期过后)
+	AccountPurger struct {
+		svc *Account
+
+		batchSize int           // 单次清除数量
+		interval  time.Duration // 清除间隔
+
+		stopCh chan struct{}
+		doneCh chan struct{}
+		once   sync.Once
+	}
+)
+
+// NewAccountPurger 创建注销账号清除器 (需要Start)
+func NewAccountPurger(svc *Account, batchSize int, interval time.Duration) *AccountPurger {
+	if batchSize <= 0 {
+		batchSize = purgeBatchSizeDef
+	}
+	if interval <= 0 {
+		interval = purgeIntervalDef
+	}
+	return &AccountPurger{
+		svc:       svc,
+		batchSize: batchSize,
+		interval:  interval,
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+}
+
+// Start 启动清除协程
+func (p *AccountPurger) Start() {
+	go p.loop()
+}
+
+// Stop 停止清除
+func (p *AccountPurger) Stop() {
+	p.once.Do(func() {
+		close(p.stopCh)
+		<-p.doneCh
+	})
+}
+
+func (p *AccountPurger) loop() {
+	defer close(p.doneCh)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.purge()
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// purge 一直清除到没有过期的注销账号
+func (p *AccountPurger) purge() {
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+		count, err := p.svc.PurgeExpired(p.batchSize)
+		if err != nil {
+			log.Error("■ ■ Purge ■ ■ 查询注销账号失败", log.FError(err))
+			return
+		} else if count > 0 {
+			log.Info("■ ■ Purge ■ ■ 清除注销账号", log.FInt("count", count))
+		}
+		if count < p.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"slices"
+)
+
+type (
+	// Quota 账号数量配额 (单认证/单用户可创建的账号数)
+	Quota struct {
+		*service.Base
+
+		dbsAccount *storage.Account
+	}
+)
+
+func NewQuota(dbAccount *storage.Account) *Quota {
+	return &Quota{
+		Base:       service.NewBase(nil),
+		dbsAccount: dbAccount,
+	}
+}
+
+// withContext 绑定事务上下文的副本
+func (svc *Quota) withContext(ctx context.Context) *Quota {
+	tx := *svc
+	tx.dbsAccount = svc.dbsAccount.WithContext(ctx)
+	return &tx
+}
+
+// LockAuth 同一认证的注册/绑定串行 (检查+写入都要在fn里，用ctx的事务)
+func (svc *Quota) LockAuth(iAuth model.IAuth, fn func(ctx context.Context) *errs.CodeErrs) *errs.CodeErrs {
+	key := fmt.Sprintf("quota:auth:%d:%s", iAuth.GetKind(), iAuth.GetTarget())
+	return svc.dbsAccount.LockQuota(key, fn)
+}
+
+// LockUser 同一用户的开通/绑定串行 (检查+写入都要在fn里，用ctx的事务)
+func (svc *Quota) LockUser(userID uint64, fn func(ctx context.Context) *errs.CodeErrs) *errs.CodeErrs {
+	key := fmt.Sprintf("quota:user:%d", userID)
+	return svc.dbsAccount.LockQuota(key, fn)
+}
+
+// CheckAuth 认证还能不能再关联一个账号 (MaxPerAuthXxx，MaxPerAuthShare时算上共享的own)
+func (svc *Quota) CheckAuth(account *model.Account, iAuth model.IAuth) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	max, msgID, ok := svc.authMax(limit, iAuth.GetKind())
+	if !ok || (max < 0) {
+		return nil
+	} else if max == 0 {
+		return errs.Match2(msg.ErrIdAccountQuotaClosed)
+	}
+
+	owns := svc.owns(account, limit, limit.MaxPerAuthShare)
+	count, err := svc.dbsAccount.SelectCountByAuth(iAuth, owns, account.ID)
+	if err != nil {
+		return err
+	} else if count >= max {
+		return errs.Match2(msgID)
+	}
+	return nil
+}
+
+// CheckUser 用户还能不能再关联一个账号 (MaxPerUser，MaxPerUserShare时算上共享的own)
+func (svc *Quota) CheckUser(account *model.Account, userID uint64) *errs.CodeErrs {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if limit.MaxPerUser < 0 {
+		return nil
+	} else if limit.MaxPerUser == 0 {
+		return errs.Match2(msg.ErrIdAccountQuotaUser)
+	}
+
+	owns := svc.owns(account, limit, limit.MaxPerUserShare)
+	count, err := svc.dbsAccount.SelectCountByUser(userID, owns, account.ID)
+	if err != nil {
+		return err
+	} else if count >= limit.MaxPerUser {
+		return errs.Match2(msg.ErrIdAccountQuotaUser)
+	}
+	return nil
+}
+
+// authMax 认证类型对应的上限，密码不限制 (username在own里唯一)
+func (svc *Quota) authMax(limit *service.LimitAccount, authKind model.AuthKind) (int, string, bool) {
+	switch {
+	case authKind == model.AuthKindCellphone:
+		return limit.MaxPerAuthCellphone, msg.ErrIdAccountQuotaCellphone, true
+	case authKind == model.AuthKindEmail:
+		return limit.MaxPerAuthEmail, msg.ErrIdAccountQuotaEmail, true
+	case (authKind >= model.AuthKindBioFace) && (authKind < model.AuthKindThirdGoogle):
+		return limit.MaxPerAuthBio, msg.ErrIdAccountQuotaBio, true
+	case authKind >= model.AuthKindThirdGoogle:
+		return limit.MaxPerAuthThird, msg.ErrIdAccountQuotaThird, true
+	}
+	return 0, "", false
+}
+
+// owns 计数范围，自己的own，共享时加上TokenShares里的own (同类型的也是不同的own，不能覆盖)
+func (svc *Quota) owns(account *model.Account, limit *service.LimitAccount, share bool) []model.Own {
+	owns := []model.Own{{Kind: account.OwnKind, ID: account.OwnID}}
+	if !share {
+		return owns
+	}
+	for ownKind, ownID := range limit.TokenShares {
+		own := model.Own{Kind: model.OwnKind(ownKind), ID: ownID}
+		if !slices.Contains(owns, own) {
+			owns = append(owns, own)
+		}
+	}
+	return owns
+}
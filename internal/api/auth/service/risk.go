@@ -0,0 +1,160 @@
+package service
+
+import (
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"strings"
+)
+
+type (
+	// Risk 登录风控服务
+	Risk struct {
+		*service.Base
+
+		dbsAccess *storage.Access
+
+		OnNotify func(access *model.Access, result *RiskResult) // 通知用户 (异地/新设备登录等)
+	}
+
+	// RiskResult 风控评估结果
+	RiskResult struct {
+		Score  int              `json:"score"`  // 风险分
+		Flags  []model.RiskFlag `json:"flags"`  // 风险标记
+		Action RiskAction       `json:"action"` // 处理动作
+	}
+
+	// RiskAction 风控处理动作
+	RiskAction int8
+)
+
+const (
+	RiskActionNone   RiskAction = 0 // 放行
+	RiskActionNotify RiskAction = 1 // 通知用户
+	RiskActionVerify RiskAction = 2 // 需要二次验证
+	RiskActionBlock  RiskAction = 3 // 拦截
+)
+
+func NewRisk(dbsAccess *storage.Access) *Risk {
+	return &Risk{
+		Base:      service.NewBase(nil),
+		dbsAccess: dbsAccess.System(), // 登录时还没有操作者
+	}
+}
+
+// Evaluate 评估登录风险，并把风险分/标记写入access
+func (svc *Risk) Evaluate(access *model.Access) (*RiskResult, *errs.CodeErrs) {
+	result := &RiskResult{Action: RiskActionNone}
+	if access == nil {
+		return result, nil
+	}
+	limit := svc.GetLimitRisk(int16(access.OwnKind), access.OwnID)
+	if !limit.Enable || (limit.HistorySize <= 0) {
+		return result, nil
+	}
+
+	// 最近的登录记录 (时间倒序)
+	histories, err := svc.dbsAccess.SelectsByAccount(access.AccountID,
+		[]model.AccessKind{model.EntryKindLogin}, limit.HistorySize)
+	if err != nil {
+		return nil, err
+	} else if len(histories) <= 0 {
+		return result, nil // 首次登录，没有对比
+	}
+
+	if svc.isImpossibleTravel(limit, access, histories) {
+		result.Score += limit.ScoreTravel
+		result.Flags = append(result.Flags, model.RiskFlagTravel)
+	}
+	if svc.isNewCountry(access, histories) {
+		result.Score += limit.ScoreCountry
+		result.Flags = append(result.Flags, model.RiskFlagCountry)
+	}
+	if svc.isNewDevice(access, histories) {
+		result.Score += limit.ScoreDevice
+		result.Flags = append(result.Flags, model.RiskFlagDevice)
+	}
+
+	switch {
+	case (limit.ScoreBlock > 0) && (result.Score >= limit.ScoreBlock):
+		result.Action = RiskActionBlock
+	case (limit.ScoreVerify > 0) && (result.Score >= limit.ScoreVerify):
+		result.Action = RiskActionVerify
+	case (limit.ScoreNotify > 0) && (result.Score >= limit.ScoreNotify):
+		result.Action = RiskActionNotify
+	}
+
+	access.RiskScore = result.Score
+	access.RiskFlags = result.Flags
+	if result.Score > 0 {
+		log.Info("■ ■ Risk ■ ■ 登录风险",
+			log.FUint64("accountId", access.AccountID),
+			log.FInt("score", result.Score),
+			log.FInt8("action", int8(result.Action)),
+		)
+	}
+	return result, nil
+}
+
+// Notify 通知用户 (异地/新设备登录等)
+func (svc *Risk) Notify(access *model.Access, result *RiskResult) {
+	if (svc.OnNotify == nil) || (result == nil) || (result.Action == RiskActionNone) {
+		return
+	}
+	svc.OnNotify(access, result)
+}
+
+// isImpossibleTravel 和上次有定位的登录对比，移动速度是否超过上限
+func (svc *Risk) isImpossibleTravel(limit *service.LimitRisk, access *model.Access, histories []*model.Access) bool {
+	if (limit.TravelMaxSpeed <= 0) || (access.Location == nil) || !access.Location.IsValid() {
+		return false
+	}
+	for _, history := range histories {
+		if (history.Location == nil) || !history.Location.IsValid() {
+			continue
+		}
+		distance := access.Location.DistanceTo(history.Location)
+		if distance < limit.TravelMinDistance {
+			return false
+		}
+		seconds := float64(access.CreateAt-history.CreateAt) / 1000
+		if seconds <= 0 {
+			return true // 同一时刻出现在两个地方
+		}
+		return (distance / seconds) > limit.TravelMaxSpeed
+	}
+	return false
+}
+
+// isNewCountry 是否是最近登录中没有出现过的国家
+func (svc *Risk) isNewCountry(access *model.Access, histories []*model.Access) bool {
+	if (access.Location == nil) || (len(access.Location.CountryCode) <= 0) {
+		return false
+	}
+	known := false
+	for _, history := range histories {
+		if (history.Location == nil) || (len(history.Location.CountryCode) <= 0) {
+			continue
+		}
+		known = true
+		if strings.EqualFold(history.Location.CountryCode, access.Location.CountryCode) {
+			return false
+		}
+	}
+	return known // 历史里都没有国家信息时，不算新国家
+}
+
+// isNewDevice 是否是最近登录中没有出现过的设备
+func (svc *Risk) isNewDevice(access *model.Access, histories []*model.Access) bool {
+	if len(access.DeviceID) <= 0 {
+		return false
+	}
+	for _, history := range histories {
+		if history.DeviceID == access.DeviceID {
+			return false
+		}
+	}
+	return true
+}
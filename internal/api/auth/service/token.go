@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/auth"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	roleVerExpires = 30 * time.Second // 账号角色版本缓存 (没有redis通知时，其他节点的角色变更最多晚这么久生效)
+)
+
+type (
+	// Token 令牌服务
+	Token struct {
+		*service.Base
+
+		dbs        *storage.Token
+		dbsAccount *storage.Account
+		dbsAuth    *storage.Auth
+
+		account *Account // 自动开通账号/登录用
+		verify  *Verify  // 验证码登录用
+
+		issuer         string // 签发者
+		jwtSecret      string // JWT密钥
+		accessExpires  int64  // 默认访问token有效期(s)
+		refreshExpires int64  // 默认刷新token有效期(h)
+
+		roleVers *cache.Cache // 账号角色版本缓存 [accountID]roleVer
+
+		OnRoles          func(account *model.Account) (roles, scopes []string) // 签发时解析角色/权限 (账号自带的角色之外)
+		OnRoleVerChanged func(accountIDs []uint64)                             // 角色版本更新后 (通知其他节点清缓存)
+	}
+)
+
+func NewToken(
+	db *storage.Token, dbAccount *storage.Account, dbAuth *storage.Auth,
+	account *Account, verify *Verify,
+	issuer, jwtSecret string, accessExpires, refreshExpires int64,
+) *Token {
+	return &Token{
+		Base:           service.NewBase(nil),
+		dbs:            db.System(), // 登录/刷新还没有操作者
+		dbsAccount:     dbAccount.System(),
+		dbsAuth:        dbAuth,
+		account:        account,
+		verify:         verify,
+		issuer:         issuer,
+		jwtSecret:      jwtSecret,
+		accessExpires:  accessExpires,
+		refreshExpires: refreshExpires,
+		roleVers:       cache.New(roleVerExpires, 2*roleVerExpires),
+	}
+}
+
+// Login 登录 (密码/验证码)，校验凭证后签发token
+// 账号不存在和凭证错误返回一样的错误，不暴露账号是否存在
+func (svc *Token) Login(
+	ownKind model.OwnKind, ownID uint64, iAuth model.IAuth, password, code string,
+	access *model.Access, deviceID string,
+) (*model.Token, *errs.CodeErrs) {
+	account, err := svc.dbsAccount.SelectByOwnAuths(ownKind, ownID, []model.IAuth{iAuth})
+	if err != nil {
+		return nil, err
+	} else if (account == nil) || !svc.account.isAuthKindLogin(account, iAuth.GetKind()) {
+		return nil, errs.Match2("账号或密码错误") // 不支持的登录方式也一样，不暴露账号的认证方式
+	}
+	// 账号绑定的认证 (密码的用户名只在own里唯一，不能全局查)
+	exist, err := svc.dbsAuth.SelectByAccount(account.ID, iAuth.GetKind())
+	if err != nil {
+		return nil, err
+	} else if exist == nil {
+		return nil, errs.Match2("账号或密码错误")
+	}
+
+	// 封禁/锁定的不校验凭证 (锁定期间不能继续试密码)
+	err = svc.account.checkLoginable(account, exist, exist.GetKind() != model.AuthKindPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// 凭证 (验证码登录的算二次验证)
+	verified := false
+	switch exist.GetKind() {
+	case model.AuthKindPassword:
+		pwd, ok := exist.(*model.AuthPassword)
+		if !ok || !pwd.CheckPassword(password) {
+			svc.loginFailed(account, exist)
+			return nil, errs.Match2("账号或密码错误")
+		}
+	case model.AuthKindCellphone, model.AuthKindEmail:
+		if len(code) <= 0 {
+			return nil, errs.Match2("验证码不能为空")
+		}
+		verify := model.NewVerifyEmpty()
+		verify.OwnKind = ownKind
+		verify.OwnID = ownID
+		verify.AuthKind = exist.GetKind()
+		verify.Apply = model.VerifyApplyLogin
+		verify.Target = model.VerifyTarget(iAuth)
+		verify.SetBody(&code)
+		if err = svc.verify.Valid(verify); err != nil {
+			svc.loginFailed(account, exist)
+			return nil, err
+		}
+		verified = true
+	default:
+		return nil, errs.Match2("账号或密码错误")
+	}
+
+	err = svc.account.Login(account, exist, access, verified)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := svc.generate(account, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.dbs.Insert(entity)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("■ ■ Token ■ ■ 登录",
+		log.FUint64("accountId", account.ID),
+		log.FInt16("authKind", int16(exist.GetKind())),
+	)
+	return entity, nil
+}
+
+// Exchange SSO交换，用来源的token换取共享应用的token (LimitAccount.TokenShares)
+// 共享应用下没有账号的，用来源账号的认证自动开通
+func (svc *Token) Exchange(
+	accessToken string, toOwnKind model.OwnKind, toOwnID uint64, deviceID string,
+) (*model.Token, *errs.CodeErrs) {
+	// 来源token
+	claims, _, e := auth.ParseJWT(accessToken, svc.jwtSecret, true)
+	if e != nil {
+		return nil, errs.Match2("token无效")
+	}
+	exist, err := svc.dbs.SelectByAccess(accessToken)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || exist.IsAccessExpired() {
+		return nil, errs.Match2("token无效")
+	}
+
+	// 只认配置的共享关系 (来源 -> 目标)
+	fromOwnKind, fromOwnID := model.OwnKind(claims.OwnKind), claims.OwnID
+	if (fromOwnKind == toOwnKind) && (fromOwnID == toOwnID) {
+		return nil, errs.Match2("不能和自己交换token")
+	} else if !svc.isShared(fromOwnKind, fromOwnID, toOwnKind, toOwnID) {
+		return nil, errs.Match2("应用之间没有共享token")
+	}
+
+	// 来源账号
+	from, err := svc.dbsAccount.SelectByID(claims.AccountID)
+	if err != nil {
+		return nil, err
+	} else if (from == nil) || !from.CanLogin() {
+		return nil, errs.Match2("账号不可用")
+	}
+
+	// 目标账号 (没有则开通)
+	to, err := svc.provision(from, toOwnKind, toOwnID)
+	if err != nil {
+		return nil, err
+	} else if !to.CanLogin() {
+		return nil, errs.Match2("账号不可用")
+	}
+
+	// 生成目标token
+	entity, err := svc.generate(to, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 两个token互相关联，登出时级联 (写入和关联在一个事务里，不留单向的关联)
+	entity.AddLink(exist.ID)
+	err = service.Transaction(svc.dbs.Context(), func(ctx context.Context) *errs.CodeErrs {
+		dbs := svc.dbs.WithContext(ctx)
+		if e := dbs.Insert(entity); e != nil {
+			return e
+		}
+		exist.AddLink(entity.ID)
+		return dbs.Update(exist)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Refresh 刷新token (重新解析角色)，返回新token和要吊销的旧访问token (加黑名单用)
+func (svc *Token) Refresh(refreshToken string, deviceID string) (*model.Token, string, *errs.CodeErrs) {
+	claims, _, e := auth.ParseJWT(refreshToken, svc.jwtSecret, true)
+	if e != nil {
+		return nil, "", errs.Match2("token无效")
+	}
+	exist, err := svc.dbs.SelectByRefresh(refreshToken)
+	if err != nil {
+		return nil, "", err
+	} else if (exist == nil) || exist.IsRefreshExpired() || (exist.AccountID != claims.AccountID) {
+		return nil, "", errs.Match2("token无效")
+	}
+
+	account, err := svc.dbsAccount.SelectByID(exist.AccountID)
+	if err != nil {
+		return nil, "", err
+	} else if (account == nil) || !account.CanLogin() {
+		return nil, "", errs.Match2("账号不可用")
+	}
+
+	if len(deviceID) <= 0 {
+		deviceID = exist.DeviceID
+	}
+	entity, err := svc.generate(account, deviceID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// SSO关联跟着新token走 (两边都要改，不留指向旧token的关联)
+	links, _ := exist.GetLinks()
+	for _, id := range links {
+		entity.AddLink(id)
+	}
+	// 新token写入+旧token删除+关联改指向 在一个事务里，失败整体回滚
+	err = service.Transaction(svc.dbs.Context(), func(ctx context.Context) *errs.CodeErrs {
+		dbs := svc.dbs.WithContext(ctx)
+		if e := dbs.Insert(entity); e != nil {
+			return e
+		} else if e = dbs.Delete(exist.ID, exist.GetDelByUserSelf()); e != nil {
+			return e
+		}
+		for _, id := range links {
+			linked, e := dbs.SelectByID(id)
+			if e != nil {
+				return e
+			} else if (linked == nil) || !linked.ReplaceLink(exist.ID, entity.ID) {
+				continue // 已经登出的
+			}
+			if e = dbs.Update(linked); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	log.Debug("■ ■ Token ■ ■ 刷新token",
+		log.FUint64("accountId", account.ID),
+		log.FInt64("roleVer", entity.RoleVer),
+	)
+	return entity, exist.AccessToken, nil
+}
+
+// BumpRoleVersion 账号角色变更，更新角色版本 (旧版本的token要刷新)，给权限服务用
+// 版本冲突的重新查询再更新，更新完通知其他节点清缓存
+func (svc *Token) BumpRoleVersion(accountIDs ...uint64) {
+	bumped := make([]uint64, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		var ver int64
+		err := service.RetryConflict(func(times int) *errs.CodeErrs {
+			account, e := svc.dbsAccount.SelectByID(accountID)
+			if (e != nil) || (account == nil) {
+				return e
+			}
+			ver = account.BumpRoleVer()
+			return svc.dbsAccount.Update(account)
+		})
+		if err != nil {
+			log.Warn("■ ■ Token ■ ■ 更新角色版本失败", log.FUint64("accountId", accountID), log.FError(err))
+			continue
+		} else if ver <= 0 {
+			continue // 账号不存在
+		}
+		svc.roleVers.Delete(strconv.FormatUint(accountID, 10))
+		bumped = append(bumped, accountID)
+		log.Info("■ ■ Token ■ ■ 更新角色版本", log.FUint64("accountId", accountID), log.FInt64("roleVer", ver))
+	}
+	if (len(bumped) > 0) && (svc.OnRoleVerChanged != nil) {
+		svc.OnRoleVerChanged(bumped)
+	}
+}
+
+// ForgetRoleVersion 清掉账号角色版本的缓存 (其他节点更新了角色版本)
+func (svc *Token) ForgetRoleVersion(accountIDs ...uint64) {
+	for _, accountID := range accountIDs {
+		svc.roleVers.Delete(strconv.FormatUint(accountID, 10))
+	}
+}
+
+// CheckRoleVersion token的角色版本是不是最新的，给认证中间件用
+// 查询失败/账号不存在的当作不是最新的 (不放行)，失败的不缓存
+func (svc *Token) CheckRoleVersion(claims *auth.TokenClaims) bool {
+	key := strconv.FormatUint(claims.AccountID, 10)
+	if ver, ok := svc.roleVers.Get(key); ok {
+		return claims.RoleVer >= ver.(int64)
+	}
+	account, err := svc.dbsAccount.SelectByID(claims.AccountID)
+	if err != nil {
+		log.Warn("■ ■ Token ■ ■ 查询账号失败", log.FUint64("accountId", claims.AccountID), log.FError(err))
+		return false
+	} else if account == nil {
+		return false
+	}
+	ver := account.GetRoleVer()
+	svc.roleVers.SetDefault(key, ver)
+	return claims.RoleVer >= ver
+}
+
+// Logout 登出，级联删除关联的token，返回所有被吊销的访问token (加黑名单用)
+// 记录登出的访问记录 (只记当前的token，级联的不记)
+func (svc *Token) Logout(accessToken string, access *model.Access) ([]string, *errs.CodeErrs) {
+	exist, err := svc.dbs.SelectByAccess(accessToken)
+	if err != nil {
+		return nil, err
+	} else if exist == nil {
+		return []string{accessToken}, nil
+	}
+	svc.recordAccess(exist, access)
+
+	revokes := make([]string, 0)
+	visited := map[uint64]bool{exist.ID: true}
+	queue := []*model.Token{exist}
+	for len(queue) > 0 {
+		token := queue[0]
+		queue = queue[1:]
+
+		err = svc.dbs.Delete(token.ID, token.GetDelByUserSelf())
+		if err != nil {
+			return revokes, err
+		}
+		revokes = append(revokes, token.AccessToken)
+
+		links, _ := token.GetLinks()
+		for _, id := range links {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			link, err := svc.dbs.SelectByID(id)
+			if err != nil {
+				log.Warn("■ ■ Token ■ ■ 查询关联token失败", log.FUint64("id", id), log.FError(err))
+				continue
+			} else if link != nil {
+				queue = append(queue, link)
+			}
+		}
+	}
+	return revokes, nil
+}
+
+// recordAccess 记录token所属账号的访问 (异步)
+func (svc *Token) recordAccess(token *model.Token, access *model.Access) {
+	if (svc.account.recorder == nil) || (access == nil) {
+		return
+	}
+	access.OwnKind = token.OwnKind
+	access.OwnID = token.OwnID
+	access.AccountID = token.AccountID
+	access.UserID = token.UserID
+	svc.account.recorder.Add(access)
+}
+
+// isShared 来源own的共享配置里是否有目标own
+func (svc *Token) isShared(fromOwnKind model.OwnKind, fromOwnID uint64, toOwnKind model.OwnKind, toOwnID uint64) bool {
+	limit := svc.GetLimitAccount(int16(fromOwnKind), fromOwnID)
+	ownID, ok := limit.TokenShares[int16(toOwnKind)]
+	return ok && (ownID == toOwnID)
+}
+
+// provision 查找目标own下的账号，没有则用来源账号的认证开通
+func (svc *Token) provision(from *model.Account, toOwnKind model.OwnKind, toOwnID uint64) (*model.Account, *errs.CodeErrs) {
+	auths, err := svc.dbsAuth.SelectsByAccount(from.ID)
+	if err != nil {
+		return nil, err
+	} else if len(auths) <= 0 {
+		return nil, errs.Match2("没有可共享的认证")
+	}
+	exist, err := svc.dbsAccount.SelectByOwnAuths(toOwnKind, toOwnID, auths)
+	if err != nil {
+		return nil, err
+	} else if exist != nil {
+		return exist, nil
+	}
+
+	// 用目标own可登录的认证开通 (密码的username只能在own里唯一，不共享)
+	entity := model.NewAccountEmpty()
+	entity.OwnKind = toOwnKind
+	entity.OwnID = toOwnID
+	entity.Nickname = from.Nickname
+	for _, iAuth := range auths {
+		if iAuth.GetKind() == model.AuthKindPassword {
+			continue
+		} else if !svc.account.isAuthKindLogin(entity, iAuth.GetKind()) {
+			continue
+		}
+		entity.Auths[iAuth.GetKind()] = iAuth
+		break
+	}
+	if len(entity.Auths) <= 0 {
+		return nil, errs.Match2("没有可共享的认证")
+	}
+	if from.UserID == nil {
+		err = svc.account.Register(entity)
+	} else {
+		// 同一用户并发开通时串行，用户配额检查+写入在锁里 (锁顺序: user -> auth)
+		err = svc.account.quota.LockUser(*from.UserID, func(ctx context.Context) *errs.CodeErrs {
+			acc := svc.account.withContext(ctx)
+			if e := acc.quota.CheckUser(entity, *from.UserID); e != nil {
+				return e
+			} else if e = acc.Register(entity); e != nil {
+				return e
+			}
+			// 注册会清洗掉userID，这里再关联上
+			entity.UserID = from.UserID
+			return svc.dbsAccount.WithContext(ctx).Update(entity)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Info("■ ■ Token ■ ■ SSO开通账号",
+		log.FUint64("fromAccountId", from.ID),
+		log.FInt16("toOwnKind", int16(toOwnKind)),
+		log.FUint64("toOwnId", toOwnID),
+	)
+	return entity, nil
+}
+
+// loginFailed 凭证错误，累计账号和认证的失败次数 (过多锁定)，失败不影响返回的登录错误
+func (svc *Token) loginFailed(account *model.Account, iAuth model.IAuth) {
+	if err := svc.account.LoginFailed(account, iAuth); err != nil {
+		log.Warn("■ ■ Token ■ ■ 记录登录失败出错", log.FUint64("accountId", account.ID), log.FError(err))
+	}
+}
+
+// generate 生成账号的token (有效期优先用limit的)
+func (svc *Token) generate(account *model.Account, deviceID string) (*model.Token, *errs.CodeErrs) {
+	limit := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	accessExpires, refreshExpires := limit.TokenExpires, limit.TokenRefreshExpires
+	if accessExpires == 0 {
+		accessExpires = svc.accessExpires
+	}
+	if refreshExpires == 0 {
+		refreshExpires = svc.refreshExpires
+	}
+
+	entity := model.NewToken(account.OwnKind, account.OwnID, deviceID, account.ID, account.UserID)
+	roles, scopes := svc.roles(account)
+	entity.SetRoles(roles, scopes, account.GetRoleVer())
+	if _, _, ok := entity.Generate(svc.issuer, svc.jwtSecret, accessExpires, refreshExpires); !ok {
+		return nil, errs.Match2("token生成失败")
+	}
+	return entity, nil
+}
+
+// roles 账号的角色 (账号自带的+OnRoles解析的，去重)和权限
+func (svc *Token) roles(account *model.Account) ([]string, []string) {
+	roles, _ := account.GetRoles()
+	roles = slices.Clone(roles)
+	var scopes []string
+	if svc.OnRoles != nil {
+		var resolved []string
+		resolved, scopes = svc.OnRoles(account)
+		for _, role := range resolved {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles, scopes
+}
@@ -35,8 +35,14 @@ func NewVerify(
 func (svc *Verify) Add(param *model.Verify) *errs.CodeErrs {
 	entity := param.Wash()
 
+	// 检查手机区号/邮箱域名 (不给不能注册/绑定的发验证码)
+	err := svc.checkTarget(entity)
+	if err != nil {
+		return err
+	}
+
 	// 生成验证码
-	err := svc.generateBody(entity)
+	err = svc.generateBody(entity)
 	if err != nil {
 		return err
 	}
@@ -51,7 +57,10 @@ func (svc *Verify) Del(param *model.Verify) *errs.CodeErrs {
 // OnSendOk 发送验证码成功
 func (svc *Verify) OnSendOk(exist *model.Verify) *errs.CodeErrs {
 	// 不检查ownerID了
-	exist.SetPending()
+	if !exist.SetPending() {
+		log.Warn("■ ■ Verify ■ ■ 验证码状态不能发送", log.FUint64("id", exist.ID), log.FInt("status", int(exist.Status)))
+		return errs.Match2("验证码状态不能发送")
+	}
 	return svc.dbs.Update(exist)
 }
 
@@ -100,13 +109,17 @@ func (svc *Verify) Valid(param *model.Verify) *errs.CodeErrs {
 	}
 
 	// 检查auth是否存在
-	existAuth, err := svc.dbsAuth.Select(nil) // TODO:GG 根据 AuthKind + Target 查找Auth
+	authParam, ok := model.NewAuthByTarget(exist.AuthKind, exist.Target)
+	if !ok {
+		return nil
+	}
+	existAuth, err := svc.dbsAuth.Select(authParam)
 	if (err != nil) || (existAuth == nil) {
 		return nil
 	}
 	// 更新auth的状态
 	if existAuth.TryActive() {
-		_ = svc.dbsAuth.Update(nil) // TODO:GG 更新auth的status
+		_ = svc.dbsAuth.Update(existAuth)
 	}
 	return nil
 }
@@ -134,20 +147,21 @@ func (svc *Verify) addWithCheck(entity *model.Verify) *errs.CodeErrs {
 	limit := svc.GetLimitVerify(int16(entity.OwnKind), entity.OwnID)
 
 	// 检查添加间隔时间
-	exist, err := svc.dbs.Select(entity) // TODO:GG 根据 OwnKind + OwnID + AuthKind + Apply + Target 查找最近的
+	now := time.Now().UnixMilli()
+	exist, err := svc.dbs.SelectLast(entity.OwnKind, entity.OwnID, entity.AuthKind, entity.Apply, entity.Target)
 	if err != nil {
 		return err
 	} else if exist != nil {
 		// 计算间隔时间，不能小于InsertInterval
-		interval := exist.CreateAt - time.Now().UnixMilli()
+		interval := now - exist.CreateAt
 		if interval < (limit.InsertInterval * 1000) {
 			return errs.Match2(fmt.Sprintf("添加间隔时间不能小于 %d", limit.InsertInterval))
 		}
 	}
 
 	// 检查添加次数
-	_ = time.Now().UnixMilli() - (limit.InsertDuration * 1000)
-	count, err := svc.dbs.SelectCount(entity) // TODO:GG 根据 OwnKind + OwnID + AuthKind + Apply + Target + time(上面) 查找最近的
+	since := now - (limit.InsertDuration * 1000)
+	count, err := svc.dbs.SelectCountSince(entity.OwnKind, entity.OwnID, entity.AuthKind, entity.Apply, entity.Target, since)
 	if err != nil {
 		return err
 	} else if count >= limit.InsertMaxTimes {
@@ -158,10 +172,25 @@ func (svc *Verify) addWithCheck(entity *model.Verify) *errs.CodeErrs {
 	return svc.dbs.Insert(entity)
 }
 
+// checkTarget 检查手机区号/邮箱域名 (target: [code, number] / [username, domain])
+func (svc *Verify) checkTarget(entity *model.Verify) *errs.CodeErrs {
+	if len(entity.Target) != 2 {
+		return nil // 格式由valid检查
+	}
+	limit := svc.GetLimitAuth(int16(entity.OwnKind), entity.OwnID)
+	switch entity.AuthKind {
+	case model.AuthKindCellphone:
+		return checkPhoneCode(limit, entity.Target[0])
+	case model.AuthKindEmail:
+		return checkEmailDomain(limit, entity.Target[1])
+	}
+	return nil
+}
+
 // checkExist 检查验证码是否存在
 func (svc *Verify) checkExist(param *model.Verify) (*model.Verify, *errs.CodeErrs) {
 	// 查找验证码
-	exist, err := svc.dbs.Select(param) // TODO:GG 根据 OwnKind + OwnID + AuthKind + Apply + Target 查找最近的
+	exist, err := svc.dbs.SelectLast(param.OwnKind, param.OwnID, param.AuthKind, param.Apply, param.Target)
 	if err != nil {
 		return nil, err
 	} else if exist == nil {
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/data"
+	"katydid-mp-user/pkg/perm"
+	"strconv"
+)
+
+const (
+	permLimits = "client/limits" // 限制管理的资源 (路由上也声明了，中间件可以不开)
+)
+
+// Limits 拥有者限制管理 (管理员)
+type Limits struct {
+	*handler.Base
+	registry *service.LimitsRegistry
+}
+
+func NewLimits(
+	registry *service.LimitsRegistry,
+) *Limits {
+	return &Limits{
+		Base:     handler.NewBase(nil),
+		registry: registry,
+	}
+}
+
+// Get 查询生效的限制+db覆盖项
+func (a *Limits) Get(c *handler.Ctx) {
+	ownKind, ownID, ok := a.bindOwn(c, perm.PolicyActGet)
+	if !ok {
+		return
+	}
+
+	overrides, err := a.registry.GetOverrides(ownKind, ownID)
+	if err != nil {
+		c.Response400("查询限制失败", err)
+		return
+	}
+	c.Response200(map[string]any{
+		"limits":    a.registry.Get(ownKind, ownID),
+		"overrides": overrides,
+	})
+}
+
+// Put 修改db覆盖项 (整体替换，只传和默认值不一样的)
+func (a *Limits) Put(c *handler.Ctx) {
+	ownKind, ownID, ok := a.bindOwn(c, perm.PolicyActMod)
+	if !ok {
+		return
+	}
+
+	content := data.KSMap{} // 不是结构体，不走valid
+	if e := c.GCtx().ShouldBindJSON(&content); e != nil {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	limits, err := a.registry.Put(ownKind, ownID, content)
+	if err != nil {
+		c.Response400("修改限制失败", err)
+		return
+	}
+	c.Response200(limits)
+}
+
+// GetEffective 生效的限制，以及每个字段来自哪一层 (调试用)
+func (a *Limits) GetEffective(c *handler.Ctx) {
+	ownKind, ownID, ok := a.bindOwn(c, perm.PolicyActGet)
+	if !ok {
+		return
+	}
+
+	explain, err := a.registry.Explain(ownKind, ownID)
+	if err != nil {
+		c.Response400("查询限制失败", err)
+		return
+	}
+	c.Response200(explain)
+}
+
+// PutParent 设置上级 (继承上级的限制)
+func (a *Limits) PutParent(c *handler.Ctx) {
+	ownKind, ownID, ok := a.bindOwn(c, perm.PolicyActMod)
+	if !ok {
+		return
+	}
+
+	body := struct {
+		ParentKind int16  `json:"parentKind"`
+		ParentID   uint64 `json:"parentId"`
+	}{}
+	if e := c.GCtx().ShouldBindJSON(&body); e != nil {
+		c.Response400("invalid_request_format", nil)
+		return
+	}
+	// 上级的限制会被继承，上级也要能管理
+	if (body.ParentKind > 0) && !c.ServiceCtx().CanManageOwn(body.ParentKind, body.ParentID) {
+		c.Response403(msg.ErrIdPermDenied)
+		return
+	}
+	limits, err := a.registry.PutParent(ownKind, ownID, body.ParentKind, body.ParentID)
+	if err != nil {
+		c.Response400("修改限制失败", err)
+		return
+	}
+	c.Response200(limits)
+}
+
+// bindOwn 路径里的own，只能管理自己所属own的 (鉴权中间件只看动作，不看是哪个own)，平台管理员可以管理所有的
+// 还要有 client/limits:act 的权限 (own里的管理员)，鉴权中间件默认不开，这里自己检查
+func (a *Limits) bindOwn(c *handler.Ctx, act string) (int16, uint64, bool) {
+	ownKind, e1 := strconv.ParseInt(c.RequestParam("ownKind", ""), 10, 16)
+	ownID, e2 := strconv.ParseUint(c.RequestParam("ownId", ""), 10, 64)
+	if (e1 != nil) || (e2 != nil) || (ownKind <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return 0, 0, false
+	}
+	ctx := c.ServiceCtx()
+	if !ctx.CanManageOwn(int16(ownKind), ownID) || !ctx.Can(permLimits, act) {
+		c.Response403(msg.ErrIdPermDenied)
+		return 0, 0, false
+	}
+	return int16(ownKind), ownID, true
+}
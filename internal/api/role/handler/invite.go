@@ -0,0 +1,96 @@
+package handler
+
+import (
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/service"
+	"katydid-mp-user/internal/pkg/handler"
+)
+
+type Invite struct {
+	*handler.Base
+	service *service.Invite
+}
+
+func NewInvite(
+	svc *service.Invite,
+) *Invite {
+	return &Invite{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Post 邀请 (邮箱/手机)
+func (a *Invite) Post(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	bind := &struct {
+		Kind   authModel.AuthKind `json:"kind" form:"kind" binding:"required"`
+		Target string             `json:"target" form:"target" binding:"required"`
+		Role   model.MemberRole   `json:"role" form:"role" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	invite, token, err := a.service.Create(id, operatorID(c), bind.Kind, bind.Target, bind.Role)
+	if err != nil {
+		c.Response400("邀请失败", err)
+		return
+	}
+	c.Response200(map[string]any{"invite": invite, "token": token})
+}
+
+// Get 待接受的邀请
+func (a *Invite) Get(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	invites, err := a.service.List(id, operatorID(c))
+	if err != nil {
+		c.Response400("查询邀请失败", err)
+		return
+	}
+	c.Response200(invites)
+}
+
+// Del 撤销邀请
+func (a *Invite) Del(c *handler.Ctx) {
+	id, ok1 := paramID(c, "id")
+	if !ok1 {
+		return
+	}
+	inviteID, ok2 := paramID(c, "inviteId")
+	if !ok2 {
+		return
+	}
+	err := a.service.Revoke(id, operatorID(c), inviteID)
+	if err != nil {
+		c.Response400("撤销邀请失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// PostAccept 接受邀请 (当前账号)
+func (a *Invite) PostAccept(c *handler.Ctx) {
+	bind := &struct {
+		Token string `json:"token" form:"token" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	member, err := a.service.Accept(bind.Token, operatorID(c))
+	if err != nil {
+		c.Response400("接受邀请失败", err)
+		return
+	}
+	c.Response200(member)
+}
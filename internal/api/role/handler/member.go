@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/service"
+	"katydid-mp-user/internal/pkg/handler"
+)
+
+type Member struct {
+	*handler.Base
+	service *service.Member
+}
+
+func NewMember(
+	svc *service.Member,
+) *Member {
+	return &Member{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Get 组织的成员
+func (a *Member) Get(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	members, err := a.service.List(id, operatorID(c))
+	if err != nil {
+		c.Response400("查询成员失败", err)
+		return
+	}
+	c.Response200(members)
+}
+
+// GetMine 当前账号加入的组织
+func (a *Member) GetMine(c *handler.Ctx) {
+	accountID := operatorID(c)
+	if accountID <= 0 {
+		c.Response400("no_login", nil)
+		return
+	}
+	members, err := a.service.Orgs(accountID)
+	if err != nil {
+		c.Response400("查询组织失败", err)
+		return
+	}
+	c.Response200(members)
+}
+
+// PutRole 修改成员角色
+func (a *Member) PutRole(c *handler.Ctx) {
+	id, ok1 := paramID(c, "id")
+	if !ok1 {
+		return
+	}
+	accountID, ok2 := paramID(c, "accountId")
+	if !ok2 {
+		return
+	}
+	bind := &struct {
+		Role model.MemberRole `json:"role" form:"role" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	member, err := a.service.ChangeRole(id, operatorID(c), accountID, bind.Role)
+	if err != nil {
+		c.Response400("修改角色失败", err)
+		return
+	}
+	c.Response200(member)
+}
+
+// Del 移除成员 (自己是退出)
+func (a *Member) Del(c *handler.Ctx) {
+	id, ok1 := paramID(c, "id")
+	if !ok1 {
+		return
+	}
+	accountID, ok2 := paramID(c, "accountId")
+	if !ok2 {
+		return
+	}
+	err := a.service.Remove(id, operatorID(c), accountID)
+	if err != nil {
+		c.Response400("移除成员失败", err)
+		return
+	}
+	c.Response200(nil)
+}
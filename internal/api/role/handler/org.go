@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/pkg/middleware"
+	"strconv"
+)
+
+type Organization struct {
+	*handler.Base
+	service *service.Organization
+}
+
+func NewOrganization(
+	svc *service.Organization,
+) *Organization {
+	return &Organization{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Post 创建组织 (当前账号成为所有者)
+func (a *Organization) Post(c *handler.Ctx) {
+	bind := model.NewOrganizationEmpty()
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	err = a.service.Add(bind, operatorID(c))
+	if err != nil {
+		c.Response400("创建组织失败", err)
+		return
+	}
+	c.Response200(bind)
+}
+
+// Get 查询组织
+func (a *Organization) Get(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	exist, err := a.service.Get(id)
+	if err != nil {
+		c.Response400("查询组织失败", err)
+		return
+	}
+	c.ResponseETag(exist.Version)
+	c.Response200(exist)
+}
+
+// Put 修改资料 (管理员)，带了If-Match的版本不对返回412
+func (a *Organization) Put(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	bind := model.NewOrganizationEmpty()
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	var version *int64
+	if v, ok := c.RequestIfMatch(); ok {
+		version = &v
+	}
+	exist, err := a.service.Update(id, operatorID(c), bind, version)
+	if err != nil {
+		c.Response400("修改组织失败", err)
+		return
+	}
+	c.ResponseETag(exist.Version)
+	c.Response200(exist)
+}
+
+// Del 删除组织 (所有者)
+func (a *Organization) Del(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	err := a.service.Delete(id, operatorID(c))
+	if err != nil {
+		c.Response400("删除组织失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// PutOwner 转让所有者
+func (a *Organization) PutOwner(c *handler.Ctx) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	bind := &struct {
+		AccountID uint64 `json:"accountId" form:"accountId" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	exist, err := a.service.Transfer(id, operatorID(c), bind.AccountID)
+	if err != nil {
+		c.Response400("转让组织失败", err)
+		return
+	}
+	c.Response200(exist)
+}
+
+// operatorID 当前登录的账号 (认证中间件设置)
+func operatorID(c *handler.Ctx) uint64 {
+	return c.GCtx().GetUint64(middleware.AuthKeyAccountID)
+}
+
+// paramID 路径里的ID，不合法直接返回400
+func paramID(c *handler.Ctx, key string) (uint64, bool) {
+	id, e := strconv.ParseUint(c.RequestParam(key, ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return 0, false
+	}
+	return id, true
+}
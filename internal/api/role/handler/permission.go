@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/role/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"katydid-mp-user/pkg/perm"
+	"strconv"
+)
+
+// Permission 权限策略管理 (域是路径里的 ownKind:ownId)
+type Permission struct {
+	*handler.Base
+	service *service.Permission
+}
+
+func NewPermission(
+	svc *service.Permission,
+) *Permission {
+	return &Permission{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// GetPolicies 域里的策略
+func (a *Permission) GetPolicies(c *handler.Ctx) {
+	dom, ok := a.bindDomain(c)
+	if !ok {
+		return
+	}
+	policies, err := a.service.Policies(dom, operatorID(c))
+	if err != nil {
+		c.Response400("查询策略失败", err)
+		return
+	}
+	c.Response200(policies)
+}
+
+// PostPolicy 添加策略
+func (a *Permission) PostPolicy(c *handler.Ctx) {
+	policy, ok := a.bindPolicy(c)
+	if !ok {
+		return
+	}
+	err := a.service.AddPolicy(policy, operatorID(c))
+	if err != nil {
+		c.Response400("添加策略失败", err)
+		return
+	}
+	c.Response200(policy)
+}
+
+// DelPolicy 删除策略
+func (a *Permission) DelPolicy(c *handler.Ctx) {
+	policy, ok := a.bindPolicy(c)
+	if !ok {
+		return
+	}
+	err := a.service.RemovePolicy(policy, operatorID(c))
+	if err != nil {
+		c.Response400("删除策略失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// GetGroupings 域里的角色继承
+func (a *Permission) GetGroupings(c *handler.Ctx) {
+	dom, ok := a.bindDomain(c)
+	if !ok {
+		return
+	}
+	groupings, err := a.service.Groupings(dom, operatorID(c))
+	if err != nil {
+		c.Response400("查询角色失败", err)
+		return
+	}
+	c.Response200(groupings)
+}
+
+// PostGrouping 添加角色继承 (账号分配角色/角色继承角色)
+func (a *Permission) PostGrouping(c *handler.Ctx) {
+	grouping, ok := a.bindGrouping(c)
+	if !ok {
+		return
+	}
+	err := a.service.AddGrouping(grouping, operatorID(c))
+	if err != nil {
+		c.Response400("添加角色失败", err)
+		return
+	}
+	c.Response200(grouping)
+}
+
+// DelGrouping 删除角色继承
+func (a *Permission) DelGrouping(c *handler.Ctx) {
+	grouping, ok := a.bindGrouping(c)
+	if !ok {
+		return
+	}
+	err := a.service.RemoveGrouping(grouping, operatorID(c))
+	if err != nil {
+		c.Response400("删除角色失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// GetRoles 账号在域里的所有角色 (含继承的)
+func (a *Permission) GetRoles(c *handler.Ctx) {
+	dom, ok1 := a.bindDomain(c)
+	if !ok1 {
+		return
+	}
+	accountID, ok2 := paramID(c, "accountId")
+	if !ok2 {
+		return
+	}
+	roles, err := a.service.Roles(dom, accountID, operatorID(c))
+	if err != nil {
+		c.Response400("查询角色失败", err)
+		return
+	}
+	c.Response200(roles)
+}
+
+// GetResources 所有路由资源 (配置策略用)
+func (a *Permission) GetResources(c *handler.Ctx) {
+	c.Response200(perm.Resources())
+}
+
+func (a *Permission) bindDomain(c *handler.Ctx) (string, bool) {
+	ownKind, e1 := strconv.ParseInt(c.RequestParam("ownKind", ""), 10, 16)
+	ownID, e2 := strconv.ParseUint(c.RequestParam("ownId", ""), 10, 64)
+	if (e1 != nil) || (e2 != nil) || (ownKind <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return "", false
+	}
+	return perm.Domain(int(ownKind), ownID), true
+}
+
+func (a *Permission) bindPolicy(c *handler.Ctx) (*perm.Policy, bool) {
+	dom, ok := a.bindDomain(c)
+	if !ok {
+		return nil, false
+	}
+	bind := &struct {
+		Sub string `json:"sub" form:"sub" binding:"required"`
+		Obj string `json:"obj" form:"obj" binding:"required"`
+		Act string `json:"act" form:"act" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return nil, false
+	}
+	return perm.NewPolicyInDomain(bind.Sub, dom, bind.Obj, bind.Act), true
+}
+
+func (a *Permission) bindGrouping(c *handler.Ctx) (*perm.Grouping, bool) {
+	dom, ok := a.bindDomain(c)
+	if !ok {
+		return nil, false
+	}
+	bind := &struct {
+		Sub  string `json:"sub" form:"sub" binding:"required"`
+		Role string `json:"role" form:"role" binding:"required"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return nil, false
+	}
+	return perm.NewGrouping(bind.Sub, bind.Role, dom), true
+}
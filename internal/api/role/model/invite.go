@@ -0,0 +1,58 @@
+package model
+
+import (
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/pkg/model"
+)
+
+type (
+	// Invite 组织邀请 (邮箱/手机，token只在创建时返回一次，库里存摘要)
+	Invite struct {
+		*model.Base
+		OrgID     uint64             `json:"orgId" gorm:"index"`   // 组织
+		Kind      authModel.AuthKind `json:"kind"`                 // 邀请方式 (邮箱/手机)
+		Target    string             `json:"target" gorm:"index"`  // 邀请对象 (和IAuth.GetTarget一致，邮箱小写)
+		Role      MemberRole         `json:"role"`                 // 加入后的角色
+		Digest    string             `json:"-" gorm:"uniqueIndex"` // token摘要
+		InviterID uint64             `json:"inviterId"`            // 邀请人
+		ExpireAt  int64              `json:"expireAt"`             // 过期时间ms
+		AcceptAt  *int64             `json:"acceptAt"`             // 接受时间ms
+		AccountID *uint64            `json:"accountId"`            // 接受的账号
+	}
+)
+
+const (
+	InviteStatusRevoked  model.Status = -1 // 撤销
+	InviteStatusPending  model.Status = 0  // 待接受
+	InviteStatusAccepted model.Status = 1  // 已接受
+)
+
+func NewInviteEmpty() *Invite {
+	return &Invite{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewInvite(orgID uint64, kind authModel.AuthKind, target string, role MemberRole, inviterID uint64, expireAt int64) *Invite {
+	return &Invite{
+		Base:      model.NewBaseEmpty(),
+		OrgID:     orgID,
+		Kind:      kind,
+		Target:    target,
+		Role:      role,
+		InviterID: inviterID,
+		ExpireAt:  expireAt,
+	}
+}
+
+// IsPending 是否待接受 (没过期)
+func (i *Invite) IsPending(now int64) bool {
+	return (i.Status == InviteStatusPending) && (now < i.ExpireAt)
+}
+
+// Accept 接受邀请
+func (i *Invite) Accept(accountID uint64, now int64) {
+	i.Status = InviteStatusAccepted
+	i.AccountID = &accountID
+	i.AcceptAt = &now
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+)
+
+type (
+	// Member 组织成员 (账号+组织内角色)
+	Member struct {
+		*model.Base
+		OrgID     uint64     `json:"orgId" gorm:"uniqueIndex:idx_member_org_account"`     // 组织
+		AccountID uint64     `json:"accountId" gorm:"uniqueIndex:idx_member_org_account"` // 账号
+		Role      MemberRole `json:"role"`                                                // 组织内角色
+		InviterID *uint64    `json:"inviterId"`                                           // 邀请人 (注册加入的没有)
+	}
+
+	// MemberRole 组织内角色 (数值越大权限越高)
+	MemberRole int8
+)
+
+const (
+	MemberRoleMember MemberRole = 1 // 成员
+	MemberRoleAdmin  MemberRole = 2 // 管理员 (管理成员/邀请)
+	MemberRoleOwner  MemberRole = 3 // 所有者 (唯一，只能转让)
+)
+
+func NewMemberEmpty() *Member {
+	return &Member{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewMember(orgID uint64, accountID uint64, role MemberRole, inviterID *uint64) *Member {
+	return &Member{
+		Base:      model.NewBaseEmpty(),
+		OrgID:     orgID,
+		AccountID: accountID,
+		Role:      role,
+		InviterID: inviterID,
+	}
+}
+
+// IsValid 是否合法的角色 (不含所有者，所有者只能转让)
+func (r MemberRole) IsValid() bool {
+	return (r == MemberRoleMember) || (r == MemberRoleAdmin)
+}
+
+// CanManage 能否管理成员/邀请
+func (m *Member) CanManage() bool {
+	return m.Role >= MemberRoleAdmin
+}
+
+// IsOwner 是否所有者
+func (m *Member) IsOwner() bool {
+	return m.Role == MemberRoleOwner
+}
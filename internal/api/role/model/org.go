@@ -0,0 +1,159 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/valid"
+	"reflect"
+	"unicode/utf8"
+)
+
+type (
+	// Organization 组织 (OwnKindOrg的拥有者，账号注册到组织下即是成员)
+	Organization struct {
+		*model.Base
+		ParentID *uint64   `json:"parentId" gorm:"index"`                              // 上级组织
+		OwnerID  uint64    `json:"ownerId" gorm:"index"`                               // 所有者账号 (转让后变更)
+		Name     string    `json:"name" validate:"required,format-name"`               // 名称
+		Display  *string   `json:"display" validate:"format-display"`                  // 显示名称
+		Kind     OrgKind   `json:"kind" validate:"range-kind"`                         // 类型
+		Become   OrgBecome `json:"become" validate:"range-become"`                     // 加入方式
+		Tags     []string  `json:"tags" gorm:"serializer:json" validate:"format-tags"` // 标签
+	}
+
+	// OrgKind 组织类型
+	OrgKind int8
+
+	// OrgBecome 组织加入方式
+	OrgBecome int8
+)
+
+const (
+	OrgKindCompany   OrgKind = 0 // 公司
+	OrgKindTeam      OrgKind = 1 // 团队
+	OrgKindSchool    OrgKind = 2 // 学校
+	OrgKindCommunity OrgKind = 3 // 社区
+
+	OrgBecomeInvite OrgBecome = 0 // 仅邀请 (注册组织账号要有待接受的邀请)
+	OrgBecomeOpen   OrgBecome = 1 // 开放 (注册组织账号即成为成员)
+)
+
+const (
+	OrgStatusDeleted model.Status = -1 // 删除 (不能获取到)
+	OrgStatusInit    model.Status = 0  // 初始
+	OrgStatusActive  model.Status = 1  // 正常
+)
+
+const (
+	orgNameMaxLen    = 50 // 名称最长
+	orgDisplayMaxLen = 50 // 显示名称最长
+	orgTagsMax       = 10 // 标签最多
+	orgTagMaxLen     = 20 // 标签最长
+)
+
+func NewOrganizationEmpty() *Organization {
+	return &Organization{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func (o *Organization) Wash() *Organization {
+	o.Base = o.Base.Wash(OrgStatusInit)
+	o.OwnerID = 0
+	return o
+}
+
+func (o *Organization) ValidFieldRules() valid.FieldValidRules {
+	return valid.FieldValidRules{
+		valid.SceneAll: valid.FieldValidRule{
+			// 名称
+			"format-name": func(value reflect.Value, param string) bool {
+				val := value.Interface().(string)
+				length := utf8.RuneCountInString(val)
+				return (length > 0) && (length <= orgNameMaxLen)
+			},
+			// 显示名称
+			"format-display": func(value reflect.Value, param string) bool {
+				val := value.Interface().(*string)
+				if val == nil {
+					return true
+				}
+				return utf8.RuneCountInString(*val) <= orgDisplayMaxLen
+			},
+			// 类型
+			"range-kind": func(value reflect.Value, param string) bool {
+				val := value.Interface().(OrgKind)
+				switch val {
+				case OrgKindCompany,
+					OrgKindTeam,
+					OrgKindSchool,
+					OrgKindCommunity:
+					return true
+				default:
+					return false
+				}
+			},
+			// 加入方式
+			"range-become": func(value reflect.Value, param string) bool {
+				val := value.Interface().(OrgBecome)
+				switch val {
+				case OrgBecomeInvite,
+					OrgBecomeOpen:
+					return true
+				default:
+					return false
+				}
+			},
+			// 标签
+			"format-tags": func(value reflect.Value, param string) bool {
+				val := value.Interface().([]string)
+				if len(val) > orgTagsMax {
+					return false
+				}
+				for _, tag := range val {
+					length := utf8.RuneCountInString(tag)
+					if (length <= 0) || (length > orgTagMaxLen) {
+						return false
+					}
+				}
+				return true
+			},
+		},
+	}
+}
+
+func (o *Organization) ValidLocalizeRules() valid.LocalizeValidRules {
+	return valid.LocalizeValidRules{
+		valid.SceneAll: valid.LocalizeValidRule{
+			Rule1: map[valid.Tag]map[valid.FieldName]valid.LocalizeValidRuleParam{
+				valid.TagRequired: {
+					"Name": {"format_org_name_err", false, nil},
+				},
+			}, Rule2: map[valid.Tag]valid.LocalizeValidRuleParam{
+				"format-name":    {"format_org_name_err", false, nil},
+				"format-display": {"format_org_display_err", false, nil},
+				"range-kind":     {"format_org_kind_err", false, nil},
+				"range-become":   {"format_org_become_err", false, nil},
+				"format-tags":    {"format_org_tags_err", false, nil},
+			},
+		},
+	}
+}
+
+// IsDeleted 是否删除
+func (o *Organization) IsDeleted() bool {
+	return o.Status <= OrgStatusDeleted
+}
+
+// IsOpen 是否开放加入
+func (o *Organization) IsOpen() bool {
+	return o.Become == OrgBecomeOpen
+}
+
+// SetInfo 修改资料 (不含所有者/上级)
+func (o *Organization) SetInfo(param *Organization) {
+	o.Name = param.Name
+	o.Display = param.Display
+	o.Kind = param.Kind
+	o.Become = param.Become
+	o.Tags = param.Tags
+}
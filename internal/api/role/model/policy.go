@@ -0,0 +1,69 @@
+package model
+
+const (
+	PolicyTypeP = "p" // 策略
+	PolicyTypeG = "g" // 角色继承
+
+	PolicyFieldMax = 6 // 字段最多
+)
+
+type (
+	// PolicyRule casbin策略规则 (一行一条，p: sub,dom,obj,act g: sub,role,dom)
+	PolicyRule struct {
+		ID    uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+		PType string `json:"ptype" gorm:"size:16;uniqueIndex:idx_policy_rule"`
+		V0    string `json:"v0" gorm:"size:128;uniqueIndex:idx_policy_rule"`
+		V1    string `json:"v1" gorm:"size:128;uniqueIndex:idx_policy_rule"`
+		V2    string `json:"v2" gorm:"size:256;uniqueIndex:idx_policy_rule"`
+		V3    string `json:"v3" gorm:"size:128;uniqueIndex:idx_policy_rule"`
+		V4    string `json:"v4" gorm:"size:128;uniqueIndex:idx_policy_rule"`
+		V5    string `json:"v5" gorm:"size:128;uniqueIndex:idx_policy_rule"`
+	}
+)
+
+func NewPolicyRuleEmpty() *PolicyRule {
+	return &PolicyRule{}
+}
+
+// NewPolicyRule 从casbin规则构建 (超出的字段丢弃)
+func NewPolicyRule(ptype string, rule []string) *PolicyRule {
+	bean := &PolicyRule{PType: ptype}
+	fields := bean.fields()
+	for i, value := range rule {
+		if i >= PolicyFieldMax {
+			break
+		}
+		*fields[i] = value
+	}
+	return bean
+}
+
+// Line casbin规则 (ptype开头，去掉末尾空字段)
+func (r *PolicyRule) Line() []string {
+	line := []string{r.PType}
+	fields := r.fields()
+	last := -1
+	for i, field := range fields {
+		if *field != "" {
+			last = i
+		}
+	}
+	for i := 0; i <= last; i++ {
+		line = append(line, *fields[i])
+	}
+	return line
+}
+
+// Values 所有字段 (空字段也在，查询删除用)
+func (r *PolicyRule) Values() []string {
+	fields := r.fields()
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = *field
+	}
+	return values
+}
+
+func (r *PolicyRule) fields() []*string {
+	return []*string{&r.V0, &r.V1, &r.V2, &r.V3, &r.V4, &r.V5}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Invite 组织邀请仓储
+	Invite struct {
+		*storage.Base
+	}
+)
+
+func NewInvite() *Invite {
+	return &Invite{
+		Base: storage.NewBase(nil),
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Invite) WithContext(ctx context.Context) *Invite {
+	return &Invite{
+		Base: sto.Base.WithContext(ctx),
+	}
+}
+
+func (sto *Invite) Insert(bean *model.Invite) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleInvite)).Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加邀请", log.FUint64("orgId", bean.OrgID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *Invite) Update(bean *model.Invite) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleInvite)).Save(bean)
+	if result.Error != nil {
+		log.Error("DB_修改邀请", log.FUint64("id", bean.ID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// RevokeByOrg 撤销组织所有待接受的邀请
+func (sto *Invite) RevokeByOrg(orgID uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleInvite)).
+		Where("org_id = ? AND status = ?", orgID, model.InviteStatusPending).
+		Update("status", model.InviteStatusRevoked)
+	if result.Error != nil {
+		log.Error("DB_撤销组织邀请", log.FUint64("orgId", orgID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// SelectByID 查询邀请，没有返回nil
+func (sto *Invite) SelectByID(id uint64) (*model.Invite, *errs.CodeErrs) {
+	return sto.selectOne(sto.Psql().Where("id = ?", id))
+}
+
+// SelectByDigest 根据token摘要查询，没有返回nil
+func (sto *Invite) SelectByDigest(digest string) (*model.Invite, *errs.CodeErrs) {
+	return sto.selectOne(sto.Psql().Where("digest = ?", digest))
+}
+
+// SelectPendingByTarget 查询对象在组织里待接受的邀请 (最新的)，没有返回nil
+func (sto *Invite) SelectPendingByTarget(orgID uint64, kind authModel.AuthKind, target string, now int64) (*model.Invite, *errs.CodeErrs) {
+	return sto.selectOne(sto.Psql().
+		Where("org_id = ? AND kind = ? AND target = ?", orgID, kind, target).
+		Where("status = ? AND expire_at > ?", model.InviteStatusPending, now).
+		Order("id DESC"))
+}
+
+// SelectsPendingByOrg 查询组织待接受的邀请
+func (sto *Invite) SelectsPendingByOrg(orgID uint64, now int64) ([]*model.Invite, *errs.CodeErrs) {
+	var beans []*model.Invite
+	result := sto.Psql().Table(string(storage.TableRoleInvite)).
+		Where("org_id = ? AND status = ? AND expire_at > ?", orgID, model.InviteStatusPending, now).
+		Order("id DESC").
+		Find(&beans)
+	if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
+}
+
+func (sto *Invite) selectOne(query *gorm.DB) (*model.Invite, *errs.CodeErrs) {
+	bean := model.NewInviteEmpty()
+	result := query.Table(string(storage.TableRoleInvite)).First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Member 组织成员仓储
+	Member struct {
+		*storage.Base
+	}
+)
+
+func NewMember() *Member {
+	return &Member{
+		Base: storage.NewBase(nil),
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Member) WithContext(ctx context.Context) *Member {
+	return &Member{
+		Base: sto.Base.WithContext(ctx),
+	}
+}
+
+// Insert 添加成员，已经是成员返回false (唯一索引冲突不报错)
+func (sto *Member) Insert(bean *model.Member) (bool, *errs.CodeErrs) {
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加成员", log.FUint64("orgId", bean.OrgID), log.FUint64("accountId", bean.AccountID), log.FError(result.Error))
+		return false, storage.TranslateErr(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (sto *Member) Update(bean *model.Member) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleMember)).Save(bean)
+	if result.Error != nil {
+		log.Error("DB_修改成员", log.FUint64("id", bean.ID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *Member) Delete(orgID uint64, accountID uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Where("org_id = ? AND account_id = ?", orgID, accountID).
+		Delete(model.NewMemberEmpty())
+	if result.Error != nil {
+		log.Error("DB_删除成员", log.FUint64("orgId", orgID), log.FUint64("accountId", accountID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// DeleteByOrg 删除组织的所有成员
+func (sto *Member) DeleteByOrg(orgID uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Where("org_id = ?", orgID).
+		Delete(model.NewMemberEmpty())
+	if result.Error != nil {
+		log.Error("DB_删除组织成员", log.FUint64("orgId", orgID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// Select 查询成员，没有返回nil
+func (sto *Member) Select(orgID uint64, accountID uint64) (*model.Member, *errs.CodeErrs) {
+	bean := model.NewMemberEmpty()
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Where("org_id = ? AND account_id = ?", orgID, accountID).
+		First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// SelectsByOrg 查询组织的成员 (角色从高到低)
+func (sto *Member) SelectsByOrg(orgID uint64) ([]*model.Member, *errs.CodeErrs) {
+	var beans []*model.Member
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Where("org_id = ?", orgID).
+		Order("role DESC, id").
+		Find(&beans)
+	if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
+}
+
+// SelectsByAccount 查询账号加入的组织
+func (sto *Member) SelectsByAccount(accountID uint64) ([]*model.Member, *errs.CodeErrs) {
+	var beans []*model.Member
+	result := sto.Psql().Table(string(storage.TableRoleMember)).
+		Where("account_id = ?", accountID).
+		Order("id").
+		Find(&beans)
+	if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return beans, nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+)
+
+type (
+	// Organization 组织仓储
+	Organization struct {
+		*storage.Repo[model.Organization]
+	}
+)
+
+func NewOrganization() *Organization {
+	return &Organization{
+		Repo: storage.NewRepo(storage.TableRoleOrg, "组织", model.NewOrganizationEmpty, nil).Versioned(),
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (sto *Organization) WithContext(ctx context.Context) *Organization {
+	return &Organization{
+		Repo: sto.Repo.WithContext(ctx),
+	}
+}
+
+// Delete 软删除 (DeleteAt+状态)
+func (sto *Organization) Delete(id uint64, deleteBy uint64) *errs.CodeErrs {
+	return sto.DeleteWith(id, int64(deleteBy), map[string]any{
+		"status": model.OrgStatusDeleted,
+	})
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	casbinModel "github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/log"
+	"strconv"
+)
+
+type (
+	// Policy casbin策略仓储 (实现persist.BatchAdapter，enforcer自动保存)
+	Policy struct {
+		*storage.Base
+	}
+)
+
+var _ persist.BatchAdapter = (*Policy)(nil)
+
+func NewPolicy() *Policy {
+	return &Policy{
+		Base: storage.NewBase(nil),
+	}
+}
+
+// LoadPolicy 加载所有策略
+func (sto *Policy) LoadPolicy(m casbinModel.Model) error {
+	var beans []*model.PolicyRule
+	result := sto.Psql().Table(string(storage.TableRolePolicy)).
+		Order("id").
+		Find(&beans)
+	if result.Error != nil {
+		log.Error("DB_加载策略", log.FError(result.Error))
+		return result.Error
+	}
+	for _, bean := range beans {
+		if err := persist.LoadPolicyArray(bean.Line(), m); err != nil {
+			log.Warn("DB_加载策略_跳过", log.FUint64("id", bean.ID), log.FError(err))
+		}
+	}
+	return nil
+}
+
+// SavePolicy 全量覆盖策略
+func (sto *Policy) SavePolicy(m casbinModel.Model) error {
+	var beans []*model.PolicyRule
+	for _, sec := range []string{model.PolicyTypeP, model.PolicyTypeG} {
+		for ptype, ast := range m[sec] {
+			for _, rule := range ast.Policy {
+				beans = append(beans, model.NewPolicyRule(ptype, rule))
+			}
+		}
+	}
+	err := sto.Psql().Transaction(func(tx *gorm.DB) error {
+		if e := tx.Table(string(storage.TableRolePolicy)).
+			Where("1 = 1").
+			Delete(model.NewPolicyRuleEmpty()).Error; e != nil {
+			return e
+		}
+		if len(beans) <= 0 {
+			return nil
+		}
+		return tx.Table(string(storage.TableRolePolicy)).Create(beans).Error
+	})
+	if err != nil {
+		log.Error("DB_保存策略", log.FInt("count", len(beans)), log.FError(err))
+	}
+	return err
+}
+
+// AddPolicy 添加策略 (已有的不报错)
+func (sto *Policy) AddPolicy(sec string, ptype string, rule []string) error {
+	return sto.AddPolicies(sec, ptype, [][]string{rule})
+}
+
+// AddPolicies 批量添加策略 (已有的不报错)
+func (sto *Policy) AddPolicies(sec string, ptype string, rules [][]string) error {
+	if len(rules) <= 0 {
+		return nil
+	}
+	beans := make([]*model.PolicyRule, 0, len(rules))
+	for _, rule := range rules {
+		beans = append(beans, model.NewPolicyRule(ptype, rule))
+	}
+	result := sto.Psql().Table(string(storage.TableRolePolicy)).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(beans)
+	if result.Error != nil {
+		log.Error("DB_添加策略", log.FString("ptype", ptype), log.FInt("count", len(beans)), log.FError(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+// RemovePolicy 删除策略
+func (sto *Policy) RemovePolicy(sec string, ptype string, rule []string) error {
+	return sto.RemovePolicies(sec, ptype, [][]string{rule})
+}
+
+// RemovePolicies 批量删除策略
+func (sto *Policy) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	err := sto.Psql().Transaction(func(tx *gorm.DB) error {
+		for _, rule := range rules {
+			bean := model.NewPolicyRule(ptype, rule)
+			query := tx.Table(string(storage.TableRolePolicy)).Where("p_type = ?", ptype)
+			for i, value := range bean.Values() {
+				query = query.Where(policyColumn(i)+" = ?", value)
+			}
+			if e := query.Delete(model.NewPolicyRuleEmpty()).Error; e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("DB_删除策略", log.FString("ptype", ptype), log.FInt("count", len(rules)), log.FError(err))
+	}
+	return err
+}
+
+// RemoveFilteredPolicy 按字段删除策略 (从fieldIndex开始匹配，空值不限制)
+func (sto *Policy) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	query := sto.Psql().Table(string(storage.TableRolePolicy)).Where("p_type = ?", ptype)
+	for i, value := range fieldValues {
+		if (value == "") || (fieldIndex+i >= model.PolicyFieldMax) {
+			continue
+		}
+		query = query.Where(policyColumn(fieldIndex+i)+" = ?", value)
+	}
+	result := query.Delete(model.NewPolicyRuleEmpty())
+	if result.Error != nil {
+		log.Error("DB_删除策略", log.FString("ptype", ptype), log.FInt("fieldIndex", fieldIndex), log.FError(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+func policyColumn(index int) string {
+	return "v" + strconv.Itoa(index)
+}
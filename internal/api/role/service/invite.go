@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	authModel "katydid-mp-user/internal/api/auth/model"
+	authStorage "katydid-mp-user/internal/api/auth/repo/storage"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/valid"
+	"strings"
+	"time"
+)
+
+const (
+	inviteExpiresDef = 7 * 24 * time.Hour // 默认邀请有效期
+	inviteTokenLen   = 32                 // token字节数
+)
+
+type (
+	// Invite 组织邀请服务 (邮箱/手机，token有效期内接受，接受的账号要绑定了被邀请的邮箱/手机)
+	Invite struct {
+		*service.Base
+
+		dbs        *storage.Invite
+		dbsAccount *authStorage.Account
+
+		member  *Member
+		expires time.Duration // 有效期
+
+		OnSend func(invite *model.Invite, token string) // 发送邀请 (邮件/短信，链接里带token)
+	}
+)
+
+func NewInvite(db *storage.Invite, dbAccount *authStorage.Account, member *Member, expires time.Duration) *Invite {
+	if expires <= 0 {
+		expires = inviteExpiresDef
+	}
+	return &Invite{
+		Base:       service.NewBase(nil),
+		dbs:        db,
+		dbsAccount: dbAccount.System(), // 接受邀请的账号 (自己)
+		member:     member,
+		expires:    expires,
+	}
+}
+
+// Create 创建邀请 (管理员，角色不能高于自己)，返回token (只有这一次)
+func (svc *Invite) Create(orgID uint64, operatorID uint64, kind authModel.AuthKind, target string, role model.MemberRole) (*model.Invite, string, *errs.CodeErrs) {
+	if !role.IsValid() {
+		return nil, "", errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	_, operator, err := svc.member.Require(orgID, operatorID, model.MemberRoleAdmin)
+	if err != nil {
+		return nil, "", err
+	} else if operator.Role < role {
+		return nil, "", errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	target, ok := inviteTarget(kind, target)
+	if !ok {
+		return nil, "", errs.Match2("邀请的邮箱/手机号格式不正确")
+	}
+
+	token, digest, e := inviteToken()
+	if e != nil {
+		return nil, "", errs.Match(e).Real()
+	}
+	expireAt := time.Now().Add(svc.expires).UnixMilli()
+	entity := model.NewInvite(orgID, kind, target, role, operatorID, expireAt)
+	entity.Digest = digest
+	err = svc.dbs.Insert(entity)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Info("■ ■ Invite ■ ■ 创建邀请",
+		log.FUint64("orgId", orgID), log.FUint64("inviteId", entity.ID), log.FUint64("operatorId", operatorID))
+	if svc.OnSend != nil {
+		svc.OnSend(entity, token)
+	}
+	return entity, token, nil
+}
+
+// List 组织待接受的邀请 (管理员)
+func (svc *Invite) List(orgID uint64, operatorID uint64) ([]*model.Invite, *errs.CodeErrs) {
+	_, _, err := svc.member.Require(orgID, operatorID, model.MemberRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return svc.dbs.SelectsPendingByOrg(orgID, time.Now().UnixMilli())
+}
+
+// Revoke 撤销邀请 (管理员)
+func (svc *Invite) Revoke(orgID uint64, operatorID uint64, inviteID uint64) *errs.CodeErrs {
+	_, _, err := svc.member.Require(orgID, operatorID, model.MemberRoleAdmin)
+	if err != nil {
+		return err
+	}
+	exist, err := svc.dbs.SelectByID(inviteID)
+	if err != nil {
+		return err
+	} else if (exist == nil) || (exist.OrgID != orgID) || !exist.IsPending(time.Now().UnixMilli()) {
+		return errs.Match2(msg.ErrIdOrgInviteInvalid)
+	}
+	exist.Status = model.InviteStatusRevoked
+	return svc.dbs.Update(exist)
+}
+
+// Accept 用token接受邀请 (账号要绑定了被邀请的邮箱/手机)
+func (svc *Invite) Accept(token string, accountID uint64) (*model.Member, *errs.CodeErrs) {
+	if accountID <= 0 {
+		return nil, errs.Match2("no_login")
+	}
+	exist, err := svc.dbs.SelectByDigest(inviteDigest(token))
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || !exist.IsPending(time.Now().UnixMilli()) {
+		return nil, errs.Match2(msg.ErrIdOrgInviteInvalid)
+	}
+	account, err := svc.dbsAccount.SelectByID(accountID)
+	if err != nil {
+		return nil, err
+	} else if (account == nil) || !account.CanAccess() {
+		return nil, errs.Match2("账号不存在")
+	}
+	matched := false
+	for _, iAuth := range account.Auths {
+		if (iAuth != nil) && inviteMatch(exist, iAuth) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, errs.Match2(msg.ErrIdOrgInviteTarget)
+	}
+	return svc.accept(context.Background(), exist, account.ID)
+}
+
+// pending 认证对应的待接受邀请 (注册组织账号时用)，没有返回nil
+func (svc *Invite) pending(orgID uint64, iAuth authModel.IAuth) (*model.Invite, *errs.CodeErrs) {
+	target, ok := inviteTarget(iAuth.GetKind(), iAuth.GetTarget())
+	if !ok {
+		return nil, nil
+	}
+	return svc.dbs.SelectPendingByTarget(orgID, iAuth.GetKind(), target, time.Now().UnixMilli())
+}
+
+// accept 加入组织并标记邀请已接受 (一个事务，ctx里已经有事务的嵌套进去，例如注册时)
+func (svc *Invite) accept(ctx context.Context, invite *model.Invite, accountID uint64) (*model.Member, *errs.CodeErrs) {
+	var member *model.Member
+	err := service.Transaction(ctx, func(ctx context.Context) *errs.CodeErrs {
+		inviterID := invite.InviterID
+		joined, e := svc.member.withContext(ctx).join(invite.OrgID, accountID, invite.Role, &inviterID)
+		if e != nil {
+			return e
+		}
+		invite.Accept(accountID, time.Now().UnixMilli())
+		if e = svc.dbs.WithContext(ctx).Update(invite); e != nil {
+			return e
+		}
+		member = joined
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("■ ■ Invite ■ ■ 接受邀请",
+		log.FUint64("orgId", invite.OrgID), log.FUint64("inviteId", invite.ID), log.FUint64("accountId", accountID))
+	return member, nil
+}
+
+// withContext 绑定事务上下文的副本
+func (svc *Invite) withContext(ctx context.Context) *Invite {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	tx.dbsAccount = svc.dbsAccount.WithContext(ctx)
+	tx.member = svc.member.withContext(ctx)
+	return &tx
+}
+
+// inviteTarget 规范邀请对象 (和IAuth.GetTarget一致: 邮箱小写，手机 "+code number")
+func inviteTarget(kind authModel.AuthKind, target string) (string, bool) {
+	switch kind {
+	case authModel.AuthKindEmail:
+		email, ok := valid.IsEmail(target)
+		if !ok {
+			return "", false
+		}
+		return strings.ToLower(email.Username + "@" + email.Domain), true
+	case authModel.AuthKindCellphone:
+		code, number, ok := valid.IsPhone(target)
+		if !ok || (code == nil) {
+			return "", false
+		}
+		return "+" + code.Code + " " + number, true
+	}
+	return "", false
+}
+
+// inviteMatch 认证是不是被邀请的对象
+func inviteMatch(invite *model.Invite, iAuth authModel.IAuth) bool {
+	if iAuth.GetKind() != invite.Kind {
+		return false
+	}
+	target, ok := inviteTarget(iAuth.GetKind(), iAuth.GetTarget())
+	return ok && (target == invite.Target)
+}
+
+// inviteToken 随机token (url安全) 和摘要
+func inviteToken() (string, string, error) {
+	buf := make([]byte, inviteTokenLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, inviteDigest(token), nil
+}
+
+func inviteDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Member 组织成员服务 (操作人角色要高于被操作人)
+	Member struct {
+		*service.Base
+
+		dbs    *storage.Member
+		dbsOrg *storage.Organization
+
+		OnRemoved func(orgID uint64, accountID uint64) // 移除后 (清理账号在组织域的权限策略)
+	}
+)
+
+func NewMember(db *storage.Member, dbOrg *storage.Organization) *Member {
+	return &Member{
+		Base:   service.NewBase(nil),
+		dbs:    db,
+		dbsOrg: dbOrg,
+	}
+}
+
+// Require 操作人在组织里的角色至少是role，返回组织和操作人
+func (svc *Member) Require(orgID uint64, operatorID uint64, role model.MemberRole) (*model.Organization, *model.Member, *errs.CodeErrs) {
+	if operatorID <= 0 {
+		return nil, nil, errs.Match2("no_login")
+	}
+	org, err := svc.dbsOrg.SelectByID(orgID)
+	if err != nil {
+		return nil, nil, err
+	} else if (org == nil) || org.IsDeleted() {
+		return nil, nil, errs.Match2("组织不存在")
+	}
+	operator, err := svc.dbs.Select(orgID, operatorID)
+	if err != nil {
+		return nil, nil, err
+	} else if operator == nil {
+		return nil, nil, errs.Match2(msg.ErrIdOrgMemberNone)
+	} else if operator.Role < role {
+		return nil, nil, errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	return org, operator, nil
+}
+
+// List 组织的成员 (成员才能看)
+func (svc *Member) List(orgID uint64, operatorID uint64) ([]*model.Member, *errs.CodeErrs) {
+	_, _, err := svc.Require(orgID, operatorID, model.MemberRoleMember)
+	if err != nil {
+		return nil, err
+	}
+	return svc.dbs.SelectsByOrg(orgID)
+}
+
+// Orgs 账号加入的组织
+func (svc *Member) Orgs(accountID uint64) ([]*model.Member, *errs.CodeErrs) {
+	return svc.dbs.SelectsByAccount(accountID)
+}
+
+// ChangeRole 修改成员角色 (所有者只能转让，操作人要高于成员当前角色，且不低于新角色)
+func (svc *Member) ChangeRole(orgID uint64, operatorID uint64, accountID uint64, role model.MemberRole) (*model.Member, *errs.CodeErrs) {
+	if !role.IsValid() {
+		return nil, errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	_, operator, err := svc.Require(orgID, operatorID, model.MemberRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	exist, err := svc.selectMember(orgID, accountID)
+	if err != nil {
+		return nil, err
+	} else if exist.IsOwner() {
+		return nil, errs.Match2(msg.ErrIdOrgOwnerFixed)
+	} else if (operator.Role <= exist.Role) || (operator.Role < role) {
+		return nil, errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	if exist.Role == role {
+		return exist, nil
+	}
+	exist.Role = role
+	err = svc.dbs.Update(exist)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("■ ■ Member ■ ■ 修改角色",
+		log.FUint64("orgId", orgID), log.FUint64("accountId", accountID), log.FInt8("role", int8(role)))
+	return exist, nil
+}
+
+// Remove 移除成员 (自己是退出，所有者不能退出/被移除)
+func (svc *Member) Remove(orgID uint64, operatorID uint64, accountID uint64) *errs.CodeErrs {
+	minRole := model.MemberRoleAdmin
+	if operatorID == accountID {
+		minRole = model.MemberRoleMember
+	}
+	_, operator, err := svc.Require(orgID, operatorID, minRole)
+	if err != nil {
+		return err
+	}
+	exist, err := svc.selectMember(orgID, accountID)
+	if err != nil {
+		return err
+	} else if exist.IsOwner() {
+		return errs.Match2(msg.ErrIdOrgOwnerFixed)
+	} else if (operatorID != accountID) && (operator.Role <= exist.Role) {
+		return errs.Match2(msg.ErrIdOrgPermissionDenied)
+	}
+	err = svc.dbs.Delete(orgID, accountID)
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ Member ■ ■ 移除成员",
+		log.FUint64("orgId", orgID), log.FUint64("accountId", accountID), log.FUint64("operatorId", operatorID))
+	if svc.OnRemoved != nil {
+		svc.OnRemoved(orgID, accountID)
+	}
+	return nil
+}
+
+// join 加入组织 (邀请/注册)，已经是成员返回错误
+func (svc *Member) join(orgID uint64, accountID uint64, role model.MemberRole, inviterID *uint64) (*model.Member, *errs.CodeErrs) {
+	entity := model.NewMember(orgID, accountID, role, inviterID)
+	ok, err := svc.dbs.Insert(entity)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errs.Match2(msg.ErrIdOrgMemberExists)
+	}
+	return entity, nil
+}
+
+// withContext 绑定事务上下文的副本
+func (svc *Member) withContext(ctx context.Context) *Member {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	tx.dbsOrg = svc.dbsOrg.WithContext(ctx)
+	return &tx
+}
+
+func (svc *Member) selectMember(orgID uint64, accountID uint64) (*model.Member, *errs.CodeErrs) {
+	exist, err := svc.dbs.Select(orgID, accountID)
+	if err != nil {
+		return nil, err
+	} else if exist == nil {
+		return nil, errs.Match2(msg.ErrIdOrgMemberNone)
+	}
+	return exist, nil
+}
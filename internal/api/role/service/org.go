@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/api/role/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Organization 组织服务 (创建者即所有者，所有者唯一只能转让)
+	Organization struct {
+		*service.Base
+
+		dbs       *storage.Organization
+		dbsMember *storage.Member
+		dbsInvite *storage.Invite
+
+		member *Member
+		invite *Invite
+
+		OnDeleted func(orgID uint64) // 删除后 (清理组织域的权限策略)
+	}
+)
+
+func NewOrganization(
+	db *storage.Organization, dbMember *storage.Member, dbInvite *storage.Invite,
+	member *Member, invite *Invite,
+) *Organization {
+	return &Organization{
+		Base:      service.NewBase(nil),
+		dbs:       db,
+		dbsMember: dbMember,
+		dbsInvite: dbInvite,
+		member:    member,
+		invite:    invite,
+	}
+}
+
+// Add 创建组织，操作人成为所有者 (有上级的要是上级的管理员)
+func (svc *Organization) Add(param *model.Organization, operatorID uint64) *errs.CodeErrs {
+	if operatorID <= 0 {
+		return errs.Match2("no_login")
+	}
+	if param.ParentID != nil {
+		_, _, err := svc.member.Require(*param.ParentID, operatorID, model.MemberRoleAdmin)
+		if err != nil {
+			return err
+		}
+	}
+	entity := param.Wash()
+	entity.Status = model.OrgStatusActive
+	entity.OwnerID = operatorID
+	err := service.Transaction(context.Background(), func(ctx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(ctx)
+		if e := tx.dbs.Insert(entity); e != nil {
+			return e
+		}
+		_, e := tx.member.join(entity.ID, operatorID, model.MemberRoleOwner, nil)
+		return e
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ Organization ■ ■ 创建组织", log.FUint64("orgId", entity.ID), log.FUint64("ownerId", operatorID))
+	return nil
+}
+
+// Get 查询组织
+func (svc *Organization) Get(id uint64) (*model.Organization, *errs.CodeErrs) {
+	exist, err := svc.dbs.SelectByID(id)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || exist.IsDeleted() {
+		return nil, errs.Match2("组织不存在")
+	}
+	return exist, nil
+}
+
+// Update 修改资料 (管理员)，version是客户端看到的版本 (If-Match)，不对返回版本冲突
+func (svc *Organization) Update(id uint64, operatorID uint64, param *model.Organization, version *int64) (*model.Organization, *errs.CodeErrs) {
+	exist, _, err := svc.member.Require(id, operatorID, model.MemberRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil {
+		exist.SetVersion(*version)
+	}
+	exist.SetInfo(param)
+	err = svc.dbs.Update(exist)
+	if err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
+
+// Delete 删除组织 (所有者)，成员全部移除，待接受的邀请撤销
+func (svc *Organization) Delete(id uint64, operatorID uint64) *errs.CodeErrs {
+	var exist *model.Organization
+	err := service.Transaction(context.Background(), func(ctx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(ctx)
+		org, _, e := tx.member.Require(id, operatorID, model.MemberRoleOwner)
+		if e != nil {
+			return e
+		} else if e = tx.dbsInvite.RevokeByOrg(org.ID); e != nil {
+			return e
+		} else if e = tx.dbsMember.DeleteByOrg(org.ID); e != nil {
+			return e
+		} else if e = tx.dbs.Delete(org.ID, operatorID); e != nil {
+			return e
+		}
+		exist = org
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ Organization ■ ■ 删除组织", log.FUint64("orgId", exist.ID), log.FUint64("operatorId", operatorID))
+	if svc.OnDeleted != nil {
+		svc.OnDeleted(exist.ID)
+	}
+	return nil
+}
+
+// Transfer 转让所有者 (新所有者要是成员，原所有者降为管理员)
+func (svc *Organization) Transfer(id uint64, operatorID uint64, toAccountID uint64) (*model.Organization, *errs.CodeErrs) {
+	var exist *model.Organization
+	err := service.Transaction(context.Background(), func(ctx context.Context) *errs.CodeErrs {
+		tx := svc.withContext(ctx)
+		org, operator, e := tx.member.Require(id, operatorID, model.MemberRoleOwner)
+		if e != nil {
+			return e
+		} else if toAccountID == operatorID {
+			exist = org
+			return nil
+		}
+		to, e := tx.member.selectMember(id, toAccountID)
+		if e != nil {
+			return e
+		}
+		to.Role = model.MemberRoleOwner
+		if e = tx.dbsMember.Update(to); e != nil {
+			return e
+		}
+		operator.Role = model.MemberRoleAdmin
+		if e = tx.dbsMember.Update(operator); e != nil {
+			return e
+		}
+		org.OwnerID = toAccountID
+		if e = tx.dbs.Update(org); e != nil {
+			return e
+		}
+		exist = org
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if toAccountID == operatorID {
+		return exist, nil
+	}
+	log.Info("■ ■ Organization ■ ■ 转让组织",
+		log.FUint64("orgId", exist.ID), log.FUint64("from", operatorID), log.FUint64("to", toAccountID))
+	return exist, nil
+}
+
+// withContext 绑定事务上下文的副本 (组织/成员/邀请都走ctx里的事务)
+func (svc *Organization) withContext(ctx context.Context) *Organization {
+	tx := *svc
+	tx.dbs = svc.dbs.WithContext(ctx)
+	tx.dbsMember = svc.dbsMember.WithContext(ctx)
+	tx.dbsInvite = svc.dbsInvite.WithContext(ctx)
+	tx.member = svc.member.withContext(ctx)
+	tx.invite = svc.invite.withContext(ctx)
+	return &tx
+}
+
+// CheckRegister 注册组织账号前检查 (组织要存在，仅邀请的要有待接受的邀请)，给账号注册用
+func (svc *Organization) CheckRegister(account *authModel.Account, iAuth authModel.IAuth) *errs.CodeErrs {
+	if account.OwnKind != authModel.OwnKindOrg {
+		return nil
+	}
+	exist, err := svc.Get(account.OwnID)
+	if err != nil {
+		return err
+	} else if exist.IsOpen() {
+		return nil
+	}
+	invite, err := svc.invite.pending(exist.ID, iAuth)
+	if err != nil {
+		return err
+	} else if invite == nil {
+		return errs.Match2(msg.ErrIdOrgInviteOnly)
+	}
+	return nil
+}
+
+// OnRegistered 组织账号注册时成为成员 (有邀请的按邀请的角色)，给账号注册用，ctx是注册的事务
+func (svc *Organization) OnRegistered(ctx context.Context, account *authModel.Account, iAuth authModel.IAuth) *errs.CodeErrs {
+	if account.OwnKind != authModel.OwnKindOrg {
+		return nil
+	}
+	tx := svc.withContext(ctx)
+	invite, err := tx.invite.pending(account.OwnID, iAuth)
+	if err != nil {
+		return err
+	} else if invite != nil {
+		_, err = tx.invite.accept(ctx, invite, account.ID)
+		return err
+	}
+	_, err = tx.dbsMember.Insert(model.NewMember(account.OwnID, account.ID, model.MemberRoleMember, nil)) // 重新注册的已经是成员
+	return err
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"github.com/casbin/casbin/v2"
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/api/role/model"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/perm"
+	"slices"
+)
+
+const (
+	permissionObject = "auth/permission/*" // 管理策略的资源 (和路由推导的一致，非组织域要有这个权限)
+)
+
+type (
+	// Permission 权限策略服务 (域是OwnKind:OwnID，组织域由组织管理员管理，其他域要有策略管理权限)
+	Permission struct {
+		*service.Base
+
+		enforcer *casbin.SyncedEnforcer
+		member   *Member
+
+		OnRolesChanged func(accountIDs []uint64) // 账号角色变更 (更新角色版本，旧的token要刷新)
+	}
+)
+
+func NewPermission(enforcer *casbin.SyncedEnforcer, member *Member) *Permission {
+	return &Permission{
+		Base:     service.NewBase(nil),
+		enforcer: enforcer,
+		member:   member,
+	}
+}
+
+// Policies 域里的策略
+func (svc *Permission) Policies(dom string, operatorID uint64) ([]*perm.Policy, *errs.CodeErrs) {
+	err := svc.require(dom, operatorID, perm.PolicyActGet)
+	if err != nil {
+		return nil, err
+	}
+	rules, e := svc.enforcer.GetFilteredPolicy(1, dom)
+	if e != nil {
+		return nil, errs.Match(e).Real()
+	}
+	policies := make([]*perm.Policy, 0, len(rules))
+	for _, rule := range rules {
+		policies = append(policies, perm.NewPolicyInDomain(rule[0], rule[1], rule[2], rule[3]))
+	}
+	return policies, nil
+}
+
+// AddPolicy 添加策略 (主体是账号或角色)，数据范围all只能加在平台域
+func (svc *Permission) AddPolicy(policy *perm.Policy, operatorID uint64) *errs.CodeErrs {
+	if !policy.IsValid() || (policy.Dom == perm.DomainAll) {
+		return errs.Match2(msg.ErrIdPermPolicyInvalid)
+	} else if policy.IsDataAll() && !perm.IsPlatform(policy.Dom) {
+		return errs.Match2(msg.ErrIdPermPolicyInvalid)
+	}
+	err := svc.require(policy.Dom, operatorID, perm.PolicyActAdd)
+	if err != nil {
+		return err
+	}
+	if _, e := svc.enforcer.AddPolicy(policy.Rule()); e != nil {
+		return errs.Match(e).Real()
+	}
+	log.Info("■ ■ Permission ■ ■ 添加策略", log.FString("policy", policy.String()), log.FUint64("operatorId", operatorID))
+	return nil
+}
+
+// RemovePolicy 删除策略
+func (svc *Permission) RemovePolicy(policy *perm.Policy, operatorID uint64) *errs.CodeErrs {
+	if !policy.IsValid() {
+		return errs.Match2(msg.ErrIdPermPolicyInvalid)
+	}
+	err := svc.require(policy.Dom, operatorID, perm.PolicyActDel)
+	if err != nil {
+		return err
+	}
+	if _, e := svc.enforcer.RemovePolicy(policy.Rule()); e != nil {
+		return errs.Match(e).Real()
+	}
+	log.Info("■ ■ Permission ■ ■ 删除策略", log.FString("policy", policy.String()), log.FUint64("operatorId", operatorID))
+	return nil
+}
+
+// Groupings 域里的角色继承
+func (svc *Permission) Groupings(dom string, operatorID uint64) ([]*perm.Grouping, *errs.CodeErrs) {
+	err := svc.require(dom, operatorID, perm.PolicyActGet)
+	if err != nil {
+		return nil, err
+	}
+	rules, e := svc.enforcer.GetFilteredGroupingPolicy(2, dom)
+	if e != nil {
+		return nil, errs.Match(e).Real()
+	}
+	groupings := make([]*perm.Grouping, 0, len(rules))
+	for _, rule := range rules {
+		groupings = append(groupings, perm.NewGrouping(rule[0], rule[1], rule[2]))
+	}
+	return groupings, nil
+}
+
+// AddGrouping 添加角色继承 (账号分配角色/角色继承角色，不能循环继承)
+func (svc *Permission) AddGrouping(grouping *perm.Grouping, operatorID uint64) *errs.CodeErrs {
+	if !grouping.IsValid() || !perm.IsSubRole(grouping.Role) || (grouping.Dom == perm.DomainAll) {
+		return errs.Match2(msg.ErrIdPermPolicyInvalid)
+	}
+	err := svc.require(grouping.Dom, operatorID, perm.PolicyActAdd)
+	if err != nil {
+		return err
+	}
+	if perm.IsSubRole(grouping.Sub) {
+		inherits, e := svc.enforcer.GetImplicitRolesForUser(grouping.Role, grouping.Dom)
+		if e != nil {
+			return errs.Match(e).Real()
+		} else if slices.Contains(inherits, grouping.Sub) {
+			return errs.Match2(msg.ErrIdPermRoleCycle)
+		}
+	}
+	if _, e := svc.enforcer.AddGroupingPolicy(grouping.Rule()); e != nil {
+		return errs.Match(e).Real()
+	}
+	log.Info("■ ■ Permission ■ ■ 添加角色继承", log.FString("grouping", grouping.String()), log.FUint64("operatorId", operatorID))
+	svc.rolesChanged(grouping)
+	return nil
+}
+
+// RemoveGrouping 删除角色继承
+func (svc *Permission) RemoveGrouping(grouping *perm.Grouping, operatorID uint64) *errs.CodeErrs {
+	if !grouping.IsValid() {
+		return errs.Match2(msg.ErrIdPermPolicyInvalid)
+	}
+	err := svc.require(grouping.Dom, operatorID, perm.PolicyActDel)
+	if err != nil {
+		return err
+	}
+	accountIDs := svc.affectedAccounts(grouping) // 删了就查不到继承关系了
+	if _, e := svc.enforcer.RemoveGroupingPolicy(grouping.Rule()); e != nil {
+		return errs.Match(e).Real()
+	}
+	log.Info("■ ■ Permission ■ ■ 删除角色继承", log.FString("grouping", grouping.String()), log.FUint64("operatorId", operatorID))
+	if (svc.OnRolesChanged != nil) && (len(accountIDs) > 0) {
+		svc.OnRolesChanged(accountIDs)
+	}
+	return nil
+}
+
+// Roles 账号在域里的所有角色 (含继承的)，自己查自己不用权限
+func (svc *Permission) Roles(dom string, accountID uint64, operatorID uint64) ([]string, *errs.CodeErrs) {
+	if accountID != operatorID {
+		err := svc.require(dom, operatorID, perm.PolicyActGet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	roles, e := svc.enforcer.GetImplicitRolesForUser(perm.SubAccount(accountID), dom)
+	if e != nil {
+		return nil, errs.Match(e).Real()
+	}
+	return roles, nil
+}
+
+// OnOrgDeleted 组织删除后清理组织域的策略，给组织服务用
+func (svc *Permission) OnOrgDeleted(orgID uint64) {
+	dom := perm.Domain(int(authModel.OwnKindOrg), orgID)
+	if _, err := svc.enforcer.RemoveFilteredPolicy(1, dom); err != nil {
+		log.Error("■ ■ Permission ■ ■ 清理组织策略失败", log.FUint64("orgId", orgID), log.FError(err))
+	}
+	if _, err := svc.enforcer.RemoveFilteredGroupingPolicy(2, dom); err != nil {
+		log.Error("■ ■ Permission ■ ■ 清理组织角色继承失败", log.FUint64("orgId", orgID), log.FError(err))
+	}
+}
+
+// OnMemberRemoved 成员移除后清理账号在组织域的角色，给成员服务用
+func (svc *Permission) OnMemberRemoved(orgID uint64, accountID uint64) {
+	dom := perm.Domain(int(authModel.OwnKindOrg), orgID)
+	if _, err := svc.enforcer.RemoveFilteredGroupingPolicy(0, perm.SubAccount(accountID), "", dom); err != nil {
+		log.Error("■ ■ Permission ■ ■ 清理成员角色失败", log.FUint64("orgId", orgID), log.FUint64("accountId", accountID), log.FError(err))
+	}
+	if _, err := svc.enforcer.RemoveFilteredPolicy(0, perm.SubAccount(accountID), dom); err != nil {
+		log.Error("■ ■ Permission ■ ■ 清理成员策略失败", log.FUint64("orgId", orgID), log.FUint64("accountId", accountID), log.FError(err))
+	}
+	if svc.OnRolesChanged != nil {
+		svc.OnRolesChanged([]uint64{accountID})
+	}
+}
+
+// rolesChanged 角色继承变更后，通知受影响的账号
+func (svc *Permission) rolesChanged(grouping *perm.Grouping) {
+	if svc.OnRolesChanged == nil {
+		return
+	}
+	if accountIDs := svc.affectedAccounts(grouping); len(accountIDs) > 0 {
+		svc.OnRolesChanged(accountIDs)
+	}
+}
+
+// affectedAccounts 角色继承影响的账号 (主体是账号的就是它，是角色的是继承这个角色的所有账号)
+func (svc *Permission) affectedAccounts(grouping *perm.Grouping) []uint64 {
+	if accountID, ok := perm.ParseSubAccount(grouping.Sub); ok {
+		return []uint64{accountID}
+	}
+	svc.enforcer.GetLock().RLock() // SyncedEnforcer没有包这个方法
+	users, err := svc.enforcer.GetImplicitUsersForRole(grouping.Sub, grouping.Dom)
+	svc.enforcer.GetLock().RUnlock()
+	if err != nil {
+		log.Warn("■ ■ Permission ■ ■ 查询角色账号失败", log.FString("role", grouping.Sub), log.FError(err))
+		return nil
+	}
+	accountIDs := make([]uint64, 0, len(users))
+	for _, user := range users {
+		if accountID, ok := perm.ParseSubAccount(user); ok {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	return accountIDs
+}
+
+// require 操作人能不能管理域的策略 (组织域要组织管理员)
+func (svc *Permission) require(dom string, operatorID uint64, act string) *errs.CodeErrs {
+	if operatorID <= 0 {
+		return errs.Match2("no_login")
+	}
+	ownKind, ownID, ok := perm.ParseDomain(dom)
+	if !ok {
+		return errs.Match2(msg.ErrIdPermDomainInvalid)
+	}
+	if authModel.OwnKind(ownKind) == authModel.OwnKindOrg {
+		_, _, err := svc.member.Require(ownID, operatorID, model.MemberRoleAdmin)
+		return err
+	}
+	ok, e := svc.enforcer.Enforce(perm.SubAccount(operatorID), dom, permissionObject, act)
+	if e != nil {
+		return errs.Match(e).Real()
+	} else if !ok {
+		return errs.Match2(msg.ErrIdPermDenied)
+	}
+	return nil
+}
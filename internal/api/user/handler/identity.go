@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/api/user/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"strconv"
+)
+
+type Identity struct {
+	*handler.Base
+	service *service.Identity
+}
+
+func NewIdentity(
+	svc *service.Identity,
+) *Identity {
+	return &Identity{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Post 提交实名认证
+func (a *Identity) Post(c *handler.Ctx) {
+	id, ok := a.paramID(c)
+	if !ok {
+		return
+	}
+	bind := &struct {
+		model.IdentityInfo
+		Kind    model.IdentityKind `json:"kind" form:"kind" binding:"required"`
+		Country string             `json:"country" form:"country"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	entity, err := a.service.Submit(c.ServiceCtx(), id, bind.Kind, bind.Country, &bind.IdentityInfo)
+	if err != nil {
+		c.Response400("实名认证失败", err)
+		return
+	}
+	c.Response200(entity)
+}
+
+// Get 查询实名认证 (脱敏)
+func (a *Identity) Get(c *handler.Ctx) {
+	id, ok := a.paramID(c)
+	if !ok {
+		return
+	}
+	// 只能查自己的 (操作者账号关联的用户，或者有all数据范围)
+	entity, err := a.service.Get(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("查询实名认证失败", err)
+		return
+	}
+	c.Response200(entity)
+}
+
+// PutRetry 重新核验 (供应商之前异常)
+func (a *Identity) PutRetry(c *handler.Ctx) {
+	id, ok := a.paramID(c)
+	if !ok {
+		return
+	}
+	entity, err := a.service.Retry(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("实名认证失败", err)
+		return
+	}
+	c.Response200(entity)
+}
+
+// PutReview 人工审核 (平台管理员，服务里鉴权)
+func (a *Identity) PutReview(c *handler.Ctx) {
+	id, ok := a.paramID(c)
+	if !ok {
+		return
+	}
+	bind := &struct {
+		Passed bool   `json:"passed" form:"passed"`
+		Reason string `json:"reason" form:"reason"`
+	}{}
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	entity, err := a.service.Review(c.ServiceCtx(), id, bind.Passed, bind.Reason)
+	if err != nil {
+		c.Response400("审核实名认证失败", err)
+		return
+	}
+	c.Response200(entity)
+}
+
+func (a *Identity) paramID(c *handler.Ctx) (uint64, bool) {
+	id, e := strconv.ParseUint(c.RequestParam("id", ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return 0, false
+	}
+	return id, true
+}
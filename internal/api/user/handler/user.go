@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/api/user/service"
+	"katydid-mp-user/internal/pkg/handler"
+	"strconv"
+)
+
+type User struct {
+	*handler.Base
+	service *service.User
+}
+
+func NewUser(
+	svc *service.User,
+) *User {
+	return &User{
+		Base:    handler.NewBase(nil),
+		service: svc,
+	}
+}
+
+// Post 创建用户
+func (a *User) Post(c *handler.Ctx) {
+	bind := model.NewUserEmpty()
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	err = a.service.Add(bind)
+	if err != nil {
+		c.Response400("创建用户失败", err)
+		return
+	}
+	c.Response200(bind)
+}
+
+// Get 查询用户 (带上关联的账号)
+func (a *User) Get(c *handler.Ctx) {
+	id, ok := a.paramID(c, "id")
+	if !ok {
+		return
+	}
+	// 只能查自己的 (操作者账号关联的用户，或者有all数据范围)
+	exist, err := a.service.Get(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("查询用户失败", err)
+		return
+	}
+	c.Response200(exist)
+}
+
+// Put 修改资料
+func (a *User) Put(c *handler.Ctx) {
+	id, ok := a.paramID(c, "id")
+	if !ok {
+		return
+	}
+	bind := model.NewUserEmpty()
+	err := c.RequestBind(bind, true)
+	if err != nil {
+		c.Response400("", err)
+		return
+	}
+	// 只能改自己的
+	exist, err := a.service.Update(c.ServiceCtx(), id, bind)
+	if err != nil {
+		c.Response400("修改用户失败", err)
+		return
+	}
+	c.Response200(exist)
+}
+
+// Del 删除用户 (解除所有账号的关联)
+func (a *User) Del(c *handler.Ctx) {
+	id, ok := a.paramID(c, "id")
+	if !ok {
+		return
+	}
+	// 只能自己/管理员操作，deleteBy是操作者
+	err := a.service.Delete(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("删除用户失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// GetAccounts 查询关联的账号
+func (a *User) GetAccounts(c *handler.Ctx) {
+	id, ok := a.paramID(c, "id")
+	if !ok {
+		return
+	}
+	accounts, err := a.service.Accounts(c.ServiceCtx(), id)
+	if err != nil {
+		c.Response400("查询关联账号失败", err)
+		return
+	}
+	c.Response200(accounts)
+}
+
+// PostAccount 关联账号
+func (a *User) PostAccount(c *handler.Ctx) {
+	id, ok1 := a.paramID(c, "id")
+	if !ok1 {
+		return
+	}
+	accountID, ok2 := a.paramID(c, "accountId")
+	if !ok2 {
+		return
+	}
+	// 用户和账号都要是自己的 (账号在数据范围里，其他账号要先用那个账号登录)
+	err := a.service.LinkAccount(c.ServiceCtx(), id, accountID)
+	if err != nil {
+		c.Response400("关联账号失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// DelAccount 解除关联账号
+func (a *User) DelAccount(c *handler.Ctx) {
+	id, ok1 := a.paramID(c, "id")
+	if !ok1 {
+		return
+	}
+	accountID, ok2 := a.paramID(c, "accountId")
+	if !ok2 {
+		return
+	}
+	err := a.service.UnlinkAccount(c.ServiceCtx(), id, accountID)
+	if err != nil {
+		c.Response400("解除关联账号失败", err)
+		return
+	}
+	c.Response200(nil)
+}
+
+// paramID 路径里的ID，不合法直接返回400
+func (a *User) paramID(c *handler.Ctx, key string) (uint64, bool) {
+	id, e := strconv.ParseUint(c.RequestParam(key, ""), 10, 64)
+	if (e != nil) || (id <= 0) {
+		c.Response400("invalid_request_format", nil)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"katydid-mp-user/internal/pkg/model"
+)
+
+type (
+	// Identity 实名认证 (证件号/姓名加密存储，只留脱敏号码和盲索引)
+	Identity struct {
+		*model.Base
+		UserID  uint64       `json:"userId" gorm:"index"` // 用户
+		Kind    IdentityKind `json:"kind"`                // 证件类型
+		Country string       `json:"country"`             // 签发国家 (ISO 3166-1 alpha-2)
+		Masked  string       `json:"masked"`              // 脱敏号码 (展示用)
+
+		Cipher string `json:"-"`                                                                                // 加密的证件信息 (IdentityInfo)
+		Digest string `json:"-" gorm:"uniqueIndex:idx_identity_digest,where:status >= 0 AND delete_at IS NULL"` // 证件号盲索引 (查重，认证中/通过的同一证件只能有一条)
+		Vendor string `json:"vendor"`                                                                           // 认证的供应商
+		Trace  string `json:"trace,omitempty"`                                                                  // 供应商的流水号
+		Reason string `json:"reason,omitempty"`                                                                 // 拒绝原因
+		Tries  int    `json:"tries" gorm:"default:0"`                                                           // 调用供应商的次数
+		DoneAt *int64 `json:"doneAt"`                                                                           // 认证完成时间 (通过/拒绝)
+	}
+
+	// IdentityInfo 证件信息 (明文，只在加解密时出现)
+	IdentityInfo struct {
+		Name   string `json:"name" binding:"required"`   // 姓名
+		Number string `json:"number" binding:"required"` // 证件号
+	}
+
+	// IdentityKind 证件类型
+	IdentityKind int8
+)
+
+const (
+	IdentityKindIDCard   IdentityKind = 1 // 中国居民身份证
+	IdentityKindPassport IdentityKind = 2 // 护照
+)
+
+const (
+	IdentityStatusRejected model.Status = -1 // 拒绝 (可以重新提交)
+	IdentityStatusPending  model.Status = 0  // 认证中 (供应商失败/待人工审核)
+	IdentityStatusVerified model.Status = 1  // 通过
+)
+
+func NewIdentityEmpty() *Identity {
+	return &Identity{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func NewIdentity(userID uint64, kind IdentityKind, country string) *Identity {
+	return &Identity{
+		Base:    model.NewBaseEmpty(),
+		UserID:  userID,
+		Kind:    kind,
+		Country: country,
+	}
+}
+
+// IsPending 是否认证中
+func (i *Identity) IsPending() bool {
+	return i.Status == IdentityStatusPending
+}
+
+// IsVerified 是否通过
+func (i *Identity) IsVerified() bool {
+	return i.Status == IdentityStatusVerified
+}
+
+// Done 认证完成 (通过/拒绝)
+func (i *Identity) Done(status model.Status, reason string, now int64) {
+	i.Status = status
+	i.Reason = reason
+	i.DoneAt = &now
+}
+
+// MaskNumber 证件号脱敏 (保留前后各几位，中间换成*)
+func MaskNumber(number string) string {
+	runes := []rune(number)
+	keep := 2
+	if len(runes) >= 15 {
+		keep = 4 // 身份证保留前4后4
+	} else if len(runes) <= 4 {
+		keep = 1
+	}
+	if len(runes) <= keep*2 {
+		return number
+	}
+	for i := keep; i < len(runes)-keep; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
@@ -0,0 +1,185 @@
+package model
+
+import (
+	authModel "katydid-mp-user/internal/api/auth/model"
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/valid"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// User 用户 (自然人，可以关联不同own下的多个账号，Account.UserID指向这里)
+	User struct {
+		*model.Base
+		Gender   Gender               `json:"gender" validate:"range-gender"`      // 性别
+		Birthday *string              `json:"birthday" validate:"format-birthday"` // 生日 (2006-01-02)
+		Region   *string              `json:"region" validate:"format-region"`     // 地区 (ISO 3166-1 alpha-2，eg: CN)
+		Bio      *string              `json:"bio" validate:"format-bio"`           // 简介
+		Identity UserIdentity         `json:"identity" gorm:"default:0"`           // 实名状态 (Identity里是详情)
+		Accounts []*authModel.Account `json:"accounts,omitempty" gorm:"-"`         // 关联的账号 (多个own)
+	}
+
+	// Gender 性别
+	Gender int8
+
+	// UserIdentity 实名状态
+	UserIdentity int8
+)
+
+const (
+	GenderUnknown Gender = 0 // 未知 (未填)
+	GenderMale    Gender = 1 // 男
+	GenderFemale  Gender = 2 // 女
+	GenderOther   Gender = 3 // 其他
+)
+
+const (
+	UserIdentityNone     UserIdentity = 0 // 未认证
+	UserIdentityPending  UserIdentity = 1 // 认证中
+	UserIdentityVerified UserIdentity = 2 // 已认证
+	UserIdentityRejected UserIdentity = 3 // 认证失败
+)
+
+const (
+	UserStatusDeleted model.Status = -1 // 删除 (不能获取到)
+	UserStatusInit    model.Status = 0  // 初始
+	UserStatusActive  model.Status = 1  // 正常
+)
+
+const (
+	BirthdayLayout = "2006-01-02" // 生日格式
+
+	birthdayMaxYears = 150 // 生日最早150年前
+	bioMaxLen        = 200 // 简介最长
+	regionLen        = 2   // 地区码长度
+)
+
+func NewUserEmpty() *User {
+	return &User{
+		Base: model.NewBaseEmpty(),
+	}
+}
+
+func (u *User) Wash() *User {
+	u.Base = u.Base.Wash(UserStatusInit)
+	u.Identity = UserIdentityNone
+	u.Accounts = nil
+	return u
+}
+
+func (u *User) ValidFieldRules() valid.FieldValidRules {
+	return valid.FieldValidRules{
+		valid.SceneAll: valid.FieldValidRule{
+			// 性别
+			"range-gender": func(value reflect.Value, param string) bool {
+				val := value.Interface().(Gender)
+				switch val {
+				case GenderUnknown,
+					GenderMale,
+					GenderFemale,
+					GenderOther:
+					return true
+				default:
+					return false
+				}
+			},
+			// 生日
+			"format-birthday": func(value reflect.Value, param string) bool {
+				val := value.Interface().(*string)
+				if val == nil {
+					return true
+				}
+				birthday, err := time.Parse(BirthdayLayout, *val)
+				if err != nil {
+					return false
+				}
+				now := time.Now()
+				return !birthday.After(now) && birthday.After(now.AddDate(-birthdayMaxYears, 0, 0))
+			},
+			// 地区
+			"format-region": func(value reflect.Value, param string) bool {
+				val := value.Interface().(*string)
+				if val == nil {
+					return true
+				}
+				if len(*val) != regionLen {
+					return false
+				}
+				for _, c := range *val {
+					if (c < 'A') || (c > 'Z') {
+						return false
+					}
+				}
+				return true
+			},
+			// 简介
+			"format-bio": func(value reflect.Value, param string) bool {
+				val := value.Interface().(*string)
+				if val == nil {
+					return true
+				}
+				return utf8.RuneCountInString(*val) <= bioMaxLen
+			},
+		},
+	}
+}
+
+func (u *User) ValidLocalizeRules() valid.LocalizeValidRules {
+	return valid.LocalizeValidRules{
+		valid.SceneAll: valid.LocalizeValidRule{
+			Rule2: map[valid.Tag]valid.LocalizeValidRuleParam{
+				"range-gender":    {"range_user_gender_err", false, nil},
+				"format-birthday": {"format_user_birthday_err", false, nil},
+				"format-region":   {"format_user_region_err", false, nil},
+				"format-bio":      {"format_user_bio_err", false, nil},
+			},
+		},
+	}
+}
+
+// IsDeleted 是否删除
+func (u *User) IsDeleted() bool {
+	return u.Status <= UserStatusDeleted
+}
+
+// HasGender 是否填了性别
+func (u *User) HasGender() bool {
+	return u.Gender != GenderUnknown
+}
+
+// HasBirthday 是否填了生日
+func (u *User) HasBirthday() bool {
+	return (u.Birthday != nil) && (len(*u.Birthday) > 0)
+}
+
+// IsIdentityVerified 是否已实名
+func (u *User) IsIdentityVerified() bool {
+	return u.Identity == UserIdentityVerified
+}
+
+// Age 周岁 (没填生日/格式错误返回-1)
+func (u *User) Age(now time.Time) int {
+	if !u.HasBirthday() {
+		return -1
+	}
+	birthday, err := time.Parse(BirthdayLayout, *u.Birthday)
+	if err != nil {
+		return -1
+	}
+	age := now.Year() - birthday.Year()
+	if (now.Month() < birthday.Month()) ||
+		((now.Month() == birthday.Month()) && (now.Day() < birthday.Day())) {
+		age--
+	}
+	return age
+}
+
+// SetProfile 修改资料 (只改资料字段)
+func (u *User) SetProfile(param *User) {
+	u.Gender = param.Gender
+	u.Birthday = param.Birthday
+	u.Region = param.Region
+	u.Bio = param.Bio
+}
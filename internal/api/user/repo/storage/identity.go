@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Identity 实名认证仓储
+	Identity struct {
+		*storage.Base
+	}
+)
+
+func NewIdentity() *Identity {
+	return &Identity{
+		Base: storage.NewBase(nil),
+	}
+}
+
+func (sto *Identity) Insert(bean *model.Identity) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableUserIdentity)).Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加实名认证", log.FUint64("userId", bean.UserID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *Identity) Update(bean *model.Identity) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableUserIdentity)).Save(bean)
+	if result.Error != nil {
+		log.Error("DB_修改实名认证", log.FUint64("id", bean.ID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// SelectLastByUser 查询用户最近一次的实名认证，没有返回nil
+func (sto *Identity) SelectLastByUser(userID uint64) (*model.Identity, *errs.CodeErrs) {
+	bean := model.NewIdentityEmpty()
+	result := sto.Psql().Table(string(storage.TableUserIdentity)).
+		Where("user_id = ? AND delete_at IS NULL", userID).
+		Order("id DESC").
+		First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// SelectCountByDigest 查询证件被其他用户占用的数量 (认证中/通过的)
+func (sto *Identity) SelectCountByDigest(digest string, excludeUserID uint64) (int, *errs.CodeErrs) {
+	var count int64
+	result := sto.Psql().Table(string(storage.TableUserIdentity)).
+		Where("digest = ? AND user_id <> ?", digest, excludeUserID).
+		Where("status >= ?", model.IdentityStatusPending).
+		Where("delete_at IS NULL").
+		Count(&count)
+	if result.Error != nil {
+		return 0, storage.TranslateErr(result.Error)
+	}
+	return int(count), nil
+}
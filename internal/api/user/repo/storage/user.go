@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"time"
+)
+
+type (
+	// User 用户仓储
+	User struct {
+		*storage.Base
+	}
+)
+
+func NewUser() *User {
+	return &User{
+		Base: storage.NewBase(nil),
+	}
+}
+
+func (sto *User) Insert(bean *model.User) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableUser)).Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加用户", log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// Delete 软删除 (DeleteAt+状态)
+func (sto *User) Delete(id uint64, deleteBy uint64) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableUser)).
+		Where("id = ? AND delete_at IS NULL", id).
+		Updates(map[string]any{
+			"status":    model.UserStatusDeleted,
+			"delete_at": time.Now().UnixMilli(),
+			"delete_by": deleteBy,
+		})
+	if result.Error != nil {
+		log.Error("DB_删除用户", log.FUint64("id", id), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+func (sto *User) Update(bean *model.User) *errs.CodeErrs {
+	result := sto.Psql().Table(string(storage.TableUser)).Save(bean)
+	if result.Error != nil {
+		log.Error("DB_修改用户", log.FUint64("id", bean.ID), log.FError(result.Error))
+		return storage.TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// SelectByID 查询用户 (不含删除的)，没有返回nil
+func (sto *User) SelectByID(id uint64) (*model.User, *errs.CodeErrs) {
+	bean := model.NewUserEmpty()
+	result := sto.Psql().Table(string(storage.TableUser)).
+		Where("id = ? AND delete_at IS NULL", id).
+		First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, storage.TranslateErr(result.Error)
+	}
+	return bean, nil
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/api/user/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	pkgStorage "katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/crypto"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/perm"
+	"katydid-mp-user/pkg/valid"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	identityTimeoutDef = 10 * time.Second // 默认供应商超时
+	identityNameMaxLen = 50               // 姓名最长
+
+	AdminIdentity = "admin/user/identity" // 人工审核的管理资源 (平台域)
+)
+
+type (
+	// Identity 实名认证服务 (格式校验->查重->加密存储->供应商核验，供应商异常时保持认证中，可重试/人工审核)
+	Identity struct {
+		*service.Base
+
+		dbs     *storage.Identity
+		dbsUser *storage.User
+
+		user *User // 用户归属 (只能认证自己的用户)
+
+		provider  IIdentityProvider
+		aead      *crypto.AESGCM // 证件信息加密
+		digestKey []byte         // 盲索引key
+		timeout   time.Duration  // 供应商超时
+	}
+)
+
+func NewIdentity(
+	db *storage.Identity, dbUser *storage.User, user *User, provider IIdentityProvider,
+	secretKey string, timeout time.Duration,
+) (*Identity, error) {
+	// 密钥不能随机生成，否则重启后解不开
+	aead, err := crypto.NewAESGCMFromSecret(secretKey, "identity:cipher")
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = identityTimeoutDef
+	}
+	return &Identity{
+		Base:      service.NewBase(nil),
+		dbs:       db,
+		dbsUser:   dbUser,
+		user:      user,
+		provider:  provider,
+		aead:      aead,
+		digestKey: crypto.DeriveKey(secretKey, "identity:digest"),
+		timeout:   timeout,
+	}, nil
+}
+
+// Submit 提交实名认证 (已通过/认证中的不能再提交)，只能是操作者的用户
+func (svc *Identity) Submit(ctx *service.Ctx, userID uint64, kind model.IdentityKind, country string, info *model.IdentityInfo) (*model.Identity, *errs.CodeErrs) {
+	user, err := svc.user.selectOwned(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if user.IsIdentityVerified() {
+		return nil, errs.Match2(msg.ErrIdUserIdentityVerified)
+	}
+	last, err := svc.dbs.SelectLastByUser(user.ID)
+	if err != nil {
+		return nil, err
+	} else if (last != nil) && last.IsPending() {
+		return nil, errs.Match2(msg.ErrIdUserIdentityPending)
+	}
+
+	// 格式
+	country, card, err := svc.normalize(kind, country, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// 查重 (同一证件只能认证一个用户)
+	digest := crypto.BlindIndex(svc.digestKey, fmt.Sprintf("%d:%s:%s", kind, country, info.Number))
+	count, err := svc.dbs.SelectCountByDigest(digest, user.ID)
+	if err != nil {
+		return nil, err
+	} else if count > 0 {
+		return nil, errs.Match2(msg.ErrIdUserIdentityExists)
+	}
+
+	entity := model.NewIdentity(user.ID, kind, country)
+	entity.Status = model.IdentityStatusPending
+	entity.Masked = model.MaskNumber(info.Number)
+	entity.Digest = digest
+	entity.Vendor = svc.provider.Name()
+	entity.Cipher, err = svc.encrypt(entity, info)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.dbs.Insert(entity)
+	if pkgStorage.ErrKind(err) == pkgStorage.DBErrUnique {
+		return nil, errs.Match2(msg.ErrIdUserIdentityExists) // 并发提交同一证件，唯一索引兜底
+	} else if err != nil {
+		return nil, err
+	}
+	user.Identity = model.UserIdentityPending
+	err = svc.dbsUser.Update(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.verify(ctx.Context(), user, entity, info, card)
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Get 查询最近一次的实名认证 (脱敏)，没有返回nil
+func (svc *Identity) Get(ctx *service.Ctx, userID uint64) (*model.Identity, *errs.CodeErrs) {
+	user, err := svc.user.selectOwned(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.dbs.SelectLastByUser(user.ID)
+}
+
+// Retry 重新调用供应商 (认证中的，供应商之前异常)
+func (svc *Identity) Retry(ctx *service.Ctx, userID uint64) (*model.Identity, *errs.CodeErrs) {
+	if _, err := svc.user.selectOwned(ctx, userID); err != nil {
+		return nil, err
+	}
+	user, entity, err := svc.selectPending(userID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := svc.decrypt(entity)
+	if err != nil {
+		return nil, err
+	}
+	var card *valid.IDCardComponents
+	if entity.Kind == model.IdentityKindIDCard {
+		card, _ = valid.IsIDCard(info.Number)
+	}
+	entity.Vendor = svc.provider.Name()
+	err = svc.verify(ctx.Context(), user, entity, info, card)
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Review 人工审核 (认证中的)，只能是平台管理员 (admin/user/identity:mod)，不依赖鉴权中间件
+func (svc *Identity) Review(ctx *service.Ctx, userID uint64, passed bool, reason string) (*model.Identity, *errs.CodeErrs) {
+	if !ctx.IsAdmin(AdminIdentity, perm.PolicyActMod) {
+		log.Warn("■ ■ Identity ■ ■ 越权审核", log.FUint64("actorId", ctx.ActorId), log.FUint64("userId", userID))
+		return nil, errs.Match2(msg.ErrIdPermDenied)
+	}
+	user, entity, err := svc.selectPending(userID)
+	if err != nil {
+		return nil, err
+	}
+	var card *valid.IDCardComponents
+	if passed && (entity.Kind == model.IdentityKindIDCard) {
+		info, err := svc.decrypt(entity)
+		if err != nil {
+			return nil, err
+		}
+		card, _ = valid.IsIDCard(info.Number)
+	}
+	entity.Vendor = "review"
+	err = svc.finish(user, entity, &IdentityResult{Passed: passed, Reason: reason}, card)
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// verify 调用供应商核验，没有结果的保持认证中
+func (svc *Identity) verify(ctx context.Context, user *model.User, entity *model.Identity, info *model.IdentityInfo, card *valid.IDCardComponents) *errs.CodeErrs {
+	ctx, cancel := context.WithTimeout(ctx, svc.timeout)
+	defer cancel()
+	entity.Tries++
+	result, e := svc.provider.Verify(ctx, &IdentityRequest{
+		Kind: entity.Kind, Country: entity.Country, Name: info.Name, Number: info.Number,
+	})
+	if e != nil {
+		log.Warn("■ ■ Identity ■ ■ 供应商核验失败",
+			log.FString("vendor", entity.Vendor), log.FUint64("userId", user.ID), log.FError(e))
+		return svc.dbs.Update(entity)
+	}
+	return svc.finish(user, entity, result, card)
+}
+
+// finish 认证完成，同步用户的实名状态，身份证通过时补上没填的性别/生日
+func (svc *Identity) finish(user *model.User, entity *model.Identity, result *IdentityResult, card *valid.IDCardComponents) *errs.CodeErrs {
+	now := time.Now().UnixMilli()
+	entity.Trace = result.Trace
+	if result.Passed {
+		entity.Done(model.IdentityStatusVerified, "", now)
+		user.Identity = model.UserIdentityVerified
+		if card != nil {
+			if !user.HasGender() {
+				user.Gender = model.GenderFemale
+				if card.Male {
+					user.Gender = model.GenderMale
+				}
+			}
+			if !user.HasBirthday() {
+				birthday := card.Birthday.Format(model.BirthdayLayout)
+				user.Birthday = &birthday
+			}
+		}
+	} else {
+		entity.Done(model.IdentityStatusRejected, result.Reason, now)
+		user.Identity = model.UserIdentityRejected
+	}
+	err := svc.dbs.Update(entity)
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ Identity ■ ■ 实名认证完成",
+		log.FUint64("userId", user.ID), log.FString("vendor", entity.Vendor), log.FBool("passed", result.Passed))
+	return svc.dbsUser.Update(user)
+}
+
+// normalize 校验并规范证件信息 (去空白/大写，15位身份证升级成18位)
+func (svc *Identity) normalize(kind model.IdentityKind, country string, info *model.IdentityInfo) (string, *valid.IDCardComponents, *errs.CodeErrs) {
+	info.Name = strings.TrimSpace(info.Name)
+	nameLen := utf8.RuneCountInString(info.Name)
+	if (nameLen <= 0) || (nameLen > identityNameMaxLen) {
+		return "", nil, errs.Match2(msg.ErrIdUserIdentityFormat)
+	}
+	country = strings.ToUpper(strings.TrimSpace(country))
+	switch kind {
+	case model.IdentityKindIDCard:
+		card, ok := valid.IsIDCard(info.Number)
+		if !ok {
+			return "", nil, errs.Match2(msg.ErrIdUserIdentityFormat)
+		}
+		info.Number = card.Number
+		return "CN", card, nil
+	case model.IdentityKindPassport:
+		if len(country) != 2 {
+			return "", nil, errs.Match2(msg.ErrIdUserIdentityFormat)
+		}
+		number, ok := valid.IsPassport(info.Number, country)
+		if !ok {
+			return "", nil, errs.Match2(msg.ErrIdUserIdentityFormat)
+		}
+		info.Number = number
+		return country, nil, nil
+	}
+	return "", nil, errs.Match2(msg.ErrIdUserIdentityFormat)
+}
+
+// encrypt 加密证件信息 (aad绑定用户，密文挪到别的用户下解不开)
+func (svc *Identity) encrypt(entity *model.Identity, info *model.IdentityInfo) (string, *errs.CodeErrs) {
+	plain, e := json.Marshal(info)
+	if e != nil {
+		return "", errs.Match(e).Real()
+	}
+	text, e := svc.aead.EncryptString(string(plain), strconv.FormatUint(entity.UserID, 10))
+	if e != nil {
+		return "", errs.Match(e).Real()
+	}
+	return text, nil
+}
+
+func (svc *Identity) decrypt(entity *model.Identity) (*model.IdentityInfo, *errs.CodeErrs) {
+	plain, e := svc.aead.DecryptString(entity.Cipher, strconv.FormatUint(entity.UserID, 10))
+	if e != nil {
+		log.Error("■ ■ Identity ■ ■ 解密失败", log.FUint64("id", entity.ID), log.FError(e))
+		return nil, errs.Match(e).Real()
+	}
+	info := &model.IdentityInfo{}
+	if e = json.Unmarshal([]byte(plain), info); e != nil {
+		return nil, errs.Match(e).Real()
+	}
+	return info, nil
+}
+
+func (svc *Identity) selectUser(userID uint64) (*model.User, *errs.CodeErrs) {
+	user, err := svc.dbsUser.SelectByID(userID)
+	if err != nil {
+		return nil, err
+	} else if (user == nil) || user.IsDeleted() {
+		return nil, errs.Match2("用户不存在")
+	}
+	return user, nil
+}
+
+func (svc *Identity) selectPending(userID uint64) (*model.User, *model.Identity, *errs.CodeErrs) {
+	user, err := svc.selectUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entity, err := svc.dbs.SelectLastByUser(user.ID)
+	if err != nil {
+		return nil, nil, err
+	} else if (entity == nil) || !entity.IsPending() {
+		return nil, nil, errs.Match2("没有认证中的实名认证")
+	}
+	return user, entity, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"katydid-mp-user/internal/api/user/model"
+	"time"
+)
+
+type (
+	// IIdentityProvider 实名认证供应商 (公安/运营商/第三方核验接口)
+	IIdentityProvider interface {
+		Name() string
+		// Verify 核验证件，返回error表示没有结果 (网络/供应商异常，可以重试)
+		Verify(ctx context.Context, req *IdentityRequest) (*IdentityResult, error)
+	}
+
+	// IdentityRequest 核验请求
+	IdentityRequest struct {
+		Kind    model.IdentityKind
+		Country string
+		Name    string
+		Number  string
+	}
+
+	// IdentityResult 核验结果
+	IdentityResult struct {
+		Passed bool   // 是否通过
+		Reason string // 不通过的原因
+		Trace  string // 供应商的流水号
+	}
+
+	// MockIdentityProvider 模拟供应商 (开发/测试用)，Rejects里的证件号不通过，其他都通过
+	MockIdentityProvider struct {
+		Rejects map[string]string // [证件号]拒绝原因
+	}
+)
+
+func NewMockIdentityProvider(rejects map[string]string) *MockIdentityProvider {
+	if rejects == nil {
+		rejects = make(map[string]string)
+	}
+	return &MockIdentityProvider{Rejects: rejects}
+}
+
+func (p *MockIdentityProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockIdentityProvider) Verify(ctx context.Context, req *IdentityRequest) (*IdentityResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := &IdentityResult{Passed: true, Trace: fmt.Sprintf("mock-%d", time.Now().UnixNano())}
+	if reason, ok := p.Rejects[req.Number]; ok {
+		result.Passed = false
+		result.Reason = reason
+	}
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	authModel "katydid-mp-user/internal/api/auth/model"
+	authService "katydid-mp-user/internal/api/auth/service"
+	"katydid-mp-user/internal/api/user/model"
+	"katydid-mp-user/internal/api/user/repo/storage"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/internal/pkg/service"
+	pkgStorage "katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+const (
+	dataUser = "data/user/user" // 用户的数据范围资源 (用户不属于own，只有all/自己)
+)
+
+type (
+	// User 用户服务 (资料+关联账号)
+	User struct {
+		*service.Base
+
+		dbs *storage.User
+
+		account *authService.Account // 账号关联 (配额在账号服务里)
+	}
+)
+
+func NewUser(db *storage.User, account *authService.Account) *User {
+	return &User{
+		Base:    service.NewBase(nil),
+		dbs:     db,
+		account: account,
+	}
+}
+
+// Add 创建用户
+func (svc *User) Add(param *model.User) *errs.CodeErrs {
+	param.Wash()
+	param.Status = model.UserStatusActive
+	err := svc.dbs.Insert(param)
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ User ■ ■ 创建用户", log.FUint64("userId", param.ID))
+	return nil
+}
+
+// Get 查询用户 (带上关联的账号)
+func (svc *User) Get(ctx *service.Ctx, id uint64) (*model.User, *errs.CodeErrs) {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	exist.Accounts, err = svc.account.SelectsByUser(exist.ID)
+	if err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
+
+// Update 修改资料
+func (svc *User) Update(ctx *service.Ctx, id uint64, param *model.User) (*model.User, *errs.CodeErrs) {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	exist.SetProfile(param)
+	err = svc.dbs.Update(exist)
+	if err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
+
+// Delete 删除用户 (先解除所有账号的关联，其他own的账号也要解除)
+func (svc *User) Delete(ctx *service.Ctx, id uint64) *errs.CodeErrs {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	accounts, err := svc.account.SelectsByUser(exist.ID)
+	if err != nil {
+		return err
+	}
+	unlinkCtx := service.NewCtxSystem().WithReason("删除用户")
+	for _, account := range accounts {
+		err = svc.account.UnlinkUser(unlinkCtx, account.ID, exist.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = svc.dbs.Delete(exist.ID, ctx.ActorId)
+	if err != nil {
+		return err
+	}
+	log.Info("■ ■ User ■ ■ 删除用户", log.FUint64("userId", exist.ID), log.FInt("accounts", len(accounts)))
+	return nil
+}
+
+// Accounts 用户关联的账号 (所有own)
+func (svc *User) Accounts(ctx *service.Ctx, id uint64) ([]*authModel.Account, *errs.CodeErrs) {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return svc.account.SelectsByUser(exist.ID)
+}
+
+// LinkAccount 关联账号 (MaxPerUser配额)，用户和账号都要是操作者的
+func (svc *User) LinkAccount(ctx *service.Ctx, id uint64, accountID uint64) *errs.CodeErrs {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	return svc.account.LinkUser(ctx, accountID, exist.ID)
+}
+
+// UnlinkAccount 解除关联账号，用户和账号都要是操作者的
+func (svc *User) UnlinkAccount(ctx *service.Ctx, id uint64, accountID uint64) *errs.CodeErrs {
+	exist, err := svc.selectOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	return svc.account.UnlinkUser(ctx, accountID, exist.ID)
+}
+
+// CheckInfo 检查账号关联的用户是否满足登录要求 (按账号own的UserInfoRequire+LimitUserInfo/UserIDCardRequire，给登录用)
+func (svc *User) CheckInfo(account *authModel.Account) *errs.CodeErrs {
+	if account.UserID == nil {
+		return errs.Match2(msg.ErrIdUserInfoRequired)
+	}
+	exist, err := svc.dbs.SelectByID(*account.UserID)
+	if err != nil {
+		return err
+	} else if (exist == nil) || exist.IsDeleted() {
+		return errs.Match2(msg.ErrIdUserInfoRequired)
+	}
+	limitAccount := svc.GetLimitAccount(int16(account.OwnKind), account.OwnID)
+	if limitAccount.UserInfoRequire {
+		limit := svc.GetLimitUserInfo(int16(account.OwnKind), account.OwnID)
+		if limit.RequireSex && !exist.HasGender() {
+			return errs.Match2(msg.ErrIdUserGenderRequired)
+		} else if limit.RequireAge && !exist.HasBirthday() {
+			return errs.Match2(msg.ErrIdUserBirthdayRequired)
+		}
+	}
+	if limitAccount.UserIDCardRequire && !exist.IsIdentityVerified() {
+		return errs.Match2(msg.ErrIdUserIdentityRequired)
+	}
+	return nil
+}
+
+// selectOwned 查询操作者能管理的用户 (有all数据范围的，或者操作者账号关联的)，其他的当作不存在
+func (svc *User) selectOwned(ctx *service.Ctx, id uint64) (*model.User, *errs.CodeErrs) {
+	exist, err := svc.selectExist(id)
+	if err != nil {
+		return nil, err
+	} else if ctx.DataScope(dataUser).Kind == pkgStorage.DataScopeAll {
+		return exist, nil
+	}
+	ok, err := svc.account.IsActorUser(ctx, exist.ID)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		log.Warn("■ ■ User ■ ■ 越权访问用户", log.FUint64("actorId", ctx.ActorId), log.FUint64("userId", id))
+		return nil, errs.Match2("用户不存在")
+	}
+	return exist, nil
+}
+
+// selectExist 查询未删除的用户
+func (svc *User) selectExist(id uint64) (*model.User, *errs.CodeErrs) {
+	exist, err := svc.dbs.SelectByID(id)
+	if err != nil {
+		return nil, err
+	} else if (exist == nil) || exist.IsDeleted() {
+		return nil, errs.Match2("用户不存在")
+	}
+	return exist, nil
+}
@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"katydid-mp-user/internal/pkg/service"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/i18n"
+	"katydid-mp-user/pkg/middleware"
+	"katydid-mp-user/pkg/valid"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Ctx 请求上下文 (每个请求一个，处理器是共享的，请求相关的都放这里)
+	Ctx struct {
+		gCtx *gin.Context
+		Conf
+		Auth
+		App
+	}
+
+	// Conf 配置
+	Conf struct {
+		Lang string // 语言
+	}
+)
+
+// Auth 身份验证
+type Auth struct {
+	OrgID  *uint64 // 组织ID
+	RoleID *string // 用户角色
+	AccID  *uint64 // 账号ID
+	UserID *uint64 // 用户ID
+}
+
+// App 应用
+type App struct {
+	AppID *uint64 // 应用Id
+	CltID *uint64 // 客户端Id
+	VerID *uint64 // 版本Id
+}
+
+// NewCtx 创建请求上下文 (语言优先用语言中间件解析的，认证信息用认证中间件设置的)
+func NewCtx(gCtx *gin.Context) *Ctx {
+	c := &Ctx{
+		gCtx: gCtx,
+		Conf: Conf{
+			Lang: i18n.DefLang(),
+		},
+	}
+	if gCtx == nil {
+		return c
+	}
+	if lang := gCtx.GetString(middleware.LanguageKey); len(lang) > 0 {
+		c.Lang = lang
+	} else if lang = gCtx.GetHeader(middleware.LanguageKey); len(lang) > 0 {
+		c.Lang = lang
+	}
+	if accountID := gCtx.GetUint64(middleware.AuthKeyAccountID); accountID > 0 {
+		c.AccID = &accountID
+	}
+	c.UserID, _ = gCtx.Value(middleware.AuthKeyUserID).(*uint64)
+	return c
+}
+
+// GCtx 获取原始gin上下文
+func (c *Ctx) GCtx() *gin.Context {
+	return c.gCtx
+}
+
+// ServiceCtx 请求的服务上下文 (认证中间件设置的操作者/角色/权限)，没登录的没有数据权限
+func (c *Ctx) ServiceCtx() *service.Ctx {
+	accountID := c.gCtx.GetUint64(middleware.AuthKeyAccountID)
+	if accountID <= 0 {
+		return service.NewCtx(0, service.ActorTypeNone, nil)
+	}
+	ownKind, _ := c.gCtx.Value(middleware.AuthKeyOwnKind).(int16)
+	return service.NewCtx(accountID, service.ActorTypeAccount, nil).
+		WithOwn(ownKind, c.gCtx.GetUint64(middleware.AuthKeyOwnID)).
+		WithPermission(c.gCtx.GetStringSlice(middleware.AuthKeyRoles), c.gCtx.GetStringSlice(middleware.AuthKeyScopes))
+}
+
+/********************************************************************************
+ *********************************** Request ************************************
+ ********************************************************************************/
+
+// RequestBind 绑定并验证请求数据
+func (c *Ctx) RequestBind(obj any, must bool) *errs.CodeErrs {
+	// bind会自动推断type
+	if must {
+		if e := c.gCtx.Bind(obj); e != nil {
+			return errs.Match(e).WrapLocalize("invalid_request_format", nil, nil).Real()
+		}
+	} else {
+		if e := c.gCtx.ShouldBind(obj); e != nil {
+			return errs.Match(e).WrapLocalize("invalid_request_format", nil, nil).Real()
+		}
+	}
+	// 验证
+	msgErrs := valid.Check(obj, valid.SceneBind)
+	if msgErrs == nil || len(msgErrs) == 0 {
+		return nil
+	}
+	// 处理验证错误
+	codeErrs := errs.New()
+	for _, me := range msgErrs {
+		if me.Err != nil {
+			_ = codeErrs.WrapErrs(me.Err)
+		}
+		if len(me.Msg) > 0 {
+			_ = codeErrs.WrapLocalize(me.Msg, me.Params, nil)
+		}
+	}
+	return codeErrs.Real()
+}
+
+// RequestParam 获取路径参数
+func (c *Ctx) RequestParam(key string, defVal string) string {
+	value := c.gCtx.Param(key)
+	if value == "" {
+		return defVal
+	}
+	return value
+}
+
+// RequestQuery 获取查询参数
+func (c *Ctx) RequestQuery(key string, defVal string) (string, bool) {
+	value, exists := c.gCtx.GetQuery(key)
+	if !exists {
+		return defVal, exists
+	}
+	return value, exists
+}
+
+// RequestPagination 获取分页参数
+func (c *Ctx) RequestPagination() (page, size int) {
+	pageStr, _ := c.RequestQuery("page", "1")
+	sizeStr, _ := c.RequestQuery("pageSize", "20")
+	// TODO:GG 应该还有一个防止重复加载的
+
+	page, _ = strconv.Atoi(pageStr)
+	size, _ = strconv.Atoi(sizeStr)
+
+	if page < 1 {
+		page = 1
+	}
+	return page, requestPageSize(size)
+}
+
+// RequestCursor 获取游标分页参数 (cursor是上一页返回的，第一页不传)
+func (c *Ctx) RequestCursor() (cursor uint64, size int) {
+	cursorStr, _ := c.RequestQuery("cursor", "0")
+	sizeStr, _ := c.RequestQuery("pageSize", "20")
+
+	cursor, _ = strconv.ParseUint(cursorStr, 10, 64)
+	size, _ = strconv.Atoi(sizeStr)
+	return cursor, requestPageSize(size)
+}
+
+func requestPageSize(size int) int {
+	if size < 1 {
+		return 20
+	}
+	if size > 100 {
+		return 100
+	}
+	return size
+}
+
+// RequestIfMatch 获取If-Match里的版本号 ("3" 或 W/"3")，没有/不合法/* 返回false
+func (c *Ctx) RequestIfMatch() (int64, bool) {
+	value := strings.TrimSpace(c.gCtx.GetHeader("If-Match"))
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, e := strconv.ParseInt(value, 10, 64)
+	if (e != nil) || (version < 0) {
+		return 0, false
+	}
+	return version, true
+}
+
+// RequestSorting 获取排序参数
+func (c *Ctx) RequestSorting(defField, defOrder string, fieldRanges []string) (field, order string) {
+	field, _ = c.RequestQuery("sortBy", defField)
+	order, _ = c.RequestQuery("sortOrder", defOrder)
+
+	find := false
+	for _, v := range fieldRanges {
+		if field == v {
+			find = true
+			break
+		}
+	}
+	if !find {
+		field = defField
+	}
+
+	if order != "asc" && order != "desc" {
+		order = defOrder
+	}
+	return field, order
+}
+
+/********************************************************************************
+ *********************************** Response ***********************************
+ ********************************************************************************/
+
+// ResponseETag 设置ETag (版本号)，客户端修改时用If-Match带回来
+func (c *Ctx) ResponseETag(version int64) {
+	c.gCtx.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// Response200 成功响应
+func (c *Ctx) Response200(data any) {
+	c.Response(http.StatusOK, 0, "success", data)
+}
+
+// Response201 创建成功响应
+func (c *Ctx) Response201(data any) {
+	c.Response(http.StatusCreated, 0, "created_success", data)
+}
+
+// Response400 请求错误响应
+func (c *Ctx) Response400(msg string, data any) {
+	if msg == "" {
+		msg = "bad_request"
+	}
+	localizedMsg := i18n.LocalizeTry(c.Lang, msg, nil)
+	c.Response(http.StatusBadRequest, 0, localizedMsg, data)
+}
+
+// Response401 未授权响应
+func (c *Ctx) Response401(data any) {
+	msg := i18n.LocalizeTry(c.Lang, "unauthorized", nil)
+	c.Response(http.StatusUnauthorized, 0, msg, data)
+}
+
+// Response403 禁止访问响应
+func (c *Ctx) Response403(msg string) {
+	if msg == "" {
+		msg = "forbidden"
+	}
+	localizedMsg := i18n.LocalizeTry(c.Lang, msg, nil)
+	c.Response(http.StatusForbidden, 403, localizedMsg, nil)
+}
+
+// Response404 资源不存在响应
+func (c *Ctx) Response404(msg string) {
+	if msg == "" {
+		msg = "not_found"
+	}
+	localizedMsg := i18n.LocalizeTry(c.Lang, msg, nil)
+	c.Response(http.StatusNotFound, 404, localizedMsg, nil)
+}
+
+func (c *Ctx) Response(status, code int, msg string, data any) {
+	if e, ok := data.(error); ok {
+		c.responseErr(status, code, e)
+		return
+	}
+	c.responseData(status, code, msg, data)
+}
+
+func (c *Ctx) responseErr(status, code int, data error) {
+	// 数据库错误 (唯一冲突/外键/超时...) 用对应的状态码，带了If-Match的版本冲突是412
+	if status == http.StatusBadRequest {
+		if kind := storage.ErrKind(data); kind != storage.DBErrUnknown {
+			status = kind.Status()
+			if _, ok := c.RequestIfMatch(); ok && (kind == storage.DBErrVersion) {
+				status = http.StatusPreconditionFailed
+			}
+		}
+	}
+	var cErr *errs.CodeErrs
+	var v *errs.CodeErrs
+	if errors.As(data, &v) {
+		cErr = v
+	} else {
+		cErr = errs.Match(data).Real()
+	}
+	if code == 0 {
+		code = cErr.Code()
+	}
+
+	msg := cErr.ToLocales(func(localize string, template1s []any, template2s map[string]any) string {
+		var templates []any
+		for _, v := range template1s {
+			if _, ok := v.(string); !ok {
+				templates = append(templates, v)
+				continue
+			}
+			temp := i18n.LocalizeTry(c.Lang, v.(string), nil)
+			templates = append(templates, temp)
+		}
+		r1 := i18n.LocalizeTry(c.Lang, localize, template2s)
+		return fmt.Sprintf(r1, templates...)
+	})
+
+	if len(msg) == 0 {
+		msg = i18n.LocalizeTry(c.Lang, "unknown_err", nil)
+	}
+
+	c.responseData(status, code, msg, nil)
+}
+
+func (c *Ctx) responseData(status, code int, msg string, data any) {
+	// TODO:GG 有些字段，返回的时候是要忽略的(利用json:"-"来做吗?)
+	body := gin.H{"code": code, "msg": msg, "data": data}
+
+	accept := c.gCtx.GetHeader("Accept")
+	if accept == "" || strings.Contains(accept, "*/*") || strings.Contains(accept, "application/*") {
+		accept = binding.MIMEJSON
+	} else if strings.Contains(accept, "msg/*") {
+		accept = binding.MIMEXML
+	}
+
+	switch {
+	case strings.Contains(accept, binding.MIMEJSON):
+		c.gCtx.JSON(status, body)
+	case strings.Contains(accept, binding.MIMEPROTOBUF):
+		c.gCtx.ProtoBuf(status, body)
+	case strings.Contains(accept, binding.MIMEHTML):
+		c.gCtx.HTML(status, "", msg)
+	case strings.Contains(accept, binding.MIMEXML), strings.Contains(accept, binding.MIMEXML2):
+		c.gCtx.XML(status, body)
+	case strings.Contains(accept, binding.MIMETOML):
+		c.gCtx.TOML(status, body)
+	case strings.Contains(accept, binding.MIMEYAML), strings.Contains(accept, binding.MIMEYAML2):
+		c.gCtx.YAML(status, body)
+	default:
+		c.gCtx.String(status, msg)
+	}
+}
+
+// TODO:GG accept-encoding
+
+//// HasPermission 检查是否有指定权限
+//func (c *Ctx) HasPermission(perm string) bool {
+//	for _, p := range c.Perms {
+//		if p == perm || p == "*" {
+//			return true
+//		}
+//	}
+//	return false
+//}
+//
+//// RequirePermission 要求特定权限, 无权限时返回错误
+//func (c *Ctx) RequirePermission(perm string) bool {
+//	if !c.HasPermission(perm) {
+//		c.Response401("")
+//		return false
+//	}
+//	return true
+//}
+//
+//// RequireAuthenticated 要求已认证用户
+//func (c *Ctx) RequireAuthenticated() bool {
+//	if c.AccID == 0 {
+//		c.Response401("login_required")
+//		return false
+//	}
+//	return true
+//}
+//
+//// IsAdmin 判断是否是管理员
+//func (c *Ctx) IsAdmin() bool {
+//	return *c.Role == "admin" || c.HasPermission("admin")
+//}
+//
+//// RequireAdmin 要求管理员权限
+//func (c *Ctx) RequireAdmin() bool {
+//	if !c.IsAdmin() {
+//		c.Response403("admin_required")
+//		return false
+//	}
+//	return true
+//}
+//
+//// IsCurrentOrg 检查是否与指定组织匹配
+//func (c *Ctx) IsCurrentOrg(orgId uint64) bool {
+//	return *c.OrgId == orgId
+//}
+//
+//// RequireOrg 要求属于指定组织
+//func (c *Ctx) RequireOrg(orgId uint64) bool {
+//	if !c.IsCurrentOrg(orgId) && !c.IsAdmin() {
+//		c.Response403("org_access_denied")
+//		return false
+//	}
+//	return true
+//}
@@ -17,6 +17,7 @@ type (
 		UpdateAt int64  `json:"updateAt" gorm:"autoUpdateTime:milli"` // 更新时间
 		DeleteAt *int64 `json:"deleteAt"`                             // 删除时间 // TODO:GG 所有的查询都带上index `gorm:"index"`
 		DeleteBy int64  `json:"deleteBy"`                             // 删除人
+		Version  int64  `json:"version" gorm:"default:0"`             // 版本号 (乐观锁，仓储开启了才检查)
 
 		// id
 		// index
@@ -24,6 +25,8 @@ type (
 		// stats
 
 		Extra data.KSMap `json:"extra" gorm:"serializer:json"` // 额外信息 (!索引/!必需)
+
+		changes []*StatusChange // 还没落库的状态变更 (状态机转换时记录，仓储修改时写入历史)
 	}
 
 	// Status 状态 (组合体可自定义)
@@ -61,7 +64,9 @@ func (b *Base) Wash(status Status) *Base {
 	b.UpdateAt = 0
 	b.DeleteBy = 0
 	b.DeleteAt = nil
+	b.Version = 0
 	b.Extra = make(data.KSMap)
+	b.changes = nil
 	return b
 }
 
@@ -170,6 +175,21 @@ func (b *Base) IsDeleted() bool {
 	return b.DeleteAt != nil
 }
 
+// GetID 主键
+func (b *Base) GetID() uint64 {
+	return b.ID
+}
+
+// GetVersion 版本号 (乐观锁)
+func (b *Base) GetVersion() int64 {
+	return b.Version
+}
+
+// SetVersion 设置版本号 (带上客户端的版本号修改，If-Match)
+func (b *Base) SetVersion(version int64) {
+	b.Version = version
+}
+
 func (b *Base) IsDelByUser() bool {
 	return b.DeleteBy >= deleteByUserSelf
 }
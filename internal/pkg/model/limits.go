@@ -0,0 +1,39 @@
+package model
+
+import (
+	"katydid-mp-user/pkg/data"
+)
+
+type (
+	// Limits 拥有者的限制覆盖项 (只存和默认值不一样的)
+	Limits struct {
+		*Base
+		OwnKind int16      `json:"ownKind" gorm:"uniqueIndex:idx_limits_own"` // 拥有者类型
+		OwnID   uint64     `json:"ownId" gorm:"uniqueIndex:idx_limits_own"`   // 拥有者ID
+		Content data.KSMap `json:"content" gorm:"serializer:json"`            // {verify:{...},account:{...},...}
+
+		ParentKind int16  `json:"parentKind"` // 上级拥有者类型 (0没有，org->app->client)
+		ParentID   uint64 `json:"parentId"`   // 上级拥有者ID
+	}
+)
+
+func NewLimitsEmpty() *Limits {
+	return &Limits{
+		Base:    NewBaseEmpty(),
+		Content: make(data.KSMap),
+	}
+}
+
+// HasParent 是否有上级 (继承上级的限制)
+func (l *Limits) HasParent() bool {
+	return l.ParentKind > 0
+}
+
+func NewLimits(ownKind int16, ownID uint64, content data.KSMap) *Limits {
+	return &Limits{
+		Base:    NewBaseEmpty(),
+		OwnKind: ownKind,
+		OwnID:   ownID,
+		Content: content,
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+)
+
+type (
+	// StatusChange 一次状态转换 (还没落库)
+	StatusChange struct {
+		From  Status
+		To    Status
+		Event string
+		At    int64 // 转换时间ms
+	}
+
+	// StatusHistory 状态变更历史
+	StatusHistory struct {
+		ID uint64 `json:"id" gorm:"primarykey"` // 主键
+
+		Target   string `json:"target" gorm:"index:idx_status_history_target"`   // 实体所在的表
+		TargetID uint64 `json:"targetId" gorm:"index:idx_status_history_target"` // 实体ID
+		Event    string `json:"event"`                                           // 事件 (状态机规则)
+		From     Status `json:"from"`                                            // 原状态
+		To       Status `json:"to"`                                              // 新状态
+
+		ActorType uint8  `json:"actorType"` // 操作者类型 (service.ActorType)
+		ActorID   uint64 `json:"actorId"`   // 操作者ID
+		Reason    string `json:"reason"`    // 原因
+
+		CreateAt int64 `json:"createAt"` // 变更时间 (转换的时间，不是落库的时间)
+	}
+)
+
+func NewStatusHistory(
+	target string, targetID uint64, change *StatusChange,
+	actorType uint8, actorID uint64, reason string,
+) *StatusHistory {
+	return &StatusHistory{
+		Target:    target,
+		TargetID:  targetID,
+		Event:     change.Event,
+		From:      change.From,
+		To:        change.To,
+		ActorType: actorType,
+		ActorID:   actorID,
+		Reason:    reason,
+		CreateAt:  change.At,
+	}
+}
+
+// AddStatusChange 记录状态转换 (给状态机的OnChange用)
+func (b *Base) AddStatusChange(from, to Status, event string) {
+	b.changes = append(b.changes, &StatusChange{
+		From:  from,
+		To:    to,
+		Event: event,
+		At:    time.Now().UnixMilli(),
+	})
+}
+
+// StatusChanges 还没落库的状态转换 (不清空，落库成功后再ClearStatusChanges)
+func (b *Base) StatusChanges() []*StatusChange {
+	return b.changes
+}
+
+// ClearStatusChanges 清空状态转换 (已经落库了)
+func (b *Base) ClearStatusChanges() {
+	b.changes = nil
+}
@@ -3,6 +3,24 @@ package msg
 const (
 	ErrCodeUnknown = 0
 	ErrCodeDB      = 1000
+	ErrCodeAccount = 2000
+	ErrCodeAuth    = 3000
+	ErrCodeUser    = 4000
+	ErrCodeOrg     = 5000
+	ErrCodePerm    = 6000
+)
+
+// 数据库错误按类型分开的错误码 (翻译后的错误按错误码识别类型，例如重试/版本冲突)
+const (
+	ErrCodeDBUnique      = ErrCodeDB + 1 // 唯一约束冲突
+	ErrCodeDBForeignNone = ErrCodeDB + 2 // 外键指向的数据不存在
+	ErrCodeDBForeignUsed = ErrCodeDB + 3 // 还被外键引用
+	ErrCodeDBTooLong     = ErrCodeDB + 4 // 值太长
+	ErrCodeDBNotNull     = ErrCodeDB + 5 // 不能为空
+	ErrCodeDBDeadlock    = ErrCodeDB + 6 // 死锁/序列化失败
+	ErrCodeDBTimeout     = ErrCodeDB + 7 // 超时
+	ErrCodeDBVersion     = ErrCodeDB + 8 // 版本冲突 (乐观锁)
+	ErrCodeDBNone        = ErrCodeDB + 9 // 没有改到 (不存在/已删除/不在数据范围里)
 )
 
 const (
@@ -21,32 +39,151 @@ const (
 	ErrIdDBQueParams      = "err_db_que_params"
 	ErrIdDBQueNone        = "err_db_que_none"
 	ErrIdDBQueForeignNone = "err_db_que_foreign_none"
+	ErrIdDBForeignUsed    = "err_db_foreign_used"
+	ErrIdDBDeadlock       = "err_db_deadlock"
+	ErrIdDBTimeout        = "err_db_timeout"
+	ErrIdDBField          = "err_db_field"
+
+	ErrIdDBVersionConflict = "err_db_version_conflict"
+)
+
+const (
+	ErrIdAccountQuotaClosed    = "err_account_quota_closed"
+	ErrIdAccountQuotaCellphone = "err_account_quota_cellphone"
+	ErrIdAccountQuotaEmail     = "err_account_quota_email"
+	ErrIdAccountQuotaBio       = "err_account_quota_bio"
+	ErrIdAccountQuotaThird     = "err_account_quota_third"
+	ErrIdAccountQuotaUser      = "err_account_quota_user"
+
+	ErrIdAccountNicknameRequired  = "err_account_nickname_required"
+	ErrIdAccountNicknameLength    = "err_account_nickname_length"
+	ErrIdAccountNicknameSensitive = "err_account_nickname_sensitive"
+	ErrIdAccountNicknameExists    = "err_account_nickname_exists"
+	ErrIdAccountNicknameFrequent  = "err_account_nickname_frequent"
+
+	ErrIdAccountAvatarLarge  = "err_account_avatar_large"
+	ErrIdAccountAvatarFormat = "err_account_avatar_format"
+)
+
+const (
+	ErrIdAuthPhoneCodeDisabled   = "err_auth_phone_code_disabled"
+	ErrIdAuthEmailDomainDisabled = "err_auth_email_domain_disabled"
+)
+
+const (
+	ErrIdUserInfoRequired     = "err_user_info_required"
+	ErrIdUserGenderRequired   = "err_user_gender_required"
+	ErrIdUserBirthdayRequired = "err_user_birthday_required"
+	ErrIdUserAccountLinked    = "err_user_account_linked"
+
+	ErrIdUserIdentityFormat   = "err_user_identity_format"
+	ErrIdUserIdentityExists   = "err_user_identity_exists"
+	ErrIdUserIdentityVerified = "err_user_identity_verified"
+	ErrIdUserIdentityPending  = "err_user_identity_pending"
+	ErrIdUserIdentityRequired = "err_user_identity_required"
+)
+
+const (
+	ErrIdOrgPermissionDenied = "err_org_permission_denied"
+	ErrIdOrgMemberExists     = "err_org_member_exists"
+	ErrIdOrgMemberNone       = "err_org_member_none"
+	ErrIdOrgOwnerFixed       = "err_org_owner_fixed"
+	ErrIdOrgInviteInvalid    = "err_org_invite_invalid"
+	ErrIdOrgInviteTarget     = "err_org_invite_target"
+	ErrIdOrgInviteOnly       = "err_org_invite_only"
+)
+
+const (
+	ErrIdPermDenied        = "err_perm_denied"
+	ErrIdPermDomainInvalid = "err_perm_domain_invalid"
+	ErrIdPermPolicyInvalid = "err_perm_policy_invalid"
+	ErrIdPermRoleCycle     = "err_perm_role_cycle"
 )
 
 var (
 	// ErrCodePatterns 错误信息映射
 	ErrCodePatterns = map[int][]string{
 		ErrCodeDB: {
-			ErrIdDBPkDuplicated,
 			ErrIdDBAddNil,
 			ErrIdDBDelNil,
 			ErrIdDBUpdNil,
 			ErrIdDBQueNil,
-			ErrIdDBFieldNil,
-			ErrIdDBFieldLarge,
 			ErrIdDBFieldShort,
 			ErrIdDBFieldMax,
 			ErrIdDBFieldMin,
 			ErrIdDBFieldRange,
 			ErrIdDBFieldUnDefined,
 			ErrIdDBQueParams,
-			ErrIdDBQueNone,
-			ErrIdDBQueForeignNone,
+			ErrIdDBField,
+		},
+		ErrCodeDBUnique:      {ErrIdDBPkDuplicated},
+		ErrCodeDBForeignNone: {ErrIdDBQueForeignNone},
+		ErrCodeDBForeignUsed: {ErrIdDBForeignUsed},
+		ErrCodeDBTooLong:     {ErrIdDBFieldLarge},
+		ErrCodeDBNotNull:     {ErrIdDBFieldNil},
+		ErrCodeDBDeadlock:    {ErrIdDBDeadlock},
+		ErrCodeDBTimeout:     {ErrIdDBTimeout},
+		ErrCodeDBVersion:     {ErrIdDBVersionConflict},
+		ErrCodeDBNone:        {ErrIdDBQueNone},
+		ErrCodeAccount: {
+			ErrIdAccountQuotaClosed,
+			ErrIdAccountQuotaCellphone,
+			ErrIdAccountQuotaEmail,
+			ErrIdAccountQuotaBio,
+			ErrIdAccountQuotaThird,
+			ErrIdAccountQuotaUser,
+			ErrIdAccountNicknameRequired,
+			ErrIdAccountNicknameLength,
+			ErrIdAccountNicknameSensitive,
+			ErrIdAccountNicknameExists,
+			ErrIdAccountNicknameFrequent,
+			ErrIdAccountAvatarLarge,
+			ErrIdAccountAvatarFormat,
+		},
+		ErrCodeAuth: {
+			ErrIdAuthPhoneCodeDisabled,
+			ErrIdAuthEmailDomainDisabled,
+		},
+		ErrCodeUser: {
+			ErrIdUserInfoRequired,
+			ErrIdUserGenderRequired,
+			ErrIdUserBirthdayRequired,
+			ErrIdUserAccountLinked,
+			ErrIdUserIdentityFormat,
+			ErrIdUserIdentityExists,
+			ErrIdUserIdentityVerified,
+			ErrIdUserIdentityPending,
+			ErrIdUserIdentityRequired,
+		},
+		ErrCodeOrg: {
+			ErrIdOrgPermissionDenied,
+			ErrIdOrgMemberExists,
+			ErrIdOrgMemberNone,
+			ErrIdOrgOwnerFixed,
+			ErrIdOrgInviteInvalid,
+			ErrIdOrgInviteTarget,
+			ErrIdOrgInviteOnly,
+		},
+		ErrCodePerm: {
+			ErrIdPermDenied,
+			ErrIdPermDomainInvalid,
+			ErrIdPermPolicyInvalid,
+			ErrIdPermRoleCycle,
 		},
 	}
 
 	// ErrMsgPatterns 错误模式匹配
 	ErrMsgPatterns = map[string]string{
 		"duplicate key value violates unique constraint": ErrIdDBPkDuplicated,
+		// 数据库错误翻译时按ID匹配，才能带上各自的错误码
+		ErrIdDBPkDuplicated:    ErrIdDBPkDuplicated,
+		ErrIdDBQueForeignNone:  ErrIdDBQueForeignNone,
+		ErrIdDBForeignUsed:     ErrIdDBForeignUsed,
+		ErrIdDBFieldLarge:      ErrIdDBFieldLarge,
+		ErrIdDBFieldNil:        ErrIdDBFieldNil,
+		ErrIdDBDeadlock:        ErrIdDBDeadlock,
+		ErrIdDBTimeout:         ErrIdDBTimeout,
+		ErrIdDBVersionConflict: ErrIdDBVersionConflict,
+		ErrIdDBQueNone:         ErrIdDBQueNone,
 	}
 )
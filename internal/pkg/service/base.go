@@ -1,23 +1,37 @@
 package service
 
 import (
+	"context"
 	"gorm.io/gorm"
-	"katydid-mp-user/utils"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/data"
+	"katydid-mp-user/pkg/perm"
+	"slices"
 )
 
 // TODO:GG behaviour , 过滤器，依赖注入
 
+const (
+	ActorTypeNone    uint8 = iota // 未知 (没有数据权限)
+	ActorTypeAccount              // 账号
+	ActorTypeSystem               // 系统 (定时任务/内部调用，所有数据)
+)
+
 type Ctx struct {
-	ActorId    uint64      // 操作者ID
-	ActorType  uint8       // 操作者类型
-	Permission []string    // 权限列表 TODO:GG 权限Casbin?
-	Extra      utils.KSMap // 扩展信息
-	Tx         *gorm.DB    // 事务对象
+	ActorId    uint64     // 操作者ID
+	ActorType  uint8      // 操作者类型
+	OwnKind    int16      // 操作者所属own类型
+	OwnID      uint64     // 操作者所属own
+	Roles      []string   // 角色 (token里的)
+	Permission []string   // 权限 "资源:动作" (token里的scopes，没有的再问casbin)
+	Reason     string     // 操作原因 (状态变更历史用)
+	Extra      data.KSMap // 扩展信息
+	Tx         *gorm.DB   // 事务对象
 }
 
 func NewCtx(
 	actorId uint64, actorType uint8,
-	extra utils.KSMap,
+	extra data.KSMap,
 ) *Ctx {
 	if extra == nil {
 		extra = make(map[string]any)
@@ -31,6 +45,146 @@ func NewCtx(
 	}
 }
 
+// NewCtxSystem 系统操作的上下文 (所有数据)
+func NewCtxSystem() *Ctx {
+	return NewCtx(0, ActorTypeSystem, nil)
+}
+
+// WithOwn 设置操作者所属own
+func (c *Ctx) WithOwn(ownKind int16, ownID uint64) *Ctx {
+	c.OwnKind = ownKind
+	c.OwnID = ownID
+	return c
+}
+
+// WithPermission 设置角色和权限
+func (c *Ctx) WithPermission(roles, permission []string) *Ctx {
+	c.Roles = roles
+	c.Permission = permission
+	return c
+}
+
+// WithReason 设置操作原因
+func (c *Ctx) WithReason(reason string) *Ctx {
+	c.Reason = reason
+	return c
+}
+
+// Context 带操作者/事务的上下文 (给仓储WithContext用，有数据范围的表按操作者的范围查/改)
+func (c *Ctx) Context() context.Context {
+	if c == nil {
+		return context.Background()
+	}
+	actor := &storage.Actor{Type: c.ActorType, ID: c.ActorId, Reason: c.Reason}
+	if (c.ActorType == ActorTypeSystem) || ((c.ActorType == ActorTypeAccount) && (c.ActorId > 0)) {
+		actor.Scope = c.DataScope // 仓储按操作者的数据范围自动加条件，没登录的算没有操作者
+	}
+	ctx := storage.WithActor(context.Background(), actor)
+	if c.Tx != nil {
+		ctx = storage.WithTx(ctx, c.Tx)
+	}
+	return ctx
+}
+
+// Domain 操作者所属own的域
+func (c *Ctx) Domain() string {
+	return perm.Domain(int(c.OwnKind), c.OwnID)
+}
+
+// IsPlatform 是不是平台操作者 (系统，或者平台域的账号)，可以跨域管理
+func (c *Ctx) IsPlatform() bool {
+	if c == nil {
+		return false
+	}
+	switch c.ActorType {
+	case ActorTypeSystem:
+		return true
+	case ActorTypeAccount:
+		return (c.ActorId > 0) && perm.IsPlatform(c.Domain())
+	}
+	return false
+}
+
+// CanManageOwn 能不能管理own (自己所属的own，或者平台操作者)，具体的动作还要走鉴权
+func (c *Ctx) CanManageOwn(ownKind int16, ownID uint64) bool {
+	if c.IsPlatform() {
+		return true
+	}
+	return (c != nil) && (c.ActorType == ActorTypeAccount) && (c.ActorId > 0) &&
+		(c.Domain() == perm.Domain(int(ownKind), ownID))
+}
+
+// Can 操作者在自己的域里有没有 资源:动作 的权限 (系统都有，没登录的都没有)
+// 服务里自己鉴权用，不依赖鉴权中间件 (中间件可以不开)
+func (c *Ctx) Can(obj, act string) bool {
+	if c == nil {
+		return false
+	}
+	switch c.ActorType {
+	case ActorTypeSystem:
+		return true
+	case ActorTypeAccount:
+		return (c.ActorId > 0) && c.hasPermission(obj, act)
+	}
+	return false
+}
+
+// IsAdmin 是不是平台管理员 (平台操作者，并且有 资源:动作 的权限)
+func (c *Ctx) IsAdmin(obj, act string) bool {
+	return c.IsPlatform() && c.Can(obj, act)
+}
+
+// DataScope 操作者对资源的数据范围 (给仓储用)
+// 系统是所有数据，账号按权限 资源:all/own 取最大的，都没有是自己的数据，其他(含nil)没有数据权限
+// all只有平台域的账号才算 (其他域的策略/token里有也不算)，不然own的管理员能看到所有own的数据
+func (c *Ctx) DataScope(obj string) *storage.Scope {
+	if c == nil {
+		return storage.NewScope(storage.DataScopeNone, 0, 0, 0)
+	}
+	switch c.ActorType {
+	case ActorTypeSystem:
+		return storage.NewScope(storage.DataScopeAll, c.ActorId, c.OwnKind, c.OwnID)
+	case ActorTypeAccount:
+		if c.ActorId <= 0 {
+			break
+		}
+		kinds := []storage.DataScope{storage.DataScopeOwn}
+		if c.IsPlatform() {
+			kinds = []storage.DataScope{storage.DataScopeAll, storage.DataScopeOwn}
+		}
+		kind := storage.DataScopeSelf
+		for _, k := range kinds {
+			if c.hasPermission(obj, k.String()) {
+				kind = k
+				break
+			}
+		}
+		return storage.NewScope(kind, c.ActorId, c.OwnKind, c.OwnID)
+	}
+	return storage.NewScope(storage.DataScopeNone, c.ActorId, c.OwnKind, c.OwnID)
+}
+
+// hasPermission 操作者在自己的域里有没有权限 (先看token里的，再问casbin)
+func (c *Ctx) hasPermission(obj, act string) bool {
+	if slices.Contains(c.Permission, obj+":"+act) || slices.Contains(c.Permission, obj+":"+perm.PolicyActAll) {
+		return true
+	}
+	if perm.Get() == nil {
+		return false
+	}
+	dom := perm.Domain(int(c.OwnKind), c.OwnID)
+	subjects := []string{perm.SubAccount(c.ActorId)}
+	for _, role := range c.Roles {
+		subjects = append(subjects, perm.SubRole(role))
+	}
+	for _, sub := range subjects {
+		if ok, _ := perm.Enforcer(perm.NewPolicyInDomain(sub, dom, obj, act)); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type IService[T any] interface {
 	WithCtx(ctx *Ctx) IService[T]
 	Ctx() *Ctx
@@ -54,12 +208,18 @@ func (s *Base) Ctx() *Ctx {
 	return s.ctx
 }
 
+// WithCtx 绑定上下文的副本 (服务是共享的，不能改自己)
 func (s *Base) WithCtx(ctx *Ctx) IService[any] {
-	s.ctx = ctx
-	return s
+	return &Base{ctx: ctx}
 }
 
+// WithTx 绑定事务的副本 (上下文也复制一份，不影响原来的)
 func (s *Base) WithTx(tx *gorm.DB) IService[any] {
-	s.ctx.Tx = tx
-	return s
+	ctx := NewCtx(0, ActorTypeNone, nil)
+	if s.ctx != nil {
+		copied := *s.ctx
+		ctx = &copied
+	}
+	ctx.Tx = tx
+	return &Base{ctx: ctx}
 }
@@ -1,12 +1,22 @@
 package service
 
+import (
+	"katydid-mp-user/pkg/valid"
+	"strings"
+)
+
 type (
 	Limits struct {
 		OwnKind int16  // 拥有者类型 (组织/应用/用户/...)
 		OwnID   uint64 // 拥有者ID
 
-		Verify  *LimitVerify  // 验证限制
-		Account *LimitAccount // 账号限制
+		Verify   *LimitVerify   // 验证限制
+		Auth     *LimitAuth     // 认证限制
+		Account  *LimitAccount  // 账号限制
+		Risk     *LimitRisk     // 风控限制
+		Device   *LimitDevice   // 设备限制
+		User     *LimitUser     // 用户限制
+		UserInfo *LimitUserInfo // 用户信息限制
 	}
 
 	// LimitVerify 验证限制
@@ -31,7 +41,9 @@ type (
 	LimitAuth struct {
 		EnablePhoneCodes  *[]string // []phoneCode 可认证的手机区号，nil不限制
 		DisablePhoneCodes *[]string // []phoneCode 禁用的手机区号，nil不限制
-		// ...邮件后缀?
+
+		EnableEmailDomains  *[]string // []domain 可认证的邮箱域名(含子域名)，nil不限制
+		DisableEmailDomains *[]string // []domain 禁用的邮箱域名(含子域名)，nil不限制
 
 		MaxPerUser map[int16]int // [authKind]count 认证最大数量 -1是无限制 一般是1? (user/phone/...)
 
@@ -47,13 +59,15 @@ type (
 		VerifyRegister   bool // 是否需要验证注册
 		VerifyUnRegister bool // 是否需要验证注销
 
+		UnRegisterGraceSeconds int64 // 注销冷静期(s)，期间可恢复 (<=0立即清除)
+
 		MaxPerAuthCellphone int  // 单手机可创建的最大数 -1是无限制 0是关闭 一般是1 (pwd只能是1)
 		MaxPerAuthEmail     int  // 单邮箱可创建的最大数 -1是无限制 0是关闭 一般是1
 		MaxPerAuthBio       int  // 单特征可创建的最大数 -1是无限制 0是关闭 一般是1
 		MaxPerAuthThird     int  // 单第三方可创建的最大数 -1是无限制 0是关闭 一般是1
 		MaxPerAuthShare     bool // 是否可共享账号最大数量(多个平台最多注册的账号数)
 		MaxPerUser          int  // 单用户可创建的最大数 -1是无限制 0是关闭
-		MaxPereUserShar     bool // 是否可共享账号最大数量(多个平台最多注册的账号数)
+		MaxPerUserShare     bool // 是否可共享账号最大数量(多个平台最多注册的账号数)
 
 		TokenExpires        int64            // token过期时间 -1是不过期 0是basic 其他是过期时间
 		TokenRefreshExpires int64            // refresh过期时间 -1是不过期 0是basic 其他是过期时间
@@ -61,11 +75,34 @@ type (
 
 		NicknameRequire  bool   // 是否需要绑定昵称
 		NicknameUnique   bool   // 昵称是否唯一
-		NicknameLenRange [2]int // 昵称长度范围
+		NicknameLenRange [2]int // 昵称长度范围 (字符数，0不限制)
+		NicknameInterval int64  // 修改昵称的间隔(s) (<=0不限制)
 
 		UserInfoRequire   bool // 是否需要绑定用户信息
 		UserBioRequire    bool // 是否需要绑定用户特征
 		UserIDCardRequire bool // 是否需要绑定身份证
+
+		LockFailTimes  int   // 连续登录失败N次后锁定 (<=0不锁定)
+		LockSeconds    int64 // 首次锁定时长(s)，之后每次翻倍
+		LockMaxSeconds int64 // 最大锁定时长(s)
+		LockBlockTimes int   // 连续锁定N次后封锁，需管理员解锁 (<=0不封锁)
+	}
+
+	// LimitRisk 登录风控限制
+	LimitRisk struct {
+		Enable      bool // 是否启用登录风控
+		HistorySize int  // 对比的最近登录记录数
+
+		TravelMaxSpeed    float64 // 最大移动速度(米/秒)，超过即不可能的移动
+		TravelMinDistance float64 // 最小移动距离(米)，小于不计算速度 (定位误差)
+
+		ScoreTravel  int // 不可能的移动 风险分
+		ScoreCountry int // 新的国家 风险分
+		ScoreDevice  int // 新的设备 风险分
+
+		ScoreNotify int // >=此分数 通知用户 (<=0不通知)
+		ScoreVerify int // >=此分数 需要二次验证 (<=0不验证)
+		ScoreBlock  int // >=此分数 拦截登录 (<=0不拦截)
 	}
 
 	LimitAuthPassword struct {
@@ -108,9 +145,15 @@ type (
 
 func newLimitsDef(ownKind int16, ownID uint64) *Limits {
 	return &Limits{
-		OwnKind: ownKind,
-		OwnID:   ownID,
-		Verify:  newLimitVerifyDef(),
+		OwnKind:  ownKind,
+		OwnID:    ownID,
+		Verify:   newLimitVerifyDef(),
+		Auth:     newLimitAuthDef(),
+		Account:  newLimitAccountDef(),
+		Risk:     newLimitRiskDef(),
+		Device:   newLimitDeviceDef(),
+		User:     newLimitUserDef(),
+		UserInfo: newLimitUserInfoDef(),
 	}
 }
 
@@ -129,78 +172,147 @@ func newLimitVerifyDef() *LimitVerify {
 	}
 }
 
-func newLimitAccountDef() *LimitAccount {
-	return &LimitAccount{
-		AuthRequires: []int16{},
-		AuthEnables:  []int16{},
-		//TokenExpires: make(map[int16]map[uint64]int64),
+func newLimitAuthDef() *LimitAuth {
+	return &LimitAuth{
+		EnablePhoneCodes:  nil, // 默认不限制
+		DisablePhoneCodes: nil, // 默认不限制
+
+		EnableEmailDomains:  nil, // 默认不限制
+		DisableEmailDomains: nil, // 默认不限制
+
+		MaxPerUser: map[int16]int{},
 	}
 }
 
-func (s *Base) RegisterLimitVerify(ownKind int16, ownID uint64, limit *LimitVerify) {
-	if s.limits == nil {
-		s.limits = make(map[int16]map[uint64]*Limits)
-	}
-	if s.limits[ownKind] == nil {
-		s.limits[ownKind] = make(map[uint64]*Limits)
+// IsPhoneCodeEnable 手机区号是否可用 (禁用优先，区号可带+)
+func (l *LimitAuth) IsPhoneCodeEnable(code string) bool {
+	countryCode, ok := valid.IsPhoneCountryCode(code)
+	if !ok {
+		return false
 	}
-	if s.limits[ownKind][ownID] == nil {
-		s.limits[ownKind][ownID] = &Limits{
-			OwnKind: ownKind,
-			OwnID:   ownID,
-			Verify:  limit,
+	match := func(codes []string) bool {
+		for _, c := range codes {
+			if strings.TrimPrefix(strings.TrimSpace(c), "+") == countryCode.Code {
+				return true
+			}
 		}
-	} else {
-		s.limits[ownKind][ownID].Verify = limit
+		return false
 	}
+	if (l.DisablePhoneCodes != nil) && match(*l.DisablePhoneCodes) {
+		return false
+	} else if l.EnablePhoneCodes != nil {
+		return match(*l.EnablePhoneCodes)
+	}
+	return true
 }
 
-func (s *Base) RegisterLimitAccount(ownKind int16, ownID uint64, limit *LimitAccount) {
-	if s.limits == nil {
-		s.limits = make(map[int16]map[uint64]*Limits)
-	}
-	if s.limits[ownKind] == nil {
-		s.limits[ownKind] = make(map[uint64]*Limits)
+// IsEmailDomainEnable 邮箱域名是否可用 (禁用优先，子域名跟随上级域名)
+func (l *LimitAuth) IsEmailDomainEnable(domain string) bool {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if len(domain) <= 0 {
+		return false
 	}
-	if s.limits[ownKind][ownID] == nil {
-		s.limits[ownKind][ownID] = &Limits{
-			OwnKind: ownKind,
-			OwnID:   ownID,
-			Account: limit,
+	match := func(domains []string) bool {
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+			if (domain == d) || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
 		}
-	} else {
-		s.limits[ownKind][ownID].Account = limit
+		return false
+	}
+	if (l.DisableEmailDomains != nil) && match(*l.DisableEmailDomains) {
+		return false
+	} else if l.EnableEmailDomains != nil {
+		return match(*l.EnableEmailDomains)
 	}
+	return true
 }
 
-func (s *Base) GetLimitVerify(ownKind int16, ownID uint64) *LimitVerify {
-	if s.limits == nil {
-		s.limits = make(map[int16]map[uint64]*Limits)
-	}
-	if s.limits[ownKind] == nil {
-		s.limits[ownKind] = make(map[uint64]*Limits)
-	}
-	if s.limits[ownKind][ownID] == nil {
-		s.limits[ownKind][ownID] = newLimitsDef(ownKind, ownID)
+func newLimitAccountDef() *LimitAccount {
+	return &LimitAccount{
+		AuthRequires: []int16{},
+		AuthEnables:  []int16{},
+		//TokenExpires: make(map[int16]map[uint64]int64),
+		UnRegisterGraceSeconds: 15 * 24 * 60 * 60, // 默认注销冷静期15d
+		MaxPerAuthCellphone:    1,                 // 默认单手机1个
+		MaxPerAuthEmail:        1,                 // 默认单邮箱1个
+		MaxPerAuthBio:          1,                 // 默认单特征1个
+		MaxPerAuthThird:        1,                 // 默认单三方1个
+		MaxPerUser:             -1,                // 默认单用户不限制
+		NicknameLenRange:       [2]int{2, 20},     // 默认昵称2~20个字符
+		NicknameInterval:       7 * 24 * 60 * 60,  // 默认7d修改一次昵称
+		LockFailTimes:          5,                 // 默认连续失败5次锁定
+		LockSeconds:            60,                // 默认首次锁定1m
+		LockMaxSeconds:         24 * 60 * 60,      // 默认最长锁定24h
+		LockBlockTimes:         5,                 // 默认连续锁定5次封锁
 	}
-	if s.limits[ownKind][ownID].Verify == nil {
-		s.limits[ownKind][ownID].Verify = newLimitVerifyDef()
+}
+
+func newLimitRiskDef() *LimitRisk {
+	return &LimitRisk{
+		Enable:            true,
+		HistorySize:       10,         // 默认对比最近10次登录
+		TravelMaxSpeed:    1000 / 3.6, // 默认1000km/h (民航速度)
+		TravelMinDistance: 100 * 1000, // 默认100km
+		ScoreTravel:       60,         // 默认不可能的移动60分
+		ScoreCountry:      30,         // 默认新的国家30分
+		ScoreDevice:       20,         // 默认新的设备20分
+		ScoreNotify:       20,         // 默认>=20通知
+		ScoreVerify:       50,         // 默认>=50二次验证
+		ScoreBlock:        90,         // 默认>=90拦截
 	}
-	return s.limits[ownKind][ownID].Verify
+}
+
+// GetLimits 获取拥有者的全部限制 (只读，不要修改)
+func (s *Base) GetLimits(ownKind int16, ownID uint64) *Limits {
+	return GetLimitsRegistry().Get(ownKind, ownID)
+}
+
+func (s *Base) GetLimitVerify(ownKind int16, ownID uint64) *LimitVerify {
+	return s.GetLimits(ownKind, ownID).Verify
+}
+
+func (s *Base) GetLimitAuth(ownKind int16, ownID uint64) *LimitAuth {
+	return s.GetLimits(ownKind, ownID).Auth
 }
 
 func (s *Base) GetLimitAccount(ownKind int16, ownID uint64) *LimitAccount {
-	if s.limits == nil {
-		s.limits = make(map[int16]map[uint64]*Limits)
-	}
-	if s.limits[ownKind] == nil {
-		s.limits[ownKind] = make(map[uint64]*Limits)
-	}
-	if s.limits[ownKind][ownID] == nil {
-		s.limits[ownKind][ownID] = newLimitsDef(ownKind, ownID)
+	return s.GetLimits(ownKind, ownID).Account
+}
+
+func (s *Base) GetLimitRisk(ownKind int16, ownID uint64) *LimitRisk {
+	return s.GetLimits(ownKind, ownID).Risk
+}
+
+func (s *Base) GetLimitDevice(ownKind int16, ownID uint64) *LimitDevice {
+	return s.GetLimits(ownKind, ownID).Device
+}
+
+func (s *Base) GetLimitUser(ownKind int16, ownID uint64) *LimitUser {
+	return s.GetLimits(ownKind, ownID).User
+}
+
+func (s *Base) GetLimitUserInfo(ownKind int16, ownID uint64) *LimitUserInfo {
+	return s.GetLimits(ownKind, ownID).UserInfo
+}
+
+func newLimitDeviceDef() *LimitDevice {
+	return &LimitDevice{
+		TrustExpires:    30 * 24 * 60 * 60, // 默认信任30d
+		MaxTrustPerUser: -1,                // 默认不限制
 	}
-	if s.limits[ownKind][ownID].Account == nil {
-		s.limits[ownKind][ownID].Account = newLimitAccountDef()
+}
+
+func newLimitUserDef() *LimitUser {
+	return &LimitUser{
+		MaxPerCellphone: 1, // 默认单手机1个
+		MaxPerEmail:     1, // 默认单邮箱1个
+		MaxPerBio:       1, // 默认单特征1个
+		MaxPerThird:     1, // 默认单三方1个
 	}
-	return s.limits[ownKind][ownID].Account
+}
+
+func newLimitUserInfoDef() *LimitUserInfo {
+	return &LimitUserInfo{}
 }
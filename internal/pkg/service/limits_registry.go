@@ -0,0 +1,378 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/data"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LimitsSourceDefault = "default" // 代码默认值
+	LimitsSourceToml    = "toml"    // toml默认值
+
+	limitsChainMaxDepth = 8                // 上级链最大深度 (防环)
+	limitsCacheExpires  = 30 * time.Second // 合并后的缓存 (没有redis通知时，其他节点的变更最多晚这么久生效)
+)
+
+type (
+	// ILimitsStore 限制持久化 (按拥有者存储覆盖项)
+	ILimitsStore interface {
+		Select(ownKind int16, ownID uint64) (*model.Limits, *errs.CodeErrs)
+		Upsert(ownKind int16, ownID uint64, content data.KSMap) *errs.CodeErrs
+		UpsertParent(ownKind int16, ownID uint64, parentKind int16, parentID uint64) *errs.CodeErrs
+	}
+
+	// LimitsLayer 合并链上的一层
+	LimitsLayer struct {
+		Source  string     `json:"source"` // default/toml/{ownKind}:{ownId}
+		Content data.KSMap `json:"content"`
+	}
+
+	// LimitsExplain 生效的限制+每个字段的来源
+	LimitsExplain struct {
+		Limits  *Limits           `json:"limits"`
+		Sources map[string]string `json:"sources"` // [Section.Field]source
+		Chain   []*LimitsLayer    `json:"chain"`   // 从上到下
+	}
+
+	// LimitsRegistry 限制注册表 (代码默认值 <- toml默认值 <- 上级db覆盖项 <- 自己db覆盖项)，读多写少
+	LimitsRegistry struct {
+		mu    sync.RWMutex
+		store ILimitsStore
+		defs  data.KSMap                         // toml里的默认值
+		cache map[int16]map[uint64]*limitsCached // 合并后的缓存
+		gen   uint64                             // 失效次数 (读库期间失效了，读到的结果不缓存)
+
+		OnChanged func() // 覆盖项/上级变更后 (通知其他节点清缓存)
+	}
+
+	limitsCached struct {
+		limits   *Limits
+		expireAt time.Time
+	}
+)
+
+var limitsRegistry = NewLimitsRegistry(nil, nil)
+
+func NewLimitsRegistry(store ILimitsStore, defs data.KSMap) *LimitsRegistry {
+	return &LimitsRegistry{
+		store: store,
+		defs:  defs,
+		cache: make(map[int16]map[uint64]*limitsCached),
+	}
+}
+
+// InitLimits 初始化全局限制注册表
+func InitLimits(store ILimitsStore, defs data.KSMap) {
+	limitsRegistry = NewLimitsRegistry(store, defs)
+}
+
+// GetLimitsRegistry 全局限制注册表
+func GetLimitsRegistry() *LimitsRegistry {
+	return limitsRegistry
+}
+
+// Get 获取合并后的限制 (只读，不要修改)
+func (r *LimitsRegistry) Get(ownKind int16, ownID uint64) *Limits {
+	r.mu.RLock()
+	cached, ok := r.cache[ownKind][ownID]
+	gen := r.gen
+	r.mu.RUnlock()
+	if ok && time.Now().Before(cached.expireAt) {
+		return cached.limits
+	}
+
+	limits, err := r.load(ownKind, ownID)
+	if err != nil {
+		// 读取失败用默认值，不缓存 (下次重试)
+		log.Error("■ ■ Limits ■ ■ 读取失败",
+			log.FInt16("ownKind", ownKind), log.FUint64("ownId", ownID), log.FError(err))
+		return limits
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gen != gen {
+		return limits // 读库期间失效了，可能是旧的，不缓存
+	}
+	if r.cache[ownKind] == nil {
+		r.cache[ownKind] = make(map[uint64]*limitsCached)
+	}
+	r.cache[ownKind][ownID] = &limitsCached{limits: limits, expireAt: time.Now().Add(limitsCacheExpires)}
+	return limits
+}
+
+// GetOverrides 获取db里自己的覆盖项
+func (r *LimitsRegistry) GetOverrides(ownKind int16, ownID uint64) (data.KSMap, *errs.CodeErrs) {
+	if r.store == nil {
+		return data.KSMap{}, nil
+	}
+	bean, err := r.store.Select(ownKind, ownID)
+	if err != nil {
+		return nil, err
+	} else if bean == nil {
+		return data.KSMap{}, nil
+	}
+	return bean.Content, nil
+}
+
+// Explain 生效的限制，以及每个字段来自哪一层 (调试用，不走缓存)
+func (r *LimitsRegistry) Explain(ownKind int16, ownID uint64) (*LimitsExplain, *errs.CodeErrs) {
+	layers, err := r.layers(ownKind, ownID)
+	if err != nil {
+		return nil, err
+	}
+	explain := &LimitsExplain{
+		Limits:  newLimitsDef(ownKind, ownID),
+		Sources: make(map[string]string),
+		Chain:   layers,
+	}
+	for _, path := range limitsFieldPaths(nil) {
+		explain.Sources[path] = LimitsSourceDefault
+	}
+	for _, layer := range layers {
+		if e := applyLimits(explain.Limits, layer.Content); e != nil {
+			return nil, errs.Match2("limits_format_err").WrapErrs(fmt.Errorf("%s: %w", layer.Source, e))
+		}
+		for _, path := range limitsFieldPaths(layer.Content) {
+			explain.Sources[path] = layer.Source
+		}
+	}
+	return explain, nil
+}
+
+// Put 保存覆盖项 (整体替换)，并让缓存失效
+func (r *LimitsRegistry) Put(ownKind int16, ownID uint64, content data.KSMap) (*Limits, *errs.CodeErrs) {
+	if r.store == nil {
+		return nil, errs.Match2("limits_store_nil")
+	}
+	// 先检查格式
+	if e := applyLimits(newLimitsDef(ownKind, ownID), content); e != nil {
+		return nil, errs.Match2("limits_format_err").WrapErrs(e)
+	}
+	if err := r.store.Upsert(ownKind, ownID, content); err != nil {
+		return nil, err
+	}
+	r.changed() // 下级都继承了这一层
+	return r.Get(ownKind, ownID), nil
+}
+
+// PutParent 设置上级 (parentKind<=0是取消)，上级类型值必须更小 (org->app->client)
+func (r *LimitsRegistry) PutParent(ownKind int16, ownID uint64, parentKind int16, parentID uint64) (*Limits, *errs.CodeErrs) {
+	if r.store == nil {
+		return nil, errs.Match2("limits_store_nil")
+	}
+	if parentKind <= 0 {
+		parentKind, parentID = 0, 0
+	} else if parentKind >= ownKind {
+		return nil, errs.Match2("limits_parent_kind_err")
+	} else {
+		// 上级链里不能有自己
+		layers, err := r.layers(parentKind, parentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range layers {
+			if layer.Source == limitsSource(ownKind, ownID) {
+				return nil, errs.Match2("limits_parent_loop").WrapErrs(fmt.Errorf("%s", layer.Source))
+			}
+		}
+	}
+	if err := r.store.UpsertParent(ownKind, ownID, parentKind, parentID); err != nil {
+		return nil, err
+	}
+	r.changed()
+	return r.Get(ownKind, ownID), nil
+}
+
+// SetDefaults 替换toml默认值 (配置热更新)，所有缓存失效
+func (r *LimitsRegistry) SetDefaults(defs data.KSMap) {
+	r.mu.Lock()
+	r.defs = defs
+	r.mu.Unlock()
+	r.InvalidateAll()
+}
+
+// Invalidate 单个拥有者的缓存失效 (只有本节点，下级继承了这一层的也不管，改了覆盖项的用InvalidateAll)
+func (r *LimitsRegistry) Invalidate(ownKind int16, ownID uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	if owns, ok := r.cache[ownKind]; ok {
+		delete(owns, ownID)
+	}
+}
+
+// InvalidateAll 所有缓存失效 (只有本节点，其他节点的变更通知也走这里)
+func (r *LimitsRegistry) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	r.cache = make(map[int16]map[uint64]*limitsCached)
+}
+
+// changed 本节点改了db，缓存失效并通知其他节点
+func (r *LimitsRegistry) changed() {
+	r.InvalidateAll()
+	if r.OnChanged != nil {
+		r.OnChanged()
+	}
+}
+
+// load 按层合并 代码默认值 <- toml默认值 <- 上级db覆盖项 <- 自己db覆盖项
+func (r *LimitsRegistry) load(ownKind int16, ownID uint64) (*Limits, *errs.CodeErrs) {
+	limits := newLimitsDef(ownKind, ownID)
+	layers, err := r.layers(ownKind, ownID)
+	for _, layer := range layers {
+		if e := applyLimits(limits, layer.Content); e != nil {
+			if layer.Source == LimitsSourceToml {
+				log.Warn("■ ■ Limits ■ ■ 默认值格式错误", log.FError(e))
+				continue
+			}
+			return limits, errs.Match2("limits_format_err").WrapErrs(fmt.Errorf("%s: %w", layer.Source, e))
+		}
+	}
+	return limits, err
+}
+
+// layers 合并链 (toml + 从最上级到自己)，出错时返回已读到的上面几层
+func (r *LimitsRegistry) layers(ownKind int16, ownID uint64) ([]*LimitsLayer, *errs.CodeErrs) {
+	r.mu.RLock()
+	defs := r.defs
+	r.mu.RUnlock()
+	layers := []*LimitsLayer{{Source: LimitsSourceToml, Content: defs}}
+	if r.store == nil {
+		return layers, nil
+	}
+
+	// 从自己往上找
+	chain := make([]*LimitsLayer, 0)
+	visited := make(map[string]bool)
+	kind, id := ownKind, ownID
+	for depth := 0; ; depth++ {
+		source := limitsSource(kind, id)
+		if visited[source] || (depth >= limitsChainMaxDepth) {
+			return layers, errs.Match2("limits_parent_loop").WrapErrs(fmt.Errorf("%s", source))
+		}
+		visited[source] = true
+
+		bean, err := r.store.Select(kind, id)
+		if err != nil {
+			return layers, err
+		} else if bean == nil {
+			break
+		}
+		chain = append(chain, &LimitsLayer{Source: source, Content: bean.Content})
+		if !bean.HasParent() {
+			break
+		}
+		kind, id = bean.ParentKind, bean.ParentID
+	}
+
+	// 倒过来，上级在前
+	for i := len(chain) - 1; i >= 0; i-- {
+		layers = append(layers, chain[i])
+	}
+	return layers, nil
+}
+
+func limitsSource(ownKind int16, ownID uint64) string {
+	return fmt.Sprintf("%d:%d", ownKind, ownID)
+}
+
+// limitsFieldPaths 覆盖项里出现的字段 (Section.Field)，content为nil时返回所有字段
+func limitsFieldPaths(content data.KSMap) []string {
+	paths := make([]string, 0)
+	limitsType := reflect.TypeOf(Limits{})
+	for i := 0; i < limitsType.NumField(); i++ {
+		section := limitsType.Field(i)
+		if section.Type.Kind() != reflect.Ptr {
+			continue // OwnKind/OwnID
+		}
+		fields := section.Type.Elem()
+		var sub map[string]any
+		if content != nil {
+			value, ok := lookupFold(content, section.Name)
+			if !ok {
+				continue
+			}
+			sub, _ = value.(map[string]any)
+			if sub == nil {
+				// 整段被替换 (null等)
+				for j := 0; j < fields.NumField(); j++ {
+					paths = append(paths, section.Name+"."+fields.Field(j).Name)
+				}
+				continue
+			}
+		}
+		for j := 0; j < fields.NumField(); j++ {
+			name := fields.Field(j).Name
+			if sub != nil {
+				if _, ok := lookupFold(sub, name); !ok {
+					continue
+				}
+			}
+			paths = append(paths, section.Name+"."+name)
+		}
+	}
+	return paths
+}
+
+// lookupFold 不区分大小写查找key (和json解码一致)
+func lookupFold(m map[string]any, key string) (any, bool) {
+	if value, ok := m[key]; ok {
+		return value, true
+	}
+	for k, value := range m {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// applyLimits 按字段覆盖 (json解码到已有结构上，没出现的字段保留原值，key不区分大小写)
+func applyLimits(limits *Limits, content data.KSMap) error {
+	if len(content) <= 0 {
+		return nil
+	}
+	ownKind, ownID := limits.OwnKind, limits.OwnID
+	bytes, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(bytes, limits)
+	limits.OwnKind, limits.OwnID = ownKind, ownID // 不能被覆盖
+
+	// 被null覆盖的，恢复默认值
+	def := newLimitsDef(ownKind, ownID)
+	if limits.Verify == nil {
+		limits.Verify = def.Verify
+	}
+	if limits.Auth == nil {
+		limits.Auth = def.Auth
+	}
+	if limits.Account == nil {
+		limits.Account = def.Account
+	}
+	if limits.Risk == nil {
+		limits.Risk = def.Risk
+	}
+	if limits.Device == nil {
+		limits.Device = def.Device
+	}
+	if limits.User == nil {
+		limits.User = def.User
+	}
+	if limits.UserInfo == nil {
+		limits.UserInfo = def.UserInfo
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"katydid-mp-user/internal/pkg/storage"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"time"
+)
+
+const (
+	conflictRetryMax   = 3                     // 乐观锁冲突/序列化失败/死锁的重试次数
+	conflictRetryDelay = 20 * time.Millisecond // 重试间隔 (按次数递增)
+)
+
+// Transaction 工作单元，fn里的仓储读写在一个事务里 (仓储用WithContext(ctx)绑定)
+// 嵌套调用的是savepoint，最外层遇到序列化失败/死锁会整个重试，fn要能重复执行
+// 乐观锁版本冲突不在这里重试，要重试的外面包RetryConflict (重新查询再修改)
+func Transaction(ctx context.Context, fn func(ctx context.Context) *errs.CodeErrs) *errs.CodeErrs {
+	var cErr *errs.CodeErrs
+	err := storage.Transaction(ctx, func(ctx context.Context) error {
+		if cErr = fn(ctx); cErr != nil {
+			return cErr
+		}
+		return nil
+	})
+	if cErr != nil {
+		return cErr
+	} else if err != nil {
+		return storage.TranslateErr(err)
+	}
+	return nil
+}
+
+// RetryConflict 乐观锁版本冲突/序列化失败/死锁时重试，times>0是重试 (fn里要重新查询再修改)
+func RetryConflict(fn func(times int) *errs.CodeErrs) *errs.CodeErrs {
+	var err *errs.CodeErrs
+	for i := 0; i <= conflictRetryMax; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * conflictRetryDelay)
+		}
+		if err = fn(i); err == nil {
+			return nil
+		} else if !storage.IsVersionConflict(err) && !storage.IsRetryable(err) {
+			return err
+		}
+		log.Warn("■ ■ Service ■ ■ 版本冲突", log.FInt("times", i+1), log.FError(err))
+	}
+	return err
+}
@@ -3,24 +3,35 @@ package storage
 import (
 	"context"
 	"gorm.io/gorm"
+	"katydid-mp-user/pkg/log"
 	"katydid-mp-user/pkg/storage"
 )
 
 // 表名常量定义
 const (
-	TableGroupAuth       TableName = "auths"
-	TableAuthVerify                = TableGroupAuth + ".verify"
-	TableAuthAccount               = TableGroupAuth + ".account"
-	TableAuthAuth                  = TableGroupAuth + ".auth"
-	TableAuthAccountAuth           = TableGroupAuth + ".account_auth"
-	TableAuthToken                 = TableGroupAuth + ".token"
-	TableAuthAccess                = TableGroupAuth + ".access"
+	TableGroupAuth         TableName = "auths"
+	TableAuthVerify                  = TableGroupAuth + ".verify"
+	TableAuthAccount                 = TableGroupAuth + ".account"
+	TableAuthAuth                    = TableGroupAuth + ".auth"
+	TableAuthAccountAuth             = TableGroupAuth + ".account_auth"
+	TableAuthToken                   = TableGroupAuth + ".token"
+	TableAuthAccess                  = TableGroupAuth + ".access"
+	TableAuthExport                  = TableGroupAuth + ".export"
+	TableAuthNickname                = TableGroupAuth + ".nickname"
+	TableAuthStatusHistory           = TableGroupAuth + ".status_history"
 
-	TableGroupUser TableName = "users"
+	TableGroupUser    TableName = "users"
+	TableUser                   = TableGroupUser + ".user"
+	TableUserIdentity           = TableGroupUser + ".identity"
 
-	TableGroupClient TableName = "clients"
+	TableGroupClient  TableName = "clients"
+	TableClientLimits           = TableGroupClient + ".limits"
 
-	TableGroupRole TableName = "roles"
+	TableGroupRole  TableName = "roles"
+	TableRoleOrg              = TableGroupRole + ".organization"
+	TableRoleMember           = TableGroupRole + ".member"
+	TableRoleInvite           = TableGroupRole + ".invite"
+	TableRolePolicy           = TableGroupRole + ".policy"
 )
 
 type (
@@ -29,7 +40,9 @@ type (
 
 	// Base 提供基础数据库功能
 	Base struct {
-		ctx context.Context
+		ctx    context.Context
+		scope  *Scope // 指定的数据范围 (nil按上下文里的操作者)
+		system bool   // 上下文里没有操作者时当作系统 (所有数据)
 	}
 )
 
@@ -52,10 +65,36 @@ func NewBase(ctx context.Context) *Base {
 // WithContext 设置上下文
 func (b *Base) WithContext(ctx context.Context) *Base {
 	return &Base{
-		ctx: ctx,
+		ctx:    ctx,
+		scope:  b.scope,
+		system: b.system,
+	}
+}
+
+// WithScope 指定数据范围 (不按上下文里的操作者)，Scoped的查询会自动加上条件
+func (b *Base) WithScope(scope *Scope) *Base {
+	return &Base{
+		ctx:    b.ctx,
+		scope:  scope,
+		system: b.system,
 	}
 }
 
+// System 系统访问 (登录/注册/定时任务等还没有操作者的流程)，上下文里没有操作者时不限制数据范围
+// 不调用的，没有操作者时有数据范围的表查不到也改不了
+func (b *Base) System() *Base {
+	return &Base{
+		ctx:    b.ctx,
+		scope:  b.scope,
+		system: true,
+	}
+}
+
+// Scope 获取指定的数据范围
+func (b *Base) Scope() *Scope {
+	return b.scope
+}
+
 // Context 获取上下文
 func (b *Base) Context() context.Context {
 	if b.ctx == nil {
@@ -64,11 +103,38 @@ func (b *Base) Context() context.Context {
 	return b.ctx
 }
 
-// Psql 获取PostgreSQL连接
+// Psql 获取PostgreSQL连接 (上下文里有事务的用事务)
 func (b *Base) Psql() *gorm.DB {
+	if tx := TxFrom(b.Context()); tx != nil {
+		return tx
+	}
 	return b.GetDB(DefaultPsqlName).WithContext(b.Context())
 }
 
+// Scoped 带数据范围的PostgreSQL表
+func (b *Base) Scoped(table TableName) *gorm.DB {
+	return b.Psql().Table(string(table)).Scopes(b.tableScope(table).Apply(table))
+}
+
+// tableScope 表的数据范围: 指定的 > 上下文里操作者的 > System的不限制 > 没有操作者的查不到
+// 没有数据范围的表 (没注册列的) 不限制
+func (b *Base) tableScope(table TableName) *Scope {
+	if b.scope != nil {
+		return b.scope
+	}
+	columns, ok := scopeColumns[table]
+	if !ok {
+		return nil
+	}
+	if actor := ActorFrom(b.Context()); actor.Scope != nil {
+		return actor.Scope(columns.Resource)
+	} else if b.system {
+		return nil
+	}
+	log.Warn("DB_数据范围_没有操作者", log.FString("table", string(table)))
+	return NewScope(DataScopeNone, 0, 0, 0)
+}
+
 // Msql 获取MySQL连接
 func (b *Base) Msql() *gorm.DB {
 	return b.GetDB(DefaultMsqlName).WithContext(b.Context())
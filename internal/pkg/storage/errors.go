@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"katydid-mp-user/internal/pkg/msg"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/storage"
+)
+
+// 数据库错误的解析在 pkg/storage (驱动相关，不依赖业务)，这里加上错误码/国际化
+type (
+	DBErrKind = storage.DBErrKind
+	DBError   = storage.DBError
+)
+
+const (
+	DBErrUnknown     = storage.DBErrUnknown
+	DBErrUnique      = storage.DBErrUnique
+	DBErrForeignNone = storage.DBErrForeignNone
+	DBErrForeignUsed = storage.DBErrForeignUsed
+	DBErrTooLong     = storage.DBErrTooLong
+	DBErrNotNull     = storage.DBErrNotNull
+	DBErrDeadlock    = storage.DBErrDeadlock
+	DBErrTimeout     = storage.DBErrTimeout
+	DBErrVersion     = storage.DBErrVersion
+	DBErrNone        = storage.DBErrNone
+)
+
+var dbErrIDs = map[DBErrKind]string{
+	DBErrUnique:      msg.ErrIdDBPkDuplicated,
+	DBErrForeignNone: msg.ErrIdDBQueForeignNone,
+	DBErrForeignUsed: msg.ErrIdDBForeignUsed,
+	DBErrTooLong:     msg.ErrIdDBFieldLarge,
+	DBErrNotNull:     msg.ErrIdDBFieldNil,
+	DBErrDeadlock:    msg.ErrIdDBDeadlock,
+	DBErrTimeout:     msg.ErrIdDBTimeout,
+	DBErrVersion:     msg.ErrIdDBVersionConflict,
+	DBErrNone:        msg.ErrIdDBQueNone,
+}
+
+// dbErrCodes 每种类型的错误码 (翻译后的错误按错误码识别)
+var dbErrCodes = map[DBErrKind]int{
+	DBErrUnique:      msg.ErrCodeDBUnique,
+	DBErrForeignNone: msg.ErrCodeDBForeignNone,
+	DBErrForeignUsed: msg.ErrCodeDBForeignUsed,
+	DBErrTooLong:     msg.ErrCodeDBTooLong,
+	DBErrNotNull:     msg.ErrCodeDBNotNull,
+	DBErrDeadlock:    msg.ErrCodeDBDeadlock,
+	DBErrTimeout:     msg.ErrCodeDBTimeout,
+	DBErrVersion:     msg.ErrCodeDBVersion,
+	DBErrNone:        msg.ErrCodeDBNone,
+}
+
+var dbErrKinds = func() map[int]DBErrKind {
+	kinds := make(map[int]DBErrKind, len(dbErrCodes))
+	for kind, code := range dbErrCodes {
+		kinds[code] = kind
+	}
+	return kinds
+}()
+
+// ErrKind 错误的数据库错误类型，翻译后的(*errs.CodeErrs)按错误码识别，驱动错误直接解析
+func ErrKind(err error) DBErrKind {
+	if err == nil {
+		return DBErrUnknown
+	}
+	var cErr *errs.CodeErrs
+	if errors.As(err, &cErr) {
+		if cErr == nil {
+			return DBErrUnknown
+		} else if kind, ok := dbErrKinds[cErr.Code()]; ok {
+			return kind
+		}
+	}
+	if dbErr := ParseDBErr(err); dbErr != nil {
+		return dbErr.Kind
+	}
+	return DBErrUnknown
+}
+
+// TranslateErr 数据库错误转成带错误码/国际化的错误 (pgx/MySQL/SQLite)，其他的按原来的匹配
+func TranslateErr(err error) *errs.CodeErrs {
+	if err == nil {
+		return nil
+	}
+	dbErr := ParseDBErr(err)
+	if dbErr == nil {
+		return errs.Match(err).Real()
+	}
+	cErr := errs.Match2(dbErrIDs[dbErr.Kind]).WrapErrs(dbErr)
+	if len(dbErr.Field) > 0 {
+		cErr = cErr.WrapLocalize(msg.ErrIdDBField, []any{dbErr.Field}, nil)
+	}
+	return cErr.Real()
+}
+
+// ParseDBErr 解析驱动错误，不认识的返回nil
+func ParseDBErr(err error) *DBError {
+	return storage.ParseDBErr(err)
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const (
+	SortAsc  = "asc"  // 升序
+	SortDesc = "desc" // 降序
+)
+
+type (
+	// Filter 查询条件 (列名由代码给，不能直接用请求参数)，默认排除软删除的
+	Filter struct {
+		conds   []filterCond
+		deleted bool // 包含软删除的
+	}
+
+	filterCond struct {
+		query string
+		args  []any
+	}
+
+	// Sort 排序 (字段是请求里的字段名，仓储按白名单转成列)
+	Sort struct {
+		Field string `json:"field"` // 字段 (驼峰)
+		Order string `json:"order"` // asc/desc
+	}
+
+	// List 列表信封
+	// 页码分页有total/page，游标分页没有total (不统计)，有cursor/more
+	List[T any] struct {
+		List     []*T   `json:"list"`             // 数据
+		Total    int64  `json:"total"`            // 总数 (游标分页为0)
+		Page     int    `json:"page,omitempty"`   // 页码 (游标分页没有)
+		PageSize int    `json:"pageSize"`         // 每页数量
+		Cursor   uint64 `json:"cursor,omitempty"` // 下一页游标 (最后一条的ID)
+		More     bool   `json:"more"`             // 还有没有下一页
+	}
+)
+
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// Eq 等于
+func (f *Filter) Eq(column string, value any) *Filter {
+	return f.Where(column+" = ?", value)
+}
+
+// Ne 不等于
+func (f *Filter) Ne(column string, value any) *Filter {
+	return f.Where(column+" <> ?", value)
+}
+
+// In 在列表里，空列表不加条件
+func (f *Filter) In(column string, values any) *Filter {
+	if v := reflect.ValueOf(values); (v.Kind() == reflect.Slice) && (v.Len() <= 0) {
+		return f
+	}
+	return f.Where(column+" IN ?", values)
+}
+
+// Gte 大于等于，零值不加条件 (时间范围之类的)
+func (f *Filter) Gte(column string, value int64) *Filter {
+	if value <= 0 {
+		return f
+	}
+	return f.Where(column+" >= ?", value)
+}
+
+// Lt 小于，零值不加条件 (时间范围之类的)
+func (f *Filter) Lt(column string, value int64) *Filter {
+	if value <= 0 {
+		return f
+	}
+	return f.Where(column+" < ?", value)
+}
+
+// Like 模糊匹配 (包含)，空字符串不加条件
+func (f *Filter) Like(column string, keyword string) *Filter {
+	if len(keyword) <= 0 {
+		return f
+	}
+	keyword = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(keyword)
+	return f.Where(column+" LIKE ?", "%"+keyword+"%")
+}
+
+// Where 自定义条件
+func (f *Filter) Where(query string, args ...any) *Filter {
+	f.conds = append(f.conds, filterCond{query: query, args: args})
+	return f
+}
+
+// WithDeleted 包含软删除的
+func (f *Filter) WithDeleted() *Filter {
+	f.deleted = true
+	return f
+}
+
+// Apply 查询条件 (gorm scope)，nil只排除软删除的
+func (f *Filter) Apply(db *gorm.DB) *gorm.DB {
+	if (f == nil) || !f.deleted {
+		db = db.Where("delete_at IS NULL")
+	}
+	if f == nil {
+		return db
+	}
+	for _, cond := range f.conds {
+		db = db.Where(cond.query, cond.args...)
+	}
+	return db
+}
+
+func NewSort(field, order string) *Sort {
+	return &Sort{Field: field, Order: order}
+}
+
+// orderBy 排序子句，字段不在白名单里的返回false
+func (s *Sort) orderBy(fields []string) (clause.OrderByColumn, bool) {
+	if (s == nil) || !containsField(fields, s.Field) {
+		return clause.OrderByColumn{}, false
+	}
+	return clause.OrderByColumn{
+		Column: clause.Column{Name: columnName(s.Field)},
+		Desc:   s.Order == SortDesc,
+	}, true
+}
+
+func containsField(fields []string, field string) bool {
+	for _, v := range fields {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
+
+// columnName 字段名转列名 (createAt -> create_at)
+func columnName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
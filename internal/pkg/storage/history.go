@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+)
+
+type (
+	// Actor 操作者 (状态变更历史/数据范围用)，放在上下文里给仓储
+	Actor struct {
+		Type   uint8  // 操作者类型 (service.ActorType)
+		ID     uint64 // 操作者ID
+		Reason string // 操作原因
+
+		Scope func(resource string) *Scope // 操作者对资源的数据范围 (service.Ctx.DataScope)，nil是没有操作者
+	}
+
+	// IStatusHistory 有状态变更的实体 (model.Base都有)
+	IStatusHistory interface {
+		GetID() uint64
+		StatusChanges() []*model.StatusChange
+		ClearStatusChanges()
+	}
+
+	actorKey struct{}
+)
+
+// WithActor 把操作者放进上下文，用这个上下文的仓储记录状态变更时带上
+func WithActor(ctx context.Context, actor *Actor) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom 上下文里的操作者，没有返回空的 (未知操作者)
+func ActorFrom(ctx context.Context) *Actor {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey{}).(*Actor); ok && (actor != nil) {
+			return actor
+		}
+	}
+	return &Actor{}
+}
+
+// InsertHistory 添加 (Create)，实体有状态变更的 (例如添加前就激活了)，变更历史和添加在一个事务里写入
+func (b *Base) InsertHistory(table TableName, bean any) *errs.CodeErrs {
+	history, ok := bean.(IStatusHistory)
+	if !ok || (len(history.StatusChanges()) <= 0) {
+		return b.insert(table, bean)
+	}
+	var cErr *errs.CodeErrs
+	err := Transaction(b.Context(), func(ctx context.Context) error {
+		tx := b.WithContext(ctx)
+		if cErr = tx.insert(table, bean); cErr != nil {
+			return cErr
+		} else if cErr = tx.saveStatusHistory(table, history.GetID(), history.StatusChanges()); cErr != nil {
+			return cErr
+		}
+		return nil
+	})
+	if cErr != nil {
+		return cErr
+	} else if err != nil {
+		return TranslateErr(err)
+	}
+	history.ClearStatusChanges()
+	return nil
+}
+
+func (b *Base) insert(table TableName, bean any) *errs.CodeErrs {
+	result := b.Psql().Table(string(table)).Create(bean)
+	if result.Error != nil {
+		log.Error("DB_添加", log.FString("table", string(table)), log.FError(result.Error))
+		return TranslateErr(result.Error)
+	}
+	return nil
+}
+
+// saveStatusHistory 写入状态变更历史 (和实体的修改在同一个事务里)
+func (b *Base) saveStatusHistory(table TableName, id uint64, changes []*model.StatusChange) *errs.CodeErrs {
+	if len(changes) <= 0 {
+		return nil
+	}
+	actor := ActorFrom(b.Context())
+	beans := make([]*model.StatusHistory, 0, len(changes))
+	for _, change := range changes {
+		beans = append(beans, model.NewStatusHistory(string(table), id, change, actor.Type, actor.ID, actor.Reason))
+	}
+	result := b.Psql().Table(string(TableAuthStatusHistory)).Create(beans)
+	if result.Error != nil {
+		log.Error("DB_添加状态历史", log.FString("table", string(table)), log.FUint64("id", id), log.FError(result.Error))
+		return TranslateErr(result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"katydid-mp-user/internal/pkg/model"
+	"katydid-mp-user/pkg/data"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"time"
+)
+
+type (
+	// Limits 限制覆盖项仓储
+	Limits struct {
+		*Base
+	}
+)
+
+func NewLimits() *Limits {
+	return &Limits{
+		Base: NewBase(nil),
+	}
+}
+
+// Select 查询拥有者的覆盖项，没有返回nil
+func (sto *Limits) Select(ownKind int16, ownID uint64) (*model.Limits, *errs.CodeErrs) {
+	bean := model.NewLimitsEmpty()
+	result := sto.Psql().Table(string(TableClientLimits)).
+		Where("own_kind = ? AND own_id = ?", ownKind, ownID).
+		Where("delete_at IS NULL").
+		First(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		log.Error("DB_查询限制", log.FError(result.Error))
+		return nil, TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// Upsert 保存拥有者的覆盖项 (整体替换)
+// 冲突时的赋值不走字段的serializer，content要先转成json
+func (sto *Limits) Upsert(ownKind int16, ownID uint64, content data.KSMap) *errs.CodeErrs {
+	bytes, err := json.Marshal(content)
+	if err != nil {
+		return errs.Match(err).Real()
+	}
+	return sto.upsert(model.NewLimits(ownKind, ownID, content), map[string]any{
+		"content":   string(bytes),
+		"update_at": time.Now().UnixMilli(),
+	})
+}
+
+// UpsertParent 保存拥有者的上级
+func (sto *Limits) UpsertParent(ownKind int16, ownID uint64, parentKind int16, parentID uint64) *errs.CodeErrs {
+	bean := model.NewLimits(ownKind, ownID, data.KSMap{})
+	bean.ParentKind, bean.ParentID = parentKind, parentID
+	return sto.upsert(bean, map[string]any{
+		"parent_kind": parentKind,
+		"parent_id":   parentID,
+		"update_at":   time.Now().UnixMilli(),
+	})
+}
+
+func (sto *Limits) upsert(bean *model.Limits, updates map[string]any) *errs.CodeErrs {
+	result := sto.Psql().Table(string(TableClientLimits)).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "own_kind"}, {Name: "own_id"}},
+			DoUpdates: clause.Assignments(updates),
+		}).
+		Create(bean)
+	if result.Error != nil {
+		log.Error("DB_保存限制", log.FError(result.Error))
+		return TranslateErr(result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/storage"
+	"time"
+)
+
+// updateOmits 修改时不写的列 (创建时间不变，软删除只走Delete)
+var updateOmits = []string{"create_at", "delete_at", "delete_by"}
+
+// errRowsNone 修改/删除没有影响到行
+var errRowsNone = errors.New("no rows affected")
+
+type (
+	// Repo 通用仓储 (单表CRUD+分页+排序+软删除)，具体仓储嵌入后再加自己的查询
+	// 查询都带数据范围 (Scoped，按上下文里的操作者，没有操作者的要System)，默认排除软删除的 (delete_at IS NULL)
+	Repo[T any] struct {
+		*Base
+
+		table   TableName
+		name    string // 日志里的名称
+		newFn   func() *T
+		defSort *Sort    // 默认排序 (不在白名单也可以)
+		sorts   []string // 可排序的字段白名单 (驼峰)
+
+		versioned bool // 修改时检查版本号 (乐观锁)
+	}
+)
+
+// NewRepo 创建通用仓储，defSort为nil时按id倒序
+func NewRepo[T any](table TableName, name string, newFn func() *T, defSort *Sort, sorts ...string) *Repo[T] {
+	if defSort == nil {
+		defSort = NewSort("id", SortDesc)
+	}
+	return &Repo[T]{
+		Base:    NewBase(nil),
+		table:   table,
+		name:    name,
+		newFn:   newFn,
+		defSort: defSort,
+		sorts:   sorts,
+	}
+}
+
+// WithContext 绑定上下文的仓储 (上下文里有事务的走事务)
+func (r *Repo[T]) WithContext(ctx context.Context) *Repo[T] {
+	repo := *r
+	repo.Base = r.Base.WithContext(ctx)
+	return &repo
+}
+
+// WithScope 带数据范围的仓储
+func (r *Repo[T]) WithScope(scope *Scope) *Repo[T] {
+	repo := *r
+	repo.Base = r.Base.WithScope(scope)
+	return &repo
+}
+
+// System 系统访问的仓储 (上下文里没有操作者时不限制数据范围)，见 Base.System
+func (r *Repo[T]) System() *Repo[T] {
+	repo := *r
+	repo.Base = r.Base.System()
+	return &repo
+}
+
+// Versioned 开启乐观锁，Update时检查版本号 (表里要有version列)
+func (r *Repo[T]) Versioned() *Repo[T] {
+	repo := *r
+	repo.versioned = true
+	return &repo
+}
+
+// Insert 添加，有状态变更的同时写状态历史
+func (r *Repo[T]) Insert(bean *T) *errs.CodeErrs {
+	return r.InsertHistory(r.table, bean)
+}
+
+// Update 修改 (按主键，带数据范围，范围外的/已删除的改不到，返回没有改到的错误)，开启了乐观锁的版本不对返回冲突错误
+// 创建时间和软删除的列不改 (删除走Delete)
+func (r *Repo[T]) Update(bean *T) *errs.CodeErrs {
+	if v, ok := any(bean).(IVersion); r.versioned && ok {
+		return r.UpdateVersion(r.table, v)
+	}
+	// 不用Save，没改到时Save会变成插入
+	result := r.Scoped(r.table).
+		Select("*").Omit(updateOmits...).
+		Where("delete_at IS NULL").
+		Updates(bean)
+	if result.Error != nil {
+		log.Error("DB_修改"+r.name, log.FError(result.Error))
+		return TranslateErr(result.Error)
+	} else if result.RowsAffected <= 0 {
+		log.Warn("DB_修改"+r.name+"_没有改到", log.FString("table", string(r.table))) // 不存在/已删除/不在数据范围里
+		return TranslateErr(storage.NewDBError(DBErrNone, "", errRowsNone))
+	}
+	return nil
+}
+
+// Delete 软删除 (Base.DeleteAt/DeleteBy)，deleteBy见 model.Base.GetDelBy
+func (r *Repo[T]) Delete(id uint64, deleteBy int64) *errs.CodeErrs {
+	return r.DeleteWith(id, deleteBy, nil)
+}
+
+// DeleteWith 软删除，同时修改其他列 (例如状态)
+func (r *Repo[T]) DeleteWith(id uint64, deleteBy int64, values map[string]any) *errs.CodeErrs {
+	updates := map[string]any{
+		"delete_at": time.Now().UnixMilli(),
+		"delete_by": deleteBy,
+	}
+	for k, v := range values {
+		updates[k] = v
+	}
+	result := r.Scoped(r.table).
+		Where("id = ? AND delete_at IS NULL", id).
+		Updates(updates)
+	if result.Error != nil {
+		log.Error("DB_删除"+r.name, log.FUint64("id", id), log.FError(result.Error))
+		return TranslateErr(result.Error)
+	} else if result.RowsAffected <= 0 {
+		log.Warn("DB_删除"+r.name+"_没有删到", log.FUint64("id", id)) // 不存在/已删除/不在数据范围里
+		return TranslateErr(storage.NewDBError(DBErrNone, "", errRowsNone))
+	}
+	return nil
+}
+
+// SelectByID 根据ID查询 (不含删除的)，没有返回nil
+func (r *Repo[T]) SelectByID(id uint64) (*T, *errs.CodeErrs) {
+	return r.Select(NewFilter().Eq("id", id))
+}
+
+// Select 查询一条，没有返回nil
+func (r *Repo[T]) Select(filter *Filter) (*T, *errs.CodeErrs) {
+	bean := r.newFn()
+	result := r.Scoped(r.table).Scopes(filter.Apply).Take(bean)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if result.Error != nil {
+		return nil, TranslateErr(result.Error)
+	}
+	return bean, nil
+}
+
+// Selects 查询列表，limit<=0不限制
+func (r *Repo[T]) Selects(filter *Filter, sort *Sort, limit int) ([]*T, *errs.CodeErrs) {
+	beans := make([]*T, 0)
+	db := r.Scoped(r.table).Scopes(filter.Apply).Order(r.orderBy(sort))
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	result := db.Find(&beans)
+	if result.Error != nil {
+		return nil, TranslateErr(result.Error)
+	}
+	return beans, nil
+}
+
+// SelectCount 查询数量
+func (r *Repo[T]) SelectCount(filter *Filter) (int64, *errs.CodeErrs) {
+	var count int64
+	result := r.Scoped(r.table).Scopes(filter.Apply).Count(&count)
+	if result.Error != nil {
+		return 0, TranslateErr(result.Error)
+	}
+	return count, nil
+}
+
+// SelectsPage 页码分页 (page从1开始，size的上限由调用方控制)
+func (r *Repo[T]) SelectsPage(filter *Filter, sort *Sort, page, size int) (*List[T], *errs.CodeErrs) {
+	page, size = max(page, 1), max(size, 1)
+	list := &List[T]{List: make([]*T, 0), Page: page, PageSize: size}
+	count, err := r.SelectCount(filter)
+	if err != nil {
+		return nil, err
+	} else if count <= 0 {
+		return list, nil
+	}
+	result := r.Scoped(r.table).Scopes(filter.Apply).
+		Order(r.orderBy(sort)).
+		Offset((page - 1) * size).Limit(size).
+		Find(&list.List)
+	if result.Error != nil {
+		return nil, TranslateErr(result.Error)
+	}
+	list.Total = count
+	list.More = int64(page*size) < count
+	return list, nil
+}
+
+// SelectsCursor 游标分页 (按id倒序，cursor是上一页最后一条的id，0是第一页)
+// 不统计总数，适合翻得深/一直在写入的表 (导出之类的批量读取)
+func (r *Repo[T]) SelectsCursor(filter *Filter, cursor uint64, size int) (*List[T], *errs.CodeErrs) {
+	size = max(size, 1)
+	list := &List[T]{List: make([]*T, 0), PageSize: size}
+	db := r.Scoped(r.table).Scopes(filter.Apply)
+	if cursor > 0 {
+		db = db.Where("id < ?", cursor)
+	}
+	var ids []uint64
+	result := db.Order("id DESC").Limit(size+1).Pluck("id", &ids)
+	if result.Error != nil {
+		return nil, TranslateErr(result.Error)
+	} else if len(ids) <= 0 {
+		return list, nil
+	}
+	if len(ids) > size {
+		ids, list.More = ids[:size], true
+	}
+	result = r.Scoped(r.table).Where("id IN ?", ids).Order("id DESC").Find(&list.List)
+	if result.Error != nil {
+		return nil, TranslateErr(result.Error)
+	}
+	if list.More {
+		list.Cursor = ids[len(ids)-1]
+	}
+	return list, nil
+}
+
+// orderBy 排序 (不在白名单里的用默认排序)，最后按id保证翻页稳定
+func (r *Repo[T]) orderBy(sort *Sort) clause.OrderBy {
+	columns := make([]clause.OrderByColumn, 0, 2)
+	if column, ok := sort.orderBy(r.sorts); ok {
+		columns = append(columns, column)
+	} else if column, ok = r.defSort.orderBy([]string{r.defSort.Field}); ok {
+		columns = append(columns, column)
+	}
+	if len(columns) <= 0 {
+		return clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "id"}, Desc: true}}}
+	} else if columns[0].Column.Name != "id" {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: columns[0].Desc})
+	}
+	return clause.OrderBy{Columns: columns}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"gorm.io/gorm"
+	"katydid-mp-user/pkg/log"
+)
+
+// DataScope 数据范围 (行级数据权限)
+type DataScope int8
+
+const (
+	DataScopeNone DataScope = iota // 没有数据权限 (查不到，也改不了)
+	DataScopeSelf                  // 自己的数据
+	DataScopeOwn                   // 所属own(应用/组织)里的数据
+	DataScopeAll                   // 所有数据
+)
+
+// 数据范围资源 (策略里的 资源:all/own/self)
+const (
+	DataResAccount = "data/auth/account" // 账号 (令牌/导出/昵称跟着账号)
+	DataResAccess  = "data/auth/access"  // 访问记录
+)
+
+var dataScopeActs = map[DataScope]string{
+	DataScopeNone: "none",
+	DataScopeSelf: "self",
+	DataScopeOwn:  "own",
+	DataScopeAll:  "all",
+}
+
+// String 数据范围在策略里的动作名 (资源:动作，例如 data/auth/account:own)
+func (d DataScope) String() string {
+	return dataScopeActs[d]
+}
+
+type (
+	// Scope 操作者的数据范围，仓储查询时自动加上条件
+	Scope struct {
+		Kind    DataScope // 数据范围
+		ActorID uint64    // 操作者ID (账号)
+		OwnKind int16     // 操作者所属own类型
+		OwnID   uint64    // 操作者所属own
+	}
+
+	// ScopeColumns 表里数据范围对应的列 (没有的列为空，对应的范围查不到数据)
+	ScopeColumns struct {
+		Resource string // 数据范围资源 (按操作者取范围用)
+		Self     string // 操作者ID列
+		OwnKind  string // own类型列
+		OwnID    string // own列
+	}
+)
+
+// scopeColumns 有数据范围的表，Scoped时自动按上下文里的操作者加条件 (没注册的表不限制，指定了范围的除了DataScopeAll都查不到)
+var scopeColumns = map[TableName]ScopeColumns{
+	TableAuthAccount:  {Resource: DataResAccount, Self: "id", OwnKind: "own_kind", OwnID: "own_id"},
+	TableAuthToken:    {Resource: DataResAccount, Self: "account_id", OwnKind: "own_kind", OwnID: "own_id"},
+	TableAuthAccess:   {Resource: DataResAccess, Self: "account_id", OwnKind: "own_kind", OwnID: "own_id"},
+	TableAuthExport:   {Resource: DataResAccount, Self: "account_id", OwnKind: "own_kind", OwnID: "own_id"},
+	TableAuthNickname: {Resource: DataResAccount, Self: "account_id", OwnKind: "own_kind", OwnID: "own_id"},
+}
+
+func NewScope(kind DataScope, actorID uint64, ownKind int16, ownID uint64) *Scope {
+	return &Scope{Kind: kind, ActorID: actorID, OwnKind: ownKind, OwnID: ownID}
+}
+
+// Apply 表的数据范围条件 (gorm scope)，nil是不限制 (没有数据范围的表/System)
+func (s *Scope) Apply(table TableName) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if (s == nil) || (s.Kind == DataScopeAll) {
+			return db
+		}
+		columns, ok := scopeColumns[table]
+		if !ok {
+			log.Warn("DB_数据范围", log.FString("table", string(table)), log.FString("scope", s.Kind.String()))
+			return db.Where("1 = 0")
+		}
+		switch s.Kind {
+		case DataScopeSelf:
+			if len(columns.Self) > 0 {
+				return db.Where(columns.Self+" = ?", s.ActorID)
+			}
+		case DataScopeOwn:
+			if (len(columns.OwnKind) > 0) && (len(columns.OwnID) > 0) {
+				return db.Where(columns.OwnKind+" = ? AND "+columns.OwnID+" = ?", s.OwnKind, s.OwnID)
+			}
+		}
+		return db.Where("1 = 0")
+	}
+}
+
+// Allow 单条数据在不在范围里 (查出来的/要写入的再校验一次)，nil是不限制 (同Apply)
+func (s *Scope) Allow(ownKind int16, ownID uint64, selfID uint64) bool {
+	if s == nil {
+		return true
+	}
+	switch s.Kind {
+	case DataScopeAll:
+		return true
+	case DataScopeOwn:
+		return (ownKind == s.OwnKind) && (ownID == s.OwnID)
+	case DataScopeSelf:
+		return (selfID > 0) && (selfID == s.ActorID)
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/storage"
+	"time"
+)
+
+const (
+	txRetryMax   = 3                     // 序列化失败/死锁时整个事务的重试次数 (只有最外层)
+	txRetryDelay = 20 * time.Millisecond // 重试间隔 (按次数递增)
+)
+
+type txKey struct{}
+
+// WithTx 把事务放进上下文，用这个上下文的仓储都走这个事务
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFrom 上下文里的事务，没有返回nil
+func TxFrom(ctx context.Context) *gorm.DB {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(txKey{}).(*gorm.DB)
+	return tx
+}
+
+// Transaction 在事务里执行fn，fn里的仓储要用传进去的ctx (WithContext)
+// ctx里已经有事务的，嵌套成savepoint (失败只回滚自己)，不重试 (错误交给最外层)；
+// 最外层的遇到序列化失败/死锁 (40001/40P01)，整个事务有限次重试，所以fn要能重复执行 (不要在fn里做事务外的副作用)
+func Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if tx := TxFrom(ctx); tx != nil {
+		return tx.Transaction(func(sub *gorm.DB) error {
+			return fn(WithTx(ctx, sub))
+		})
+	}
+
+	db := storage.GetDB(DefaultPsqlName)
+	if db == nil {
+		return gorm.ErrInvalidDB
+	}
+	db = db.WithContext(ctx)
+	var err error
+	for i := 0; i <= txRetryMax; i++ {
+		if i > 0 {
+			log.Warn("DB_事务重试", log.FInt("times", i), log.FError(err))
+			time.Sleep(time.Duration(i) * txRetryDelay)
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return fn(WithTx(ctx, tx))
+		}, opts...)
+		if !IsRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// IsRetryable 是不是可以重试的事务错误 (序列化失败/死锁)，翻译过的也能识别
+func IsRetryable(err error) bool {
+	return ErrKind(err) == DBErrDeadlock
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"katydid-mp-user/pkg/errs"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/storage"
+)
+
+// IVersion 有版本号的实体 (乐观锁，model.Base都有)
+type IVersion interface {
+	GetVersion() int64
+	SetVersion(int64)
+}
+
+var errVersionConflict = errors.New("version conflict")
+
+// UpdateVersion 带版本号的修改 (WHERE version = ?，带数据范围)，成功后版本号+1
+// 没改到的 (被别人先改了/不存在/已删除/不在范围里) 返回版本冲突错误，实体的版本号不变
+// 实体有状态变更的，变更历史和修改在一个事务里写入 (失败的变更留着，重试时再写)
+func (b *Base) UpdateVersion(table TableName, bean IVersion) *errs.CodeErrs {
+	history, ok := bean.(IStatusHistory)
+	if !ok {
+		return b.updateVersion(table, bean)
+	}
+	changes := history.StatusChanges()
+	if len(changes) <= 0 {
+		return b.updateVersion(table, bean)
+	}
+	version := bean.GetVersion()
+	var cErr *errs.CodeErrs
+	err := Transaction(b.Context(), func(ctx context.Context) error {
+		tx := b.WithContext(ctx)
+		if cErr = tx.updateVersion(table, bean); cErr != nil {
+			return cErr
+		} else if cErr = tx.saveStatusHistory(table, history.GetID(), changes); cErr != nil {
+			return cErr
+		}
+		return nil
+	})
+	if (cErr != nil) || (err != nil) {
+		bean.SetVersion(version) // 事务回滚了
+	}
+	if cErr != nil {
+		return cErr
+	} else if err != nil {
+		return TranslateErr(err)
+	}
+	history.ClearStatusChanges()
+	return nil
+}
+
+func (b *Base) updateVersion(table TableName, bean IVersion) *errs.CodeErrs {
+	version := bean.GetVersion()
+	bean.SetVersion(version + 1)
+	result := b.Scoped(table).
+		Select("*").Omit(updateOmits...).
+		Where("version = ? AND delete_at IS NULL", version).
+		Updates(bean)
+	if result.Error != nil {
+		bean.SetVersion(version)
+		log.Error("DB_版本修改", log.FString("table", string(table)), log.FInt64("version", version), log.FError(result.Error))
+		return TranslateErr(result.Error)
+	} else if result.RowsAffected <= 0 {
+		bean.SetVersion(version)
+		log.Warn("DB_版本冲突", log.FString("table", string(table)), log.FInt64("version", version))
+		return TranslateErr(storage.NewDBError(DBErrVersion, "", errVersionConflict))
+	}
+	return nil
+}
+
+// IsVersionConflict 是不是版本冲突 (乐观锁)，按错误码识别
+func IsVersionConflict(err *errs.CodeErrs) bool {
+	if err == nil {
+		return false
+	}
+	return ErrKind(err) == DBErrVersion
+}
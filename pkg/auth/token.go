@@ -44,7 +44,10 @@ type (
 		OwnID     uint64  `json:"ownId,omitempty"`     // 令牌拥有者ID
 		AccountID uint64  `json:"accountId,omitempty"` // 账号ID
 		UserID    *uint64 `json:"userId,omitempty"`    // 用户ID
-		// TODO:GG roles (记得加到middleware里)
+
+		Roles   []string `json:"roles,omitempty"`   // 角色 (签发时解析)
+		Scopes  []string `json:"scopes,omitempty"`  // 粗粒度权限 "资源:动作" (可选)
+		RoleVer int64    `json:"roleVer,omitempty"` // 角色版本 (角色变更后旧的token要刷新)
 
 		jwt.RegisteredClaims `json:"-"` // 注册声明(不序列化)
 	}
@@ -97,6 +100,14 @@ func newTokenClaims(
 	}
 }
 
+// WithRoles 设置角色/权限/角色版本 (生成JWT之前)
+func (t *Token) WithRoles(roles, scopes []string, roleVer int64) *Token {
+	t.Claims.Roles = roles
+	t.Claims.Scopes = scopes
+	t.Claims.RoleVer = roleVer
+	return t
+}
+
 // GenerateJWTTokens 生成访问令牌，可选保留原令牌ID
 // oldToken为nil时生成新令牌ID，不为nil时尝试保留原令牌ID
 func (t *Token) GenerateJWTTokens(secret string, oldToken *string) error {
@@ -119,6 +130,9 @@ func (t *Token) generateJWTToken(secret string, tokenID *string) error {
 		t.Claims.AccountID, t.Claims.UserID,
 		t.Claims.Issuer, t.ExpireSec,
 	)
+	claims.Roles = t.Claims.Roles
+	claims.Scopes = t.Claims.Scopes
+	claims.RoleVer = t.Claims.RoleVer
 
 	// 设置令牌ID
 	if tokenID != nil && *tokenID != "" {
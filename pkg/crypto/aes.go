@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+var (
+	ErrKeyEmpty      = errors.New("crypto: key is empty")
+	ErrCipherInvalid = errors.New("crypto: cipher text is invalid")
+)
+
+// AESGCM AES-256-GCM 加解密 (密文 = nonce + 密文 + tag)
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM 用32字节的key创建 (16/24字节则是AES-128/192)
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) <= 0 {
+		return nil, ErrKeyEmpty
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// NewAESGCMFromSecret 从配置的密钥派生32字节key (purpose区分用途，同一密钥不同用途的key不同)
+func NewAESGCMFromSecret(secret string, purpose string) (*AESGCM, error) {
+	if len(secret) <= 0 {
+		return nil, ErrKeyEmpty
+	}
+	return NewAESGCM(DeriveKey(secret, purpose))
+}
+
+// Encrypt 加密，每次随机nonce，aad是附加认证数据 (不加密，但解密时要一致，可以绑定用户ID等)
+func (a *AESGCM) Encrypt(plain []byte, aad []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+// Decrypt 解密 (密文被改过/aad不一致都会失败)
+func (a *AESGCM) Decrypt(data []byte, aad []byte) ([]byte, error) {
+	size := a.aead.NonceSize()
+	if len(data) < size+a.aead.Overhead() {
+		return nil, ErrCipherInvalid
+	}
+	return a.aead.Open(nil, data[:size], data[size:], aad)
+}
+
+// EncryptString 加密成base64 (方便存db)
+func (a *AESGCM) EncryptString(plain string, aad string) (string, error) {
+	data, err := a.Encrypt([]byte(plain), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// DecryptString 解密base64
+func (a *AESGCM) DecryptString(text string, aad string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(text)
+	if err != nil {
+		return "", ErrCipherInvalid
+	}
+	plain, err := a.Decrypt(data, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// DeriveKey 从密钥派生32字节key (HMAC-SHA256(secret, purpose))
+func DeriveKey(secret string, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// BlindIndex 敏感数据的盲索引 (HMAC-SHA256 hex)，可以查重/等值查询，但不能反推原文
+func BlindIndex(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
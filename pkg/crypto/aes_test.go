@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAESGCM(t *testing.T) {
+	a, err := NewAESGCMFromSecret("secret", "idcard")
+	if err != nil {
+		t.Fatalf("NewAESGCMFromSecret: %v", err)
+	}
+	other, _ := NewAESGCMFromSecret("secret", "phone")
+
+	tests := []struct {
+		name    string
+		plain   string
+		aad     string
+		dec     *AESGCM // 解密用的
+		decAad  string
+		tamper  bool // 改一个字节
+		wantErr bool
+	}{
+		{"正常", "110105194912310021", "1", a, "1", false, false},
+		{"空明文", "", "", a, "", false, false},
+		{"aad不一致", "110105194912310021", "1", a, "2", false, true},
+		{"不同用途的key", "110105194912310021", "1", other, "1", false, true},
+		{"密文被改", "110105194912310021", "1", a, "1", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := a.Encrypt([]byte(tt.plain), []byte(tt.aad))
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if tt.tamper {
+				data[len(data)-1] ^= 0xff
+			}
+			plain, err := tt.dec.Decrypt(data, []byte(tt.decAad))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt err = %v, wantErr %v", err, tt.wantErr)
+			} else if (err == nil) && (string(plain) != tt.plain) {
+				t.Errorf("Decrypt = %q, want %q", plain, tt.plain)
+			}
+		})
+	}
+}
+
+func TestAESGCMString(t *testing.T) {
+	a, _ := NewAESGCM(DeriveKey("secret", "test"))
+	text1, err := a.EncryptString("hello", "aad")
+	if err != nil {
+		t.Fatalf("EncryptString: %v", err)
+	}
+	text2, _ := a.EncryptString("hello", "aad")
+	if text1 == text2 {
+		t.Errorf("同样的明文密文不能一样 (随机nonce)")
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr error
+	}{
+		{"正常", text1, "hello", nil},
+		{"不是base64", "!!!", "", ErrCipherInvalid},
+		{"太短", "AAAA", "", ErrCipherInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.DecryptString(tt.text, "aad")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecryptString err = %v, want %v", err, tt.wantErr)
+			} else if got != tt.want {
+				t.Errorf("DecryptString = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr error
+	}{
+		{"空key", nil, ErrKeyEmpty},
+		{"AES-128", bytes.Repeat([]byte{1}, 16), nil},
+		{"AES-256", DeriveKey("secret", "x"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAESGCM(tt.key); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewAESGCM err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewAESGCM([]byte("short")); err == nil {
+		t.Errorf("NewAESGCM 长度不对应该失败")
+	}
+	if bytes.Equal(DeriveKey("secret", "a"), DeriveKey("secret", "b")) {
+		t.Errorf("不同用途的key不能一样")
+	}
+	if BlindIndex([]byte("k"), "v") != BlindIndex([]byte("k"), "v") {
+		t.Errorf("盲索引要稳定")
+	}
+}
@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordEmpty = errors.New("crypto: password is empty")
+
+// PasswordCost bcrypt的计算成本 (每+1慢一倍)
+const PasswordCost = 12
+
+// HashPassword 密码哈希 (bcrypt，自带随机盐，结果60字节)，密码超过72字节的报错
+func HashPassword(password string) (string, error) {
+	if len(password) <= 0 {
+		return "", ErrPasswordEmpty
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验密码和哈希 (bcrypt内部是常量时间比较)
+func CheckPassword(hash string, password string) bool {
+	if (len(hash) <= 0) || (len(password) <= 0) {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,38 @@
+package crypto
+
+import "testing"
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("e10adc3949ba59abbe56e057f20f883e")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	other, _ := HashPassword("e10adc3949ba59abbe56e057f20f883e")
+	if hash == other {
+		t.Errorf("同一个密码的哈希应该不同 (随机盐)")
+	}
+	if _, err = HashPassword(""); err == nil {
+		t.Errorf("空密码应该报错")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"正确", hash, "e10adc3949ba59abbe56e057f20f883e", true},
+		{"另一个哈希", other, "e10adc3949ba59abbe56e057f20f883e", true},
+		{"错误", hash, "e10adc3949ba59abbe56e057f20f883f", false},
+		{"空密码", hash, "", false},
+		{"空哈希", "", "e10adc3949ba59abbe56e057f20f883e", false},
+		{"不是bcrypt", "e10adc3949ba59abbe56e057f20f883e", "e10adc3949ba59abbe56e057f20f883e", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("CheckPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
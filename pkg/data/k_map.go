@@ -1102,3 +1102,15 @@ func (m KSMap) Keys() []string {
 	}
 	return keys
 }
+
+// Clone 浅拷贝
+func (m KSMap) Clone() KSMap {
+	if m == nil {
+		return nil
+	}
+	clone := make(KSMap, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
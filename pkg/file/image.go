@@ -0,0 +1,227 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册gif解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	MimeJPEG = "image/jpeg"
+	MimePNG  = "image/png"
+	MimeGIF  = "image/gif"
+
+	imageMaxPixels = 40 * 1000 * 1000 // 解码前检查像素数，防解压炸弹
+)
+
+// SniffImage 根据内容判断图片类型 (不信任扩展名/Content-Type)，只支持jpeg/png/gif
+func SniffImage(head []byte) (string, bool) {
+	mime := http.DetectContentType(head)
+	switch mime {
+	case MimeJPEG, MimePNG, MimeGIF:
+		return mime, true
+	}
+	return mime, false
+}
+
+// DecodeImage 解码图片 (gif只取第一帧)，先检查尺寸
+func DecodeImage(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, format, err
+	} else if (config.Width <= 0) || (config.Height <= 0) {
+		return nil, format, errors.New("image size is empty")
+	} else if config.Width*config.Height > imageMaxPixels {
+		return nil, format, errors.New("image size is too large")
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	return img, format, err
+}
+
+// EncodeImage 重新编码 (会丢掉EXIF等元数据)，有透明通道的用png，其他用jpeg
+func EncodeImage(w io.Writer, img image.Image, quality int) (string, error) {
+	if hasAlpha(img) {
+		return MimePNG, png.Encode(w, img)
+	}
+	return MimeJPEG, jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// CropSquare 居中裁剪成正方形
+func CropSquare(img image.Image) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x, y), draw.Src)
+	return dst
+}
+
+// ResizeImage 缩放到指定宽高 (缩小用区域平均，放大用最近邻)
+func ResizeImage(img image.Image, width, height int) image.Image {
+	src := toRGBA(img)
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if (sw == width) && (sh == height) {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+		return dst
+	}
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * sh / height
+		y1 := (dy + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * sw / width
+			x1 := (dx + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y++ {
+				offset := src.PixOffset(bounds.Min.X+x0, bounds.Min.Y+y)
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[offset])
+					g += uint32(src.Pix[offset+1])
+					b += uint32(src.Pix[offset+2])
+					a += uint32(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+func hasAlpha(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return false
+}
+
+// ImageOrientation jpeg的EXIF方向 (1~8)，没有/解析不了的返回1 (不用转)
+func ImageOrientation(data []byte) int {
+	if (len(data) < 4) || (data[0] != 0xFF) || (data[1] != 0xD8) {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if (marker == 0xDA) || (marker == 0xD9) {
+			return 1 // 图像数据开始了，后面没有APP段
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if (length < 2) || (i+2+length > len(data)) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if (marker == 0xE1) && (len(segment) > 6) && (string(segment[:6]) == "Exif\x00\x00") {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation 从TIFF头里找IFD0的Orientation(0x0112)
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:8]))
+	if (offset < 8) || (offset+2 > len(tiff)) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if (orientation < 1) || (orientation > 8) {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// OrientImage 按EXIF方向转正 (重新编码会丢掉EXIF，不转的话手机拍的会横过来)
+func OrientImage(img image.Image, orientation int) image.Image {
+	if (orientation <= 1) || (orientation > 8) {
+		return img
+	}
+	src := toRGBA(img)
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // 5~8 宽高互换
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转180
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针90
+				sx, sy = y, h-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针90
+				sx, sy = w-1-y, x
+			}
+			so := src.PixOffset(bounds.Min.X+sx, bounds.Min.Y+sy)
+			do := dst.PixOffset(x, y)
+			copy(dst.Pix[do:do+4], src.Pix[so:so+4])
+		}
+	}
+	return dst
+}
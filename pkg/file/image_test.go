@@ -0,0 +1,83 @@
+package file
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// jpegWithOrientation 只有SOI+APP1(Exif)的jpeg头
+func jpegWithOrientation(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 0x2A)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(segment) + 2) >> 8), byte(len(segment) + 2)}
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA)
+}
+
+func TestImageOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"小端", jpegWithOrientation(binary.LittleEndian, 6), 6},
+		{"大端", jpegWithOrientation(binary.BigEndian, 8), 8},
+		{"超出范围", jpegWithOrientation(binary.BigEndian, 9), 1},
+		{"不是jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"没有Exif", []byte{0xFF, 0xD8, 0xFF, 0xDA}, 1},
+		{"截断", jpegWithOrientation(binary.BigEndian, 6)[:12], 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ImageOrientation(tt.data); got != tt.want {
+				t.Errorf("ImageOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 2x1: 左红右蓝
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		first       color.RGBA // 左上角
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},  // 顺时针90，红在上
+		{8, 1, 2, blue}, // 逆时针90，蓝在上
+	}
+	for _, tt := range tests {
+		got := OrientImage(src, tt.orientation)
+		bounds := got.Bounds()
+		if (bounds.Dx() != tt.w) || (bounds.Dy() != tt.h) {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, bounds.Dx(), bounds.Dy(), tt.w, tt.h)
+			continue
+		}
+		if c := color.RGBAModel.Convert(got.At(bounds.Min.X, bounds.Min.Y)).(color.RGBA); c != tt.first {
+			t.Errorf("orientation %d: first = %v, want %v", tt.orientation, c, tt.first)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package fsm
+
+import (
+	"fmt"
+)
+
+type (
+	// Machine 状态机 (S状态，O对象)，启动时声明好状态/转换，之后只读 (并发安全)
+	Machine[S comparable, O any] struct {
+		name     string
+		get      func(O) S    // 取对象的状态
+		set      func(O, S)   // 改对象的状态
+		names    map[S]string // 状态名 (日志/错误用)
+		rules    map[S]map[S]*Rule[S, O]
+		onChange []Hook[S, O] // 所有转换成功后 (例如记录变更历史)
+	}
+
+	// Rule 转换规则 (事件 froms -> to)
+	Rule[S comparable, O any] struct {
+		Event  string
+		To     S
+		guards []Guard[O]
+		hooks  []Hook[S, O]
+	}
+
+	// Guard 转换条件，返回false不能转换
+	Guard[O any] func(obj O) bool
+
+	// Hook 转换成功后的副作用 (状态已经改好)
+	Hook[S comparable, O any] func(obj O, from, to S, event string)
+
+	// Error 不允许的转换
+	Error struct {
+		Machine string
+		Event   string // 指定了事件的 (FireEvent)
+		From    string
+		To      string
+		Guard   bool // 规则有，但条件不满足
+	}
+)
+
+func (e *Error) Error() string {
+	if e.Guard {
+		return fmt.Sprintf("fsm %s: guard rejected %s -> %s", e.Machine, e.From, e.To)
+	} else if len(e.Event) > 0 {
+		return fmt.Sprintf("fsm %s: event %s not allowed %s -> %s", e.Machine, e.Event, e.From, e.To)
+	}
+	return fmt.Sprintf("fsm %s: transition not allowed %s -> %s", e.Machine, e.From, e.To)
+}
+
+// New 创建状态机
+func New[S comparable, O any](name string, get func(O) S, set func(O, S)) *Machine[S, O] {
+	return &Machine[S, O]{
+		name:  name,
+		get:   get,
+		set:   set,
+		names: make(map[S]string),
+		rules: make(map[S]map[S]*Rule[S, O]),
+	}
+}
+
+// State 声明状态
+func (m *Machine[S, O]) State(state S, name string) *Machine[S, O] {
+	m.names[state] = name
+	return m
+}
+
+// Allow 声明转换 froms -> to，同一对from/to后声明的覆盖前面的
+func (m *Machine[S, O]) Allow(event string, to S, froms ...S) *Rule[S, O] {
+	rule := &Rule[S, O]{Event: event, To: to}
+	for _, from := range froms {
+		if m.rules[from] == nil {
+			m.rules[from] = make(map[S]*Rule[S, O])
+		}
+		m.rules[from][to] = rule
+	}
+	return rule
+}
+
+// OnChange 所有转换成功后的回调
+func (m *Machine[S, O]) OnChange(hook Hook[S, O]) *Machine[S, O] {
+	m.onChange = append(m.onChange, hook)
+	return m
+}
+
+// Guard 添加转换条件
+func (r *Rule[S, O]) Guard(guard Guard[O]) *Rule[S, O] {
+	r.guards = append(r.guards, guard)
+	return r
+}
+
+// Do 添加转换的副作用
+func (r *Rule[S, O]) Do(hook Hook[S, O]) *Rule[S, O] {
+	r.hooks = append(r.hooks, hook)
+	return r
+}
+
+// Name 状态名，没声明的用%v
+func (m *Machine[S, O]) Name(state S) string {
+	if name, ok := m.names[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("%v", state)
+}
+
+// Can 对象能不能转换到to (规则+条件)
+func (m *Machine[S, O]) Can(obj O, to S) bool {
+	_, err := m.rule(obj, "", to)
+	return err == nil
+}
+
+// CanEvent 对象能不能通过event转换到to (规则的事件也要一致)
+func (m *Machine[S, O]) CanEvent(obj O, event string, to S) bool {
+	_, err := m.rule(obj, event, to)
+	return err == nil
+}
+
+// Fire 转换到to，不允许的返回*Error，状态不变
+// 没有声明自己到自己的，原地转换什么都不做 (不算错误，也不触发回调)
+func (m *Machine[S, O]) Fire(obj O, to S) error {
+	return m.fire(obj, "", to)
+}
+
+// FireEvent 通过event转换到to，(from, to)的规则不是这个事件的也不允许
+// 同一对状态可能属于不同的业务 (例如 锁定->激活 是解锁，不是添加认证)
+func (m *Machine[S, O]) FireEvent(obj O, event string, to S) error {
+	return m.fire(obj, event, to)
+}
+
+func (m *Machine[S, O]) fire(obj O, event string, to S) error {
+	from := m.get(obj)
+	rule, err := m.rule(obj, event, to)
+	if err != nil {
+		if (from == to) && !err.Guard && (len(err.Event) <= 0) {
+			return nil
+		}
+		return err
+	}
+	m.set(obj, to)
+	for _, hook := range rule.hooks {
+		hook(obj, from, to, rule.Event)
+	}
+	for _, hook := range m.onChange {
+		hook(obj, from, to, rule.Event)
+	}
+	return nil
+}
+
+func (m *Machine[S, O]) rule(obj O, event string, to S) (*Rule[S, O], *Error) {
+	from := m.get(obj)
+	rule, ok := m.rules[from][to]
+	if !ok {
+		return nil, &Error{Machine: m.name, From: m.Name(from), To: m.Name(to)}
+	} else if (len(event) > 0) && (rule.Event != event) {
+		return nil, &Error{Machine: m.name, Event: event, From: m.Name(from), To: m.Name(to)}
+	}
+	for _, guard := range rule.guards {
+		if !guard(obj) {
+			return nil, &Error{Machine: m.name, From: m.Name(from), To: m.Name(to), Guard: true}
+		}
+	}
+	return rule, nil
+}
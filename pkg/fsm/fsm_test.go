@@ -0,0 +1,131 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	stInit   = 0
+	stActive = 1
+	stLocked = 2
+	stBanned = -1
+)
+
+type obj struct {
+	status  int
+	allowed bool     // guard
+	events  []string // OnChange记录的事件
+	hooks   int      // Do的次数
+}
+
+func newTestMachine() *Machine[int, *obj] {
+	m := New("test",
+		func(o *obj) int { return o.status },
+		func(o *obj, s int) { o.status = s },
+	).
+		State(stInit, "init").
+		State(stActive, "active").
+		State(stLocked, "locked").
+		State(stBanned, "banned").
+		OnChange(func(o *obj, _, _ int, event string) { o.events = append(o.events, event) })
+
+	m.Allow("activate", stActive, stInit)
+	m.Allow("lock", stLocked, stInit, stActive, stLocked).Do(func(o *obj, _, _ int, _ string) { o.hooks++ })
+	m.Allow("unlock", stActive, stLocked)
+	m.Allow("ban", stBanned, stInit, stActive, stLocked).Guard(func(o *obj) bool { return o.allowed })
+	return m
+}
+
+func TestFire(t *testing.T) {
+	m := newTestMachine()
+	tests := []struct {
+		name    string
+		from    int
+		to      int
+		allowed bool
+		wantTo  int
+		wantErr bool
+		guard   bool // 错误是条件不满足
+		events  int  // OnChange触发次数
+	}{
+		{"允许", stInit, stActive, false, stActive, false, false, 1},
+		{"不允许", stActive, stInit, false, stActive, true, false, 0},
+		{"原地没声明 不算错误", stActive, stActive, false, stActive, false, false, 0},
+		{"原地有声明 触发回调", stLocked, stLocked, false, stLocked, false, false, 1},
+		{"条件不满足", stActive, stBanned, false, stActive, true, true, 0},
+		{"条件满足", stActive, stBanned, true, stBanned, false, false, 1},
+		{"终态", stBanned, stActive, false, stBanned, true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &obj{status: tt.from, allowed: tt.allowed}
+			err := m.Fire(o, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fire(%d -> %d) err = %v, wantErr %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			var fsmErr *Error
+			if (err != nil) && (!errors.As(err, &fsmErr) || (fsmErr.Guard != tt.guard)) {
+				t.Errorf("Fire(%d -> %d) err = %#v, guard %v", tt.from, tt.to, err, tt.guard)
+			}
+			if o.status != tt.wantTo {
+				t.Errorf("status = %d, want %d", o.status, tt.wantTo)
+			}
+			if len(o.events) != tt.events {
+				t.Errorf("events = %v, want %d", o.events, tt.events)
+			}
+			if (tt.from != tt.to) && (m.Can(&obj{status: tt.from, allowed: tt.allowed}, tt.to) != (err == nil)) {
+				t.Errorf("Can(%d -> %d) 和 Fire 不一致", tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestFireEvent(t *testing.T) {
+	m := newTestMachine()
+	tests := []struct {
+		name    string
+		from    int
+		event   string
+		to      int
+		wantTo  int
+		wantErr bool
+	}{
+		{"事件一致", stLocked, "unlock", stActive, stActive, false},
+		{"事件不一致 (锁定->激活 不是activate)", stLocked, "activate", stActive, stLocked, true},
+		{"事件不一致的原地也是错误", stLocked, "unlock", stLocked, stLocked, true},
+		{"没有规则", stActive, "activate", stInit, stActive, true},
+		{"激活", stInit, "activate", stActive, stActive, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &obj{status: tt.from}
+			if got := m.CanEvent(o, tt.event, tt.to); got == tt.wantErr {
+				t.Errorf("CanEvent(%s, %d -> %d) = %v", tt.event, tt.from, tt.to, got)
+			}
+			err := m.FireEvent(o, tt.event, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FireEvent(%s, %d -> %d) err = %v, wantErr %v", tt.event, tt.from, tt.to, err, tt.wantErr)
+			}
+			if o.status != tt.wantTo {
+				t.Errorf("status = %d, want %d", o.status, tt.wantTo)
+			}
+			if (err == nil) && ((len(o.events) != 1) || (o.events[0] != tt.event)) {
+				t.Errorf("events = %v, want [%s]", o.events, tt.event)
+			}
+		})
+	}
+}
+
+func TestRuleDo(t *testing.T) {
+	m := newTestMachine()
+	o := &obj{status: stActive}
+	for i := 0; i < 2; i++ {
+		if err := m.Fire(o, stLocked); err != nil {
+			t.Fatalf("Fire lock: %v", err)
+		}
+	}
+	if o.hooks != 2 {
+		t.Errorf("hooks = %d, want 2 (续期也触发)", o.hooks)
+	}
+}
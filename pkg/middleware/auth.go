@@ -20,15 +20,18 @@ const (
 
 // AuthConfig 认证中间件配置
 type AuthConfig struct {
-	JwtSecret          string                     // JWT密钥
-	EnableTokenCaching bool                       // 是否启用token缓存
-	CacheExpiration    time.Duration              // 缓存过期时间
-	CacheCleanupTime   time.Duration              // 缓存清理时间间隔
-	BlacklistTTL       time.Duration              // 黑名单项过期时间
-	BlacklistCleanup   time.Duration              // 黑名单清理间隔
-	IgnorePaths        []string                   // 忽略认证的路径
-	SkipExpireCheck    bool                       // 是否跳过过期检查(开发环境可用)
-	ErrorResponse      func(*gin.Context, string) // 自定义错误响应
+	JwtSecret          string                                // JWT密钥
+	EnableTokenCaching bool                                  // 是否启用token缓存
+	CacheExpiration    time.Duration                         // 缓存过期时间
+	CacheCleanupTime   time.Duration                         // 缓存清理时间间隔
+	BlacklistTTL       time.Duration                         // 黑名单项过期时间
+	BlacklistCleanup   time.Duration                         // 黑名单清理间隔
+	IgnorePaths        []string                              // 忽略认证的路径
+	Skip               func(*gin.Context) bool               // 忽略认证的请求 (按路由声明的，比路径更细)
+	SkipExpireCheck    bool                                  // 是否跳过过期检查(开发环境可用)
+	ErrorResponse      func(*gin.Context, string)            // 自定义错误响应
+	OnAccess           func(*gin.Context, *auth.TokenClaims) // 认证通过后的访问回调(记录访问，不要阻塞)
+	CheckRoleVersion   func(*auth.TokenClaims) bool          // 检查token的角色版本(false是角色变更过，要刷新token)
 }
 
 // DefaultAuthConfig 返回默认配置
@@ -77,6 +80,23 @@ func BlacklistToken(c *gin.Context) {
 	}
 }
 
+// BlacklistTokens 使多个token失效(加入黑名单)，用于级联登出
+func BlacklistTokens(tokens ...string) {
+	if authBlacklist == nil {
+		return // 没有启用认证中间件
+	}
+	for _, token := range tokens {
+		authBlacklist.Set(token, time.Now(), authConfig.BlacklistTTL)
+		authTokenCache.Delete(token)
+	}
+
+	if gin.Mode() == gin.DebugMode {
+		log.InfoFmt("■ ■ Auth ■ ■ 批量加入黑名单:%d", len(tokens))
+	} else {
+		log.InfoFmtOutput("■ ■ Auth ■ ■ 批量加入黑名单:%d", true, len(tokens))
+	}
+}
+
 // WhiteListToken 移除token黑名单
 func WhiteListToken(c *gin.Context) {
 	token := c.GetHeader(AuthHeaderToken)
@@ -103,6 +123,7 @@ func maskToken(token string) string {
 
 // Auth 认证中间件
 func Auth(config AuthConfig) gin.HandlerFunc {
+	authConfig = config
 	authTokenCache = cache.New(config.CacheExpiration, config.CacheCleanupTime)
 	authBlacklist = cache.New(config.BlacklistTTL, config.BlacklistCleanup)
 
@@ -115,6 +136,10 @@ func Auth(config AuthConfig) gin.HandlerFunc {
 				return
 			}
 		}
+		if (config.Skip != nil) && config.Skip(c) {
+			c.Next()
+			return
+		}
 
 		authStr := c.GetHeader(AuthHeaderToken)
 		// 检查Authorization头是否存在
@@ -154,15 +179,30 @@ func Auth(config AuthConfig) gin.HandlerFunc {
 			return
 		}
 
+		// 角色变更过的token要刷新
+		if (config.CheckRoleVersion != nil) && !config.CheckRoleVersion(claims) {
+			config.ErrorResponse(c, "token_role_stale")
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储在上下文中 (claims里有的)
 		c.Set(AuthKeyToken, tokenStr)
 		c.Set(AuthKeyOwnKind, claims.OwnKind)
 		c.Set(AuthKeyOwnID, claims.OwnID)
 		c.Set(AuthKeyAccountID, claims.AccountID)
 		c.Set(AuthKeyUserID, claims.UserID)
+		c.Set(AuthKeyRoles, claims.Roles)
+		c.Set(AuthKeyScopes, claims.Scopes)
+		c.Set(AuthKeyRoleVer, claims.RoleVer)
 
 		log.DebugFmt("■ ■ Auth ■ ■ 设置进Header: %v", claims)
 
+		// 访问记录
+		if config.OnAccess != nil {
+			config.OnAccess(c, claims)
+		}
+
 		c.Next()
 	}
 }
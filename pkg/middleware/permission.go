@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"katydid-mp-user/pkg/i18n"
+	"katydid-mp-user/pkg/log"
+	"katydid-mp-user/pkg/perm"
+	"net/http"
+)
+
+// PermissionConfig 鉴权中间件配置
+type PermissionConfig struct {
+	IgnorePaths   []string                        // 忽略鉴权的路径 (前缀/正则，同认证)
+	Subjects      func(*gin.Context) []string     // 请求的主体 (默认账号，有一个通过即可)
+	Domain        func(*gin.Context) string       // 请求的域 (默认token拥有者)
+	NoLoginMsg    string                          // 没登录的提示 (i18n id)
+	DeniedMsg     string                          // 没权限的提示 (i18n id)
+	ErrorResponse func(*gin.Context, int, string) // 自定义错误响应
+}
+
+// DefaultPermissionConfig 返回默认配置
+func DefaultPermissionConfig(ignorePaths []string) PermissionConfig {
+	return PermissionConfig{
+		IgnorePaths:   append(ignorePaths, "/health", "/metrics"),
+		Subjects:      PermissionSubjects,
+		Domain:        PermissionDomain,
+		NoLoginMsg:    "no_login",
+		DeniedMsg:     "forbidden",
+		ErrorResponse: defPermissionErrorResponse,
+	}
+}
+
+// 默认错误响应处理 (按请求语言本地化)
+func defPermissionErrorResponse(c *gin.Context, code int, msg string) {
+	lang := c.GetString(LanguageKey)
+	if len(lang) <= 0 {
+		lang = i18n.DefLang()
+	}
+	ResponseData(c, code, gin.H{"code": code, "msg": i18n.LocalizeTry(lang, msg, nil)})
+}
+
+// PermissionSubjects 请求的主体 (认证中间件设置的账号，和token里的角色)
+func PermissionSubjects(c *gin.Context) []string {
+	accountID := c.GetUint64(AuthKeyAccountID)
+	if accountID <= 0 {
+		return nil
+	}
+	roles := c.GetStringSlice(AuthKeyRoles)
+	subjects := make([]string, 0, len(roles)+1)
+	subjects = append(subjects, perm.SubAccount(accountID))
+	for _, role := range roles {
+		subjects = append(subjects, perm.SubRole(role))
+	}
+	return subjects
+}
+
+// PermissionDomain 请求的域 (认证中间件设置的token拥有者)
+func PermissionDomain(c *gin.Context) string {
+	ownKind, _ := c.Value(AuthKeyOwnKind).(int16)
+	return perm.Domain(int(ownKind), c.GetUint64(AuthKeyOwnID))
+}
+
+// RegisterPermissionRoutes 注册路由资源 (路由都注册完之后调用)，basePath是资源名要去掉的前缀
+func RegisterPermissionRoutes(routes gin.RoutesInfo, basePath string) {
+	skips := 0
+	for _, route := range routes {
+		if perm.RegisterRoute(route.Method, route.Path, basePath).Skip {
+			skips++
+		}
+	}
+	log.Info("■ ■ Permission ■ ■ 注册路由资源", log.FInt("routes", len(routes)), log.FInt("skips", skips))
+}
+
+// Permission 鉴权中间件 (按路由的资源/动作，在域里验证主体的权限)，要在认证中间件之后
+func Permission(config PermissionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 检查是否为忽略路径
+		path := c.Request.URL.Path
+		for _, ignorePath := range config.IgnorePaths {
+			if authMatchRegex(path, ignorePath) {
+				c.Next()
+				return
+			}
+		}
+
+		// 没注册的(404)交给路由，声明不鉴权的跳过
+		resource := perm.GetRoute(c.Request.Method, c.FullPath())
+		if (resource == nil) || resource.Skip {
+			c.Next()
+			return
+		}
+
+		subjects := config.Subjects(c)
+		if len(subjects) <= 0 {
+			config.ErrorResponse(c, http.StatusUnauthorized, config.NoLoginMsg)
+			c.Abort()
+			return
+		}
+
+		dom := config.Domain(c)
+		for _, sub := range subjects {
+			ok, err := perm.Enforcer(perm.NewPolicyInDomain(sub, dom, resource.Obj, resource.Act))
+			if err != nil {
+				log.Error("■ ■ Permission ■ ■ 鉴权失败", log.FString("sub", sub), log.FString("dom", dom), log.FError(err))
+				break
+			} else if ok {
+				c.Next()
+				return
+			}
+		}
+
+		log.DebugFmt("■ ■ Permission ■ ■ 拒绝: %v %s %s %s", subjects, dom, resource.Obj, resource.Act)
+		config.ErrorResponse(c, http.StatusForbidden, config.DeniedMsg)
+		c.Abort()
+	}
+}
@@ -10,12 +10,23 @@ const (
 	XRequestIDHeader   = "X-Request-ID"   // 网关生成并塞入的requestID
 	XRequestPathHeader = "X-Request-Path" // 上层api塞入
 
+	XDeviceIDHeader   = "X-Device-Id"   // 设备标识
+	XDeviceNameHeader = "X-Device-Name" // 设备名称+型号
+	XMarketHeader     = "X-Market"      // 渠道
+	XPlatformHeader   = "X-Platform"    // 平台
+	XOsVersionHeader  = "X-Os-Version"  // 系统版本
+	XAppVersionHeader = "X-App-Version" // 软件versionCode
+	XLocationHeader   = "X-Location"    // 定位 "lat,lng"
+
 	LanguageKey      = "Use-Language"
 	AuthKeyToken     = "token"
 	AuthKeyOwnKind   = "ownKind"
 	AuthKeyOwnID     = "ownId"
 	AuthKeyUserID    = "userId"
 	AuthKeyAccountID = "accountId"
+	AuthKeyRoles     = "roles"
+	AuthKeyScopes    = "scopes"
+	AuthKeyRoleVer   = "roleVer"
 )
 
 // IsContentTypeOk 检查请求的Content-Type是否符合预期
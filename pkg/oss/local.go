@@ -0,0 +1,101 @@
+package oss
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type (
+	// Local 本地文件存储，访问链接由服务自己校验签名后返回文件
+	Local struct {
+		dir     string // 根目录
+		baseURL string // 下载接口地址 (eg: /api/v1/auth/avatar/file)
+		secret  []byte // 签名密钥
+	}
+)
+
+// NewLocal 没有密钥时随机生成 (重启后链接失效)
+func NewLocal(dir, baseURL, secretKey string) *Local {
+	secret := []byte(secretKey)
+	if len(secret) <= 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &Local{dir: dir, baseURL: baseURL, secret: secret}
+}
+
+func (l *Local) Put(_ context.Context, key string, body []byte, _ string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，读的时候不会读到一半
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); (err != nil) && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignURL(key string, expires time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expireAt := time.Now().Add(expires).UnixMilli()
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("expires", strconv.FormatInt(expireAt, 10))
+	query.Set("sign", l.sign(key, expireAt))
+	return l.baseURL + "?" + query.Encode(), nil
+}
+
+// Verify 校验访问链接，返回文件路径
+func (l *Local) Verify(key string, expireAt int64, sign string) (string, bool) {
+	key, err := CleanKey(key)
+	if err != nil || (expireAt <= time.Now().UnixMilli()) {
+		return "", false
+	} else if !hmac.Equal([]byte(l.sign(key, expireAt)), []byte(sign)) {
+		return "", false
+	}
+	path, err := l.Path(key)
+	return path, err == nil
+}
+
+// Path key对应的本地路径
+func (l *Local) Path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) sign(key string, expireAt int64) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(fmt.Sprintf("%s:%d", key, expireAt)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+type (
+	// Store 对象存储 (本地文件/S3兼容)
+	Store interface {
+		Put(ctx context.Context, key string, body []byte, contentType string) error // 上传 (覆盖)
+		Delete(ctx context.Context, key string) error                               // 删除 (不存在不报错)
+		SignURL(key string, expires time.Duration) (string, error)                  // 带签名的访问链接
+	}
+)
+
+var ErrKeyInvalid = errors.New("oss key invalid")
+
+// CleanKey 规范化key (去掉开头的/，不能跳出根目录)
+func CleanKey(key string) (string, error) {
+	if strings.Contains(key, "\\") {
+		return "", ErrKeyInvalid
+	}
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if (len(key) <= 0) || strings.HasPrefix(key, "..") {
+		return "", ErrKeyInvalid
+	}
+	return key, nil
+}
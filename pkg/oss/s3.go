@@ -0,0 +1,284 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3Service         = "s3"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+	s3MaxExpires      = 7 * 24 * time.Hour // 预签名最长7d
+)
+
+type (
+	// S3 S3兼容存储 (AWS/MinIO/R2/OSS...)，请求用SigV4签名
+	S3 struct {
+		endpoint  *url.URL // eg: https://s3.us-east-1.amazonaws.com
+		region    string
+		bucket    string
+		accessKey string
+		secretKey string
+		pathStyle bool // true: endpoint/bucket/key  false: bucket.endpoint/key
+
+		client *http.Client
+	}
+
+	// S3Options S3配置
+	S3Options struct {
+		Endpoint  string
+		Region    string
+		Bucket    string
+		AccessKey string
+		SecretKey string
+		PathStyle bool
+		Timeout   time.Duration
+	}
+)
+
+func NewS3(options S3Options) (*S3, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil {
+		return nil, err
+	} else if (len(endpoint.Scheme) <= 0) || (len(endpoint.Host) <= 0) {
+		return nil, fmt.Errorf("s3 endpoint invalid: %s", options.Endpoint)
+	} else if len(options.Bucket) <= 0 {
+		return nil, fmt.Errorf("s3 bucket is empty")
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	region := options.Region
+	if len(region) <= 0 {
+		region = "us-east-1"
+	}
+	return &S3{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    options.Bucket,
+		accessKey: options.AccessKey,
+		secretKey: options.SecretKey,
+		pathStyle: options.PathStyle,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.signHeader(req, body, time.Now())
+	return s.do(req)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	s.signHeader(req, nil, time.Now())
+	return s.do(req)
+}
+
+// SignURL 预签名GET链接 (query里带签名)
+func (s *S3) SignURL(key string, expires time.Duration) (string, error) {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	if expires > s3MaxExpires {
+		expires = s3MaxExpires
+	} else if expires < time.Second {
+		expires = time.Second
+	}
+	now := time.Now().UTC()
+	query := target.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	target.RawQuery = s3EncodeQuery(query)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		s3EncodePath(target.Path),
+		target.RawQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+	signature := s.signature(now, canonical)
+	target.RawQuery += "&X-Amz-Signature=" + signature
+	return target.String(), nil
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	target := *s.endpoint
+	if s.pathStyle {
+		target.Path = strings.TrimSuffix(target.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		target.Host = s.bucket + "." + target.Host
+		target.Path = strings.TrimSuffix(target.Path, "/") + "/" + key
+	}
+	target.RawPath = s3EncodePath(target.Path) // 发出去的路径和签名的一致
+	return &target, nil
+}
+
+// signHeader 请求头签名 (Authorization)
+func (s *S3) signHeader(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	payloadHash := s3Hash(body)
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// 参与签名的头 (小写排序)
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-date":           req.Header.Get("X-Amz-Date"),
+		"x-amz-content-sha256": payloadHash,
+	}
+	if contentType := req.Header.Get("Content-Type"); len(contentType) > 0 {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s3EncodePath(req.URL.Path),
+		s3EncodeQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := s.signature(now, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, s.scope(now), signedHeaders, signature))
+}
+
+func (s *S3) signature(now time.Time, canonical string) string {
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		s.scope(now),
+		s3Hash([]byte(canonical)),
+	}, "\n")
+	key := s3HMAC([]byte("AWS4"+s.secretKey), now.Format(s3DateFormat))
+	key = s3HMAC(key, s.region)
+	key = s3HMAC(key, s3Service)
+	key = s3HMAC(key, "aws4_request")
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.Format(s3DateFormat) + "/" + s.region + "/" + s3Service + "/aws4_request"
+}
+
+func (s *S3) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if (resp.StatusCode >= 200) && (resp.StatusCode < 300) {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: %d %s", req.Method, req.URL.Path, resp.StatusCode, string(msg))
+}
+
+func s3Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EncodePath 路径编码 (保留/)
+func s3EncodePath(path string) string {
+	if len(path) <= 0 {
+		return "/"
+	}
+	return s3Encode(path, true)
+}
+
+// s3EncodeQuery query编码 (key排序)
+func s3EncodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Encode(key, false)+"="+s3Encode(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Encode SigV4的URI编码 (只保留 A-Za-z0-9-_.~)
+func s3Encode(s string, keepSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9'),
+			c == '-', c == '_', c == '.', c == '~':
+			builder.WriteByte(c)
+		case (c == '/') && keepSlash:
+			builder.WriteByte(c)
+		default:
+			builder.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return builder.String()
+}
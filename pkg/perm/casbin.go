@@ -1,157 +1,88 @@
 package perm
 
 import (
-	"fmt"
-	"log/slog"
-	"net/http"
-	"strings"
+	"errors"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"katydid-mp-user/pkg/log"
 )
 
 var (
-	enforcer *casbin.Enforcer
-)
+	enforcer *casbin.SyncedEnforcer
 
-type (
-	Policy struct {
-		Sub string `json:"sub"` // 主体
-		Obj string `json:"obj"` // 资源
-		Act string `json:"act"` // 动作
-		Lv  int    `json:"lv"`  // 等级
-	}
+	ErrNotInit = errors.New("casbin enforcer not init")
 )
 
-// Init initializes the Casbin enforcer with an in-memory model.
-// In real projects, you can load the model and policy from files or database.
-func Init() {
-	m, err := model.NewModelFromString(`
+// modelText 带域的RBAC模型
+// 域是OwnKind:OwnID (DomainAll的策略所有域生效)，g(账号/角色, 角色, 域) 可以多级继承，
+// 资源按keyMatch2匹配 (路径参数是*)，动作*匹配所有
+const modelText = `
 [request_definition]
-r = sub, obj, act, lv
+r = sub, dom, obj, act
 
 [policy_definition]
-p = sub, obj, act, lv
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
 
 [policy_effect]
 e = some(where (p.eft == allow))
 
 [matchers]
-m = r.sub == p.sub && r.obj == p.obj && r.act == p.act && r.lv >= p.lv
-`)
+m = g(r.sub, p.sub, r.dom) && (r.dom == p.dom || p.dom == "*") && keyMatch2(r.obj, p.obj) && (r.act == p.act || p.act == "*")
+`
+
+// Init 初始化鉴权，策略从adapter加载 (nil只在内存)，
+// watcher不为nil时，策略变更会通知其他节点重新加载
+func Init(adapter persist.Adapter, watcher persist.Watcher) (*casbin.SyncedEnforcer, error) {
+	m, err := model.NewModelFromString(modelText)
 	if err != nil {
-		panic(fmt.Sprintf("■ ■ Casbin ■ ■ failed to create m: %v", err))
+		return nil, err
 	}
-
-	enforcer, err = casbin.NewEnforcer(m)
+	params := []interface{}{m}
+	if adapter != nil {
+		params = append(params, adapter)
+	}
+	e, err := casbin.NewSyncedEnforcer(params...)
 	if err != nil {
-		panic(fmt.Sprintf("■ ■ Casbin ■ ■ failed to create enforcer: %v", err))
+		return nil, err
 	}
-
-	policies := []*Policy{
-		{"alice", "/data1", "GET", 2},
-		{"bob", "/data2", "POST", 1},
-		// Add more policies as needed
+	if watcher != nil {
+		if err = e.SetWatcher(watcher); err != nil {
+			return nil, err
+		}
 	}
-
-	// 注册策略
-	Register(policies)
+	enforcer = e
+	log.Info("■ ■ Casbin ■ ■ 初始化完成", log.FBool("watcher", watcher != nil))
+	return e, nil
 }
 
-func Get() *casbin.Enforcer {
+func Get() *casbin.SyncedEnforcer {
 	return enforcer
 }
 
-//func NewPolicy(role, path, method string) *Policy {
-//
-//}
-
+// Enforcer 验证策略 (sub在dom里对obj有没有act的权限)
 func Enforcer(policy *Policy) (bool, error) {
-	ok, err := Get().Enforce(policy.Sub, policy.Obj, policy.Act)
-	// TODO:GG 还需要其他处理吗?
-	return ok, err
-}
-
-func Register(policies []*Policy) {
-	for _, p := range policies {
-		if ok, err := enforcer.AddPolicy(p.Sub, p.Obj, p.Act); err != nil || !ok {
-			slog.Error("■ ■ Casbin ■ ■ AddPolicy Failed", slog.Any("err", err))
-		}
+	e := Get()
+	if e == nil {
+		return false, ErrNotInit
 	}
+	return e.Enforce(policy.Sub, policy.Dom, policy.Obj, policy.Act)
 }
 
-// ActionMap 定义 HTTP 方法到 Casbin 动作的映射
-var ActionMap = map[string]string{
-	http.MethodGet:     "read",
-	http.MethodPost:    "create",
-	http.MethodPut:     "update",
-	http.MethodDelete:  "delete",
-	http.MethodPatch:   "update",
-	http.MethodHead:    "read",
-	http.MethodOptions: "read",
-}
-
-// ResourceRule 用于转换 API 路径到资源规则
-type ResourceRule struct {
-	Path       string            // API 路径
-	Method     string            // HTTP 方法
-	Resource   string            // 资源名称
-	Action     string            // 操作类型
-	ExtraRules map[string]string // 额外的规则映射
-}
-
-// NewResourceRule 创建资源规则转换器
-func NewResourceRule(path, method string) *ResourceRule {
-	return &ResourceRule{
-		Path:       path,
-		Method:     method,
-		ExtraRules: make(map[string]string),
-	}
-}
-
-// Convert 转换 HTTP 请求到 Casbin 规则
-func (r *ResourceRule) Convert() *Policy {
-	// 解析资源名称
-	resource := r.parseResource()
-	// 获取动作名称
-	action := r.parseAction()
-
-	return &Policy{
-		Obj: resource,
-		Act: action,
+// Register 添加策略 (已有的跳过)
+func Register(policies []*Policy) {
+	e := Get()
+	if e == nil {
+		log.Error("■ ■ Casbin ■ ■ AddPolicy Failed", log.FError(ErrNotInit))
+		return
 	}
-}
-
-// parseResource 解析API路径为资源名称
-func (r *ResourceRule) parseResource() string {
-	// 移除路径参数中的具体值，保留结构
-	parts := strings.Split(r.Path, "/")
-	var result []string
-
-	for _, part := range parts {
-		if strings.HasPrefix(part, ":") {
-			// 将路径参数替换为通配符
-			result = append(result, "*")
-		} else if part != "" {
-			result = append(result, part)
+	for _, p := range policies {
+		if _, err := e.AddPolicy(p.Rule()); err != nil {
+			log.Error("■ ■ Casbin ■ ■ AddPolicy Failed", log.FString("policy", p.String()), log.FError(err))
 		}
 	}
-
-	return strings.Join(result, "/")
-}
-
-// parseAction 解析HTTP方法为Casbin动作
-func (r *ResourceRule) parseAction() string {
-	if action, exists := ActionMap[r.Method]; exists {
-		return action
-	}
-	return "unknown"
-}
-
-// WithExtraRules 添加额外的规则映射
-func (r *ResourceRule) WithExtraRules(rules map[string]string) *ResourceRule {
-	for k, v := range rules {
-		r.ExtraRules[k] = v
-	}
-	return r
 }
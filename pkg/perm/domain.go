@@ -0,0 +1,72 @@
+package perm
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	DomainAll      = "*"   // 所有域 (只能用在策略上，做角色模板)
+	DomainPlatform = "0:0" // 平台域 (保留的own，不能注册，平台管理员的账号在这里)，只有这个域可以跨域管理
+
+	domainSep  = ":"
+	subAccount = "account" + domainSep
+	subRole    = "role" + domainSep
+)
+
+// Domain 域 (OwnKind:OwnID)
+func Domain(ownKind int, ownID uint64) string {
+	return strconv.Itoa(ownKind) + domainSep + strconv.FormatUint(ownID, 10)
+}
+
+// IsPlatform 是不是平台域
+func IsPlatform(dom string) bool {
+	return dom == DomainPlatform
+}
+
+// ParseDomain 解析域，DomainAll和格式不对的返回false
+func ParseDomain(dom string) (int, uint64, bool) {
+	kind, id, ok := strings.Cut(dom, domainSep)
+	if !ok {
+		return 0, 0, false
+	}
+	ownKind, err := strconv.Atoi(kind)
+	if err != nil {
+		return 0, 0, false
+	}
+	ownID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ownKind, ownID, true
+}
+
+// SubAccount 账号主体
+func SubAccount(accountID uint64) string {
+	return subAccount + strconv.FormatUint(accountID, 10)
+}
+
+// SubRole 角色主体
+func SubRole(role string) string {
+	return subRole + role
+}
+
+// IsSubRole 是否角色主体
+func IsSubRole(sub string) bool {
+	return strings.HasPrefix(sub, subRole) && (len(sub) > len(subRole))
+}
+
+// ParseSubAccount 解析账号主体，不是账号的返回false
+func ParseSubAccount(sub string) (uint64, bool) {
+	id, ok := strings.CutPrefix(sub, subAccount)
+	if !ok {
+		return 0, false
+	}
+	accountID, err := strconv.ParseUint(id, 10, 64)
+	return accountID, err == nil
+}
+
+// RoleName 角色主体的角色名 (去掉前缀)
+func RoleName(sub string) string {
+	return strings.TrimPrefix(sub, subRole)
+}
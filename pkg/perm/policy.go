@@ -2,6 +2,7 @@ package perm
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
@@ -9,15 +10,28 @@ const (
 	PolicyActDel = "del"
 	PolicyActMod = "mod"
 	PolicyActGet = "get"
+	PolicyActAll = "*" // 所有动作
+
+	PolicyObjData    = "data" // 数据范围资源的第一段 (data/模块/资源:all/own)
+	PolicyActDataAll = "all"  // 所有数据 (只有平台域可以有)
 )
 
-// Policy 权限策略定义
-type Policy struct {
-	Sub string `json:"sub"` // 主体
-	Obj string `json:"obj"` // 资源
-	Act string `json:"act"` // 动作
-	//Lv  int    `json:"lv"`  // 等级
-}
+type (
+	// Policy 权限策略定义
+	Policy struct {
+		Sub string `json:"sub"` // 主体 (账号/角色)
+		Dom string `json:"dom"` // 域
+		Obj string `json:"obj"` // 资源
+		Act string `json:"act"` // 动作
+	}
+
+	// Grouping 角色继承定义 (sub在dom里拥有role的所有权限，sub可以是账号或角色)
+	Grouping struct {
+		Sub  string `json:"sub"`  // 账号/角色
+		Role string `json:"role"` // 继承的角色
+		Dom  string `json:"dom"`  // 域
+	}
+)
 
 // NewPolicy 创建一个新的空策略
 func NewPolicy() *Policy {
@@ -33,6 +47,16 @@ func NewPolicyFromTriple(sub, obj, act string) *Policy {
 	}
 }
 
+// NewPolicyInDomain 从四元组构建策略
+func NewPolicyInDomain(sub, dom, obj, act string) *Policy {
+	return &Policy{
+		Sub: sub,
+		Dom: dom,
+		Obj: obj,
+		Act: act,
+	}
+}
+
 // NewPolicyFromRequest 从HTTP请求信息构建策略
 func NewPolicyFromRequest(subject, path, method string) *Policy {
 	rule := NewRequestRule(path, method)
@@ -41,12 +65,27 @@ func NewPolicyFromRequest(subject, path, method string) *Policy {
 	return policy
 }
 
+// IsDataAll 策略能不能匹配到数据范围的all (资源第一段是data或者通配，动作是all或*)
+func (p *Policy) IsDataAll() bool {
+	if (p.Act != PolicyActDataAll) && (p.Act != PolicyActAll) {
+		return false
+	}
+	seg, _, _ := strings.Cut(p.Obj, "/")
+	return (seg == PolicyObjData) || (seg == "*") || strings.HasPrefix(seg, ":")
+}
+
 // WithSubject 设置策略主体
 func (p *Policy) WithSubject(sub string) *Policy {
 	p.Sub = sub
 	return p
 }
 
+// WithDomain 设置策略域
+func (p *Policy) WithDomain(dom string) *Policy {
+	p.Dom = dom
+	return p
+}
+
 // WithObject 设置策略资源
 func (p *Policy) WithObject(obj string) *Policy {
 	p.Obj = obj
@@ -69,18 +108,24 @@ func (p *Policy) Build() (*Policy, error) {
 
 // IsValid 检查策略是否有效
 func (p *Policy) IsValid() bool {
-	return p.Sub != "" && p.Obj != "" && p.Act != ""
+	return p.Sub != "" && p.Dom != "" && p.Obj != "" && p.Act != ""
+}
+
+// Rule 策略规则 (和模型的p定义顺序一致)
+func (p *Policy) Rule() []string {
+	return []string{p.Sub, p.Dom, p.Obj, p.Act}
 }
 
 // String 返回策略的字符串表示
 func (p *Policy) String() string {
-	return fmt.Sprintf("%s, %s, %s", p.Sub, p.Obj, p.Act)
+	return fmt.Sprintf("%s, %s, %s, %s", p.Sub, p.Dom, p.Obj, p.Act)
 }
 
 // Clone 复制策略
 func (p *Policy) Clone() *Policy {
 	return &Policy{
 		Sub: p.Sub,
+		Dom: p.Dom,
 		Obj: p.Obj,
 		Act: p.Act,
 	}
@@ -90,3 +135,27 @@ func (p *Policy) Clone() *Policy {
 func (p *Policy) Check() (bool, error) {
 	return Enforcer(p)
 }
+
+// NewGrouping 构建角色继承
+func NewGrouping(sub, role, dom string) *Grouping {
+	return &Grouping{
+		Sub:  sub,
+		Role: role,
+		Dom:  dom,
+	}
+}
+
+// IsValid 检查角色继承是否有效 (不能继承自己)
+func (g *Grouping) IsValid() bool {
+	return g.Sub != "" && g.Role != "" && g.Dom != "" && g.Sub != g.Role
+}
+
+// Rule 角色继承规则 (和模型的g定义顺序一致)
+func (g *Grouping) Rule() []string {
+	return []string{g.Sub, g.Role, g.Dom}
+}
+
+// String 返回角色继承的字符串表示
+func (g *Grouping) String() string {
+	return fmt.Sprintf("%s, %s, %s", g.Sub, g.Role, g.Dom)
+}
@@ -0,0 +1,94 @@
+package perm
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	routes     = make(map[string]*Resource) // 路由资源 [method path]
+	routeSkips []string                     // 不鉴权的路由组
+	routesMu   sync.RWMutex
+)
+
+type (
+	// Resource 路由资源 (鉴权中间件按路由查)
+	Resource struct {
+		Method string `json:"method"` // HTTP 方法
+		Path   string `json:"path"`   // 路由路径 (gin的FullPath)
+		Obj    string `json:"obj"`    // 资源
+		Act    string `json:"act"`    // 动作
+		Skip   bool   `json:"skip"`   // 不鉴权 (不用登录的，或服务里自己鉴权的)
+	}
+)
+
+// Declare 声明路由需要的权限 (覆盖按路径推导的资源/动作)，要在RegisterRoute之前
+func Declare(method, path, obj, act string) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes[routeKey(method, path)] = &Resource{Method: method, Path: path, Obj: obj, Act: act}
+}
+
+// DeclareSkip 声明路由不鉴权，要在RegisterRoute之前
+func DeclareSkip(method, path string) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes[routeKey(method, path)] = &Resource{Method: method, Path: path, Skip: true}
+}
+
+// DeclareSkipGroup 声明路由组下的路由都不鉴权 (服务里自己鉴权的)，要在RegisterRoute之前
+func DeclareSkipGroup(groupPath string) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routeSkips = append(routeSkips, strings.TrimSuffix(groupPath, "/"))
+}
+
+// RegisterRoute 注册路由资源，声明过的不变，没声明的按路径推导 (去掉basePath前缀)
+func RegisterRoute(method, path, basePath string) *Resource {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	key := routeKey(method, path)
+	if exist, ok := routes[key]; ok {
+		return exist
+	}
+	for _, group := range routeSkips {
+		if (path == group) || strings.HasPrefix(path, group+"/") {
+			resource := &Resource{Method: method, Path: path, Skip: true}
+			routes[key] = resource
+			return resource
+		}
+	}
+	policy := NewRequestRule(strings.TrimPrefix(path, basePath), method).Convert()
+	resource := &Resource{Method: method, Path: path, Obj: policy.Obj, Act: policy.Act}
+	routes[key] = resource
+	return resource
+}
+
+// GetRoute 路由资源，没注册的返回nil
+func GetRoute(method, path string) *Resource {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+	return routes[routeKey(method, path)]
+}
+
+// Resources 所有路由资源 (按路径排序，配置策略用)
+func Resources() []*Resource {
+	routesMu.RLock()
+	list := make([]*Resource, 0, len(routes))
+	for _, resource := range routes {
+		list = append(list, resource)
+	}
+	routesMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	return list
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
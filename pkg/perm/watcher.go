@@ -0,0 +1,135 @@
+package perm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"katydid-mp-user/pkg/log"
+)
+
+const (
+	WatcherChannelDef = "casbin:policy" // 默认通知频道
+
+	watcherTimeout = 3 * time.Second
+)
+
+type (
+	// RedisWatcher 策略变更通知 (redis发布订阅)，
+	// 一个节点改了策略，其他节点收到后重新加载，自己发的忽略
+	// 其他的变更通知 (例如账号角色版本) 用子频道 channel:topic
+	RedisWatcher struct {
+		client  redis.UniversalClient
+		channel string
+		node    string // 节点标识 (区分自己发的)
+
+		pubsub   *redis.PubSub
+		callback func(string)
+		topics   map[string]func(string) // [子频道]回调
+		mu       sync.RWMutex
+		once     sync.Once
+	}
+)
+
+func NewRedisWatcher(client redis.UniversalClient, channel string) (*RedisWatcher, error) {
+	if len(channel) <= 0 {
+		channel = WatcherChannelDef
+	}
+	w := &RedisWatcher{
+		client:  client,
+		channel: channel,
+		node:    uuid.NewString(),
+		topics:  make(map[string]func(string)),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), watcherTimeout)
+	defer cancel()
+	w.pubsub = client.Subscribe(ctx, channel)
+	if _, err := w.pubsub.Receive(ctx); err != nil { // 等订阅成功
+		_ = w.pubsub.Close()
+		return nil, err
+	}
+	go w.listen()
+	return w, nil
+}
+
+// SetUpdateCallback 设置收到通知后的回调 (enforcer.SetWatcher时设置为重新加载策略)
+func (w *RedisWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 通知其他节点策略变更 (enforcer修改策略后自动调用)
+func (w *RedisWatcher) Update() error {
+	ctx, cancel := context.WithTimeout(context.Background(), watcherTimeout)
+	defer cancel()
+	return w.client.Publish(ctx, w.channel, w.node).Err()
+}
+
+// Publish 通知其他节点topic的变更 (消息前面带上节点标识)
+func (w *RedisWatcher) Publish(topic, payload string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), watcherTimeout)
+	defer cancel()
+	return w.client.Publish(ctx, w.topicChannel(topic), w.node+"|"+payload).Err()
+}
+
+// Subscribe 订阅其他节点topic的变更 (自己发的忽略)
+func (w *RedisWatcher) Subscribe(topic string, callback func(payload string)) error {
+	channel := w.topicChannel(topic)
+	w.mu.Lock()
+	w.topics[channel] = callback
+	w.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), watcherTimeout)
+	defer cancel()
+	return w.pubsub.Subscribe(ctx, channel)
+}
+
+// Close 停止订阅
+func (w *RedisWatcher) Close() {
+	w.once.Do(func() {
+		if err := w.pubsub.Close(); err != nil {
+			log.Warn("■ ■ Casbin ■ ■ 关闭订阅失败", log.FError(err))
+		}
+	})
+}
+
+func (w *RedisWatcher) listen() {
+	for message := range w.pubsub.Channel() {
+		if message.Channel != w.channel {
+			w.onTopic(message.Channel, message.Payload)
+			continue
+		} else if message.Payload == w.node {
+			continue
+		}
+		w.mu.RLock()
+		callback := w.callback
+		w.mu.RUnlock()
+		if callback == nil {
+			continue
+		}
+		log.Info("■ ■ Casbin ■ ■ 策略变更，重新加载", log.FString("node", message.Payload))
+		callback(message.Payload)
+	}
+}
+
+// onTopic 子频道的通知，自己发的忽略
+func (w *RedisWatcher) onTopic(channel, message string) {
+	node, payload, ok := strings.Cut(message, "|")
+	if !ok || (node == w.node) {
+		return
+	}
+	w.mu.RLock()
+	callback := w.topics[channel]
+	w.mu.RUnlock()
+	if callback != nil {
+		callback(payload)
+	}
+}
+
+func (w *RedisWatcher) topicChannel(topic string) string {
+	return w.channel + ":" + topic
+}
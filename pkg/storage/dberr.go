@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DBErrKind 数据库错误类型 (驱动无关)
+type DBErrKind int8
+
+const (
+	DBErrUnknown     DBErrKind = iota // 未知 (按原来的匹配)
+	DBErrUnique                       // 唯一约束冲突
+	DBErrForeignNone                  // 外键指向的数据不存在 (写入)
+	DBErrForeignUsed                  // 还被外键引用 (删除)
+	DBErrTooLong                      // 值太长
+	DBErrNotNull                      // 不能为空
+	DBErrDeadlock                     // 死锁/序列化失败 (可以重试)
+	DBErrTimeout                      // 超时/锁等待超时
+	DBErrVersion                      // 版本冲突 (乐观锁)
+	DBErrNone                         // 没有改到 (不存在/已删除/不在数据范围里)
+)
+
+var dbErrNames = map[DBErrKind]string{
+	DBErrUnknown:     "unknown",
+	DBErrUnique:      "unique",
+	DBErrForeignNone: "foreign_none",
+	DBErrForeignUsed: "foreign_used",
+	DBErrTooLong:     "too_long",
+	DBErrNotNull:     "not_null",
+	DBErrDeadlock:    "deadlock",
+	DBErrTimeout:     "timeout",
+	DBErrVersion:     "version",
+	DBErrNone:        "none",
+}
+
+var dbErrStatuses = map[DBErrKind]int{
+	DBErrUnique:      http.StatusConflict,
+	DBErrForeignNone: http.StatusBadRequest,
+	DBErrForeignUsed: http.StatusConflict,
+	DBErrTooLong:     http.StatusBadRequest,
+	DBErrNotNull:     http.StatusBadRequest,
+	DBErrDeadlock:    http.StatusConflict,
+	DBErrTimeout:     http.StatusServiceUnavailable,
+	DBErrVersion:     http.StatusConflict,
+	DBErrNone:        http.StatusNotFound,
+}
+
+var (
+	pgKeyRegexp     = regexp.MustCompile(`Key \(([^)]+)\)`)                        // Key (name)=(xx) already exists.
+	mysqlKeyRegexp  = regexp.MustCompile(`for key '(?:[^'.]+\.)?([^']+)'`)         // Duplicate entry 'xx' for key 'table.idx'
+	mysqlColRegexp  = regexp.MustCompile(`[Cc]olumn '([^']+)'`)                    // Data too long for column 'name' at row 1
+	sqliteColRegexp = regexp.MustCompile(`constraint failed: (?:[^.\s]+\.)?(\S+)`) // UNIQUE constraint failed: table.name
+)
+
+// String 错误类型名
+func (k DBErrKind) String() string {
+	return dbErrNames[k]
+}
+
+// Status 对应的http状态码 (未知的是0)
+func (k DBErrKind) Status() int {
+	return dbErrStatuses[k]
+}
+
+// DBError 解析后的数据库错误 (保留原始错误)
+type DBError struct {
+	Kind  DBErrKind
+	Field string // 出错的列 (或约束/索引名)，解析不到为空
+	cause error
+}
+
+// NewDBError 仓储自己判断出的数据库错误 (版本冲突/没有改到等，驱动不会报的)
+func NewDBError(kind DBErrKind, field string, cause error) *DBError {
+	return &DBError{Kind: kind, Field: field, cause: cause}
+}
+
+func (e *DBError) Error() string {
+	if len(e.Field) > 0 {
+		return "db_" + e.Kind.String() + " (" + e.Field + "): " + e.cause.Error()
+	}
+	return "db_" + e.Kind.String() + ": " + e.cause.Error()
+}
+
+func (e *DBError) Unwrap() error {
+	return e.cause
+}
+
+// Status 对应的http状态码
+func (e *DBError) Status() int {
+	return e.Kind.Status()
+}
+
+// ParseDBErr 解析驱动错误 (pgx/MySQL/SQLite)，不认识的返回nil
+func ParseDBErr(err error) *DBError {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return dbErr
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return parsePgErr(pgErr)
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return parseMysqlErr(myErr)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &DBError{Kind: DBErrTimeout, cause: err}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &DBError{Kind: DBErrUnique, cause: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &DBError{Kind: DBErrForeignNone, cause: err}
+	}
+	return parseSqliteErr(err)
+}
+
+func parsePgErr(err *pgconn.PgError) *DBError {
+	field := err.ColumnName
+	if len(field) <= 0 {
+		if match := pgKeyRegexp.FindStringSubmatch(err.Detail); len(match) > 1 {
+			field = match[1]
+		} else {
+			field = err.ConstraintName
+		}
+	}
+	var kind DBErrKind
+	switch err.Code {
+	case "23505": // unique_violation
+		kind = DBErrUnique
+	case "23503": // foreign_key_violation
+		kind = DBErrForeignNone
+		if strings.Contains(err.Detail, "still referenced") {
+			kind = DBErrForeignUsed
+		}
+	case "22001": // string_data_right_truncation
+		kind = DBErrTooLong
+	case "23502": // not_null_violation
+		kind = DBErrNotNull
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		kind = DBErrDeadlock
+	case "57014", "55P03": // query_canceled (statement_timeout), lock_not_available
+		kind = DBErrTimeout
+	default:
+		return nil
+	}
+	return &DBError{Kind: kind, Field: field, cause: err}
+}
+
+func parseMysqlErr(err *mysql.MySQLError) *DBError {
+	var kind DBErrKind
+	switch err.Number {
+	case 1062: // ER_DUP_ENTRY
+		kind = DBErrUnique
+	case 1452: // ER_NO_REFERENCED_ROW_2
+		kind = DBErrForeignNone
+	case 1451: // ER_ROW_IS_REFERENCED_2
+		kind = DBErrForeignUsed
+	case 1406: // ER_DATA_TOO_LONG
+		kind = DBErrTooLong
+	case 1048, 1364: // ER_BAD_NULL_ERROR, ER_NO_DEFAULT_FOR_FIELD
+		kind = DBErrNotNull
+	case 1213: // ER_LOCK_DEADLOCK
+		kind = DBErrDeadlock
+	case 1205, 3024: // ER_LOCK_WAIT_TIMEOUT, ER_QUERY_TIMEOUT
+		kind = DBErrTimeout
+	default:
+		return nil
+	}
+	var field string
+	if match := mysqlKeyRegexp.FindStringSubmatch(err.Message); len(match) > 1 {
+		field = match[1]
+	} else if match = mysqlColRegexp.FindStringSubmatch(err.Message); len(match) > 1 {
+		field = match[1]
+	}
+	return &DBError{Kind: kind, Field: field, cause: err}
+}
+
+// parseSqliteErr SQLite按错误信息解析 (不依赖cgo驱动的类型)
+func parseSqliteErr(err error) *DBError {
+	text := err.Error()
+	var kind DBErrKind
+	switch {
+	case strings.Contains(text, "UNIQUE constraint failed"):
+		kind = DBErrUnique
+	case strings.Contains(text, "FOREIGN KEY constraint failed"):
+		kind = DBErrForeignNone
+	case strings.Contains(text, "NOT NULL constraint failed"):
+		kind = DBErrNotNull
+	case strings.Contains(text, "database is locked"), strings.Contains(text, "database table is locked"):
+		kind = DBErrTimeout
+	default:
+		return nil
+	}
+	var field string
+	if match := sqliteColRegexp.FindStringSubmatch(text); len(match) > 1 {
+		field = match[1]
+	}
+	return &DBError{Kind: kind, Field: field, cause: err}
+}
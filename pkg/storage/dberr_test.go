@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+func TestParseDBErr(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		kind  DBErrKind
+		field string
+		nil   bool
+	}{
+		{"pg唯一", &pgconn.PgError{Code: "23505", Detail: "Key (email)=(a@b.c) already exists."}, DBErrUnique, "email", false},
+		{"pg外键不存在", &pgconn.PgError{Code: "23503", ConstraintName: "fk_owner"}, DBErrForeignNone, "fk_owner", false},
+		{"pg外键被引用", &pgconn.PgError{Code: "23503", Detail: `Key (id)=(1) is still referenced from table "x".`}, DBErrForeignUsed, "id", false},
+		{"pg太长", &pgconn.PgError{Code: "22001", ColumnName: "nickname"}, DBErrTooLong, "nickname", false},
+		{"pg序列化失败", &pgconn.PgError{Code: "40001"}, DBErrDeadlock, "", false},
+		{"pg死锁", &pgconn.PgError{Code: "40P01"}, DBErrDeadlock, "", false},
+		{"pg锁超时", &pgconn.PgError{Code: "55P03"}, DBErrTimeout, "", false},
+		{"pg不认识", &pgconn.PgError{Code: "42P01"}, 0, "", true},
+		{"包了一层的pg", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23502", ColumnName: "name"}), DBErrNotNull, "name", false},
+		{"mysql唯一", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'account.idx_name'"}, DBErrUnique, "idx_name", false},
+		{"mysql太长", &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'nickname' at row 1"}, DBErrTooLong, "nickname", false},
+		{"mysql外键被引用", &mysql.MySQLError{Number: 1451}, DBErrForeignUsed, "", false},
+		{"mysql死锁", &mysql.MySQLError{Number: 1213}, DBErrDeadlock, "", false},
+		{"mysql锁超时", &mysql.MySQLError{Number: 1205}, DBErrTimeout, "", false},
+		{"mysql不认识", &mysql.MySQLError{Number: 1146}, 0, "", true},
+		{"sqlite唯一", errors.New("UNIQUE constraint failed: account.name"), DBErrUnique, "name", false},
+		{"sqlite非空", errors.New("NOT NULL constraint failed: account.nickname"), DBErrNotNull, "nickname", false},
+		{"sqlite锁", errors.New("database is locked"), DBErrTimeout, "", false},
+		{"超时", fmt.Errorf("query: %w", context.DeadlineExceeded), DBErrTimeout, "", false},
+		{"gorm唯一", gorm.ErrDuplicatedKey, DBErrUnique, "", false},
+		{"已经解析过", NewDBError(DBErrVersion, "", errors.New("version conflict")), DBErrVersion, "", false},
+		{"普通错误", errors.New("boom"), 0, "", true},
+		{"nil", nil, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDBErr(tt.err)
+			if (got == nil) != tt.nil {
+				t.Fatalf("ParseDBErr(%v) = %v, want nil %v", tt.err, got, tt.nil)
+			} else if got == nil {
+				return
+			}
+			if (got.Kind != tt.kind) || (got.Field != tt.field) {
+				t.Errorf("ParseDBErr(%v) = {%s %q}, want {%s %q}", tt.err, got.Kind, got.Field, tt.kind, tt.field)
+			}
+			if got.Unwrap() == nil {
+				t.Errorf("ParseDBErr(%v) 要保留原始错误", tt.err)
+			}
+		})
+	}
+}
+
+func TestDBErrStatus(t *testing.T) {
+	tests := []struct {
+		kind DBErrKind
+		want int
+	}{
+		{DBErrUnique, http.StatusConflict},
+		{DBErrTooLong, http.StatusBadRequest},
+		{DBErrTimeout, http.StatusServiceUnavailable},
+		{DBErrVersion, http.StatusConflict},
+		{DBErrNone, http.StatusNotFound},
+		{DBErrUnknown, 0},
+	}
+	for _, tt := range tests {
+		if got := NewDBError(tt.kind, "", errors.New("x")).Status(); got != tt.want {
+			t.Errorf("%s.Status() = %d, want %d", tt.kind, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package valid
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	idCardLayout = "20060102" // 身份证里的出生日期格式
+)
+
+var (
+	// 18位身份证 (最后一位可能是X)
+	idCard18Regex = regexp.MustCompile(`^\d{17}[\dX]$`)
+	// 15位旧身份证 (全数字，年份两位，没有校验码)
+	idCard15Regex = regexp.MustCompile(`^\d{15}$`)
+
+	// 中国护照 (普通: E+8位数字 / E+字母+7位数字(不含I/O)，旧版G+8位，外交/公务/因公普通: D/S/P(+E)+7位)
+	passportCNRegex = regexp.MustCompile(`^(?:[EG]\d{8}|E[A-HJ-NP-Z]\d{7}|[DSP]E?\d{7})$`)
+	// 其他国家护照 (ICAO 9303 机读区证件号最长9位，字母数字)
+	passportRegex = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+
+	// 校验码加权因子 (ISO 7064 MOD 11-2)
+	idCardWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	// 校验码 (余数 -> 校验码)
+	idCardCheckCodes = [11]byte{'1', '0', 'X', '9', '8', '7', '6', '5', '4', '3', '2'}
+
+	// 省级行政区划代码 (前两位)
+	idCardProvinces = map[string]string{
+		"11": "北京", "12": "天津", "13": "河北", "14": "山西", "15": "内蒙古",
+		"21": "辽宁", "22": "吉林", "23": "黑龙江",
+		"31": "上海", "32": "江苏", "33": "浙江", "34": "安徽", "35": "福建", "36": "江西", "37": "山东",
+		"41": "河南", "42": "湖北", "43": "湖南", "44": "广东", "45": "广西", "46": "海南",
+		"50": "重庆", "51": "四川", "52": "贵州", "53": "云南", "54": "西藏",
+		"61": "陕西", "62": "甘肃", "63": "青海", "64": "宁夏", "65": "新疆",
+		"71": "台湾", "81": "香港", "82": "澳门", "83": "台湾", // 83是台湾居民居住证
+	}
+)
+
+// IDCardComponents 保存解析后的身份证各部分
+type IDCardComponents struct {
+	Number   string    // 18位号码 (15位的会升级成18位)
+	Region   string    // 行政区划代码 (前6位)
+	Province string    // 省级行政区名称
+	Birthday time.Time // 出生日期
+	Male     bool      // 性别 (顺序码奇数为男)
+}
+
+// IsIDCard 验证中国居民身份证号码 (地区/出生日期/校验码)
+// 支持15位旧号码 (没有校验码，会升级成18位返回)
+// 如果号码无效，但能够解析出组件，则返回组件和false
+func IsIDCard(number string) (*IDCardComponents, bool) {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	switch {
+	case idCard18Regex.MatchString(number):
+	case idCard15Regex.MatchString(number):
+		number = upgradeIDCard15(number)
+	default:
+		return nil, false
+	}
+
+	components := &IDCardComponents{Number: number, Region: number[:6]}
+	province, ok := idCardProvinces[number[:2]]
+	if !ok {
+		return nil, false
+	}
+	components.Province = province
+
+	// 出生日期 (不能是未来，不早于1900)
+	birthday, err := time.Parse(idCardLayout, number[6:14])
+	if err != nil {
+		return nil, false
+	}
+	components.Birthday = birthday
+	components.Male = (number[16]-'0')%2 == 1
+	if (birthday.Year() < 1900) || birthday.After(time.Now()) {
+		return components, false
+	}
+
+	// 校验码
+	if IDCardCheckCode(number[:17]) != number[17] {
+		return components, false
+	}
+	return components, true
+}
+
+// IDCardCheckCode 计算18位身份证的校验码 (前17位)，格式错误返回0
+func IDCardCheckCode(first17 string) byte {
+	if len(first17) != 17 {
+		return 0
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		c := first17[i]
+		if (c < '0') || (c > '9') {
+			return 0
+		}
+		sum += int(c-'0') * idCardWeights[i]
+	}
+	return idCardCheckCodes[sum%11]
+}
+
+// IsPassport 验证护照号码格式 (country是ISO 3166-1 alpha-2，CN按中国护照规则，其他按ICAO通用规则)
+func IsPassport(number string, country string) (string, bool) {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	number = strings.ReplaceAll(number, " ", "")
+	if number == "" {
+		return "", false
+	}
+	if strings.EqualFold(country, "CN") {
+		return number, passportCNRegex.MatchString(number)
+	}
+	return number, passportRegex.MatchString(number)
+}
+
+// upgradeIDCard15 15位升级成18位 (年份补19，补上校验码)
+func upgradeIDCard15(number string) string {
+	first17 := number[:6] + "19" + number[6:]
+	return first17 + string(IDCardCheckCode(first17))
+}
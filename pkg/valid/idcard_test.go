@@ -0,0 +1,64 @@
+package valid
+
+import (
+	"testing"
+)
+
+func TestIDCardCheckCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		first17 string
+		want    byte
+	}{
+		{"X", "11010519491231002", 'X'},
+		{"数字", "44030419900101001", '1'},
+		{"长度不对", "1101051949123100", 0},
+		{"有字母", "1101051949123100A", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IDCardCheckCode(tt.first17); got != tt.want {
+				t.Errorf("IDCardCheckCode(%q) = %q, want %q", tt.first17, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsIDCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		number     string
+		want       bool
+		components bool   // 能解析出组件
+		upgraded   string // 15位升级后的号码
+		male       bool
+	}{
+		{"18位", "11010519491231002X", true, true, "", false},
+		{"小写x和空白", " 11010519491231002x ", true, true, "", false},
+		{"校验码错", "110105194912310021", false, true, "", false},
+		{"省份不存在", "99010519491231002X", false, false, "", false},
+		{"日期不存在", "110105194902300027", false, false, "", false},
+		{"早于1900", "110105189901010011", false, true, "", false},
+		{"15位升级", "110105491231002", true, true, "11010519491231002X", false},
+		{"男", "440304199001010011", true, true, "", true},
+		{"格式错", "1101051949123100", false, false, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			components, ok := IsIDCard(tt.number)
+			if ok != tt.want {
+				t.Fatalf("IsIDCard(%q) ok = %v, want %v", tt.number, ok, tt.want)
+			} else if (components != nil) != tt.components {
+				t.Fatalf("IsIDCard(%q) components = %v, want %v", tt.number, components, tt.components)
+			} else if components == nil {
+				return
+			}
+			if (len(tt.upgraded) > 0) && (components.Number != tt.upgraded) {
+				t.Errorf("Number = %s, want %s", components.Number, tt.upgraded)
+			}
+			if tt.want && (components.Male != tt.male) {
+				t.Errorf("Male = %v, want %v", components.Male, tt.male)
+			}
+		})
+	}
+}
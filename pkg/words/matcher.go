@@ -0,0 +1,169 @@
+package words
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+type (
+	// Matcher 敏感词匹配 (Aho-Corasick自动机)，构建后只读，并发安全
+	Matcher struct {
+		nodes []*node
+	}
+
+	node struct {
+		next   map[rune]int // 子节点
+		fail   int          // 失败指针
+		output []int        // 在这里结束的词长度(rune)
+	}
+
+	// Hit 命中的词
+	Hit struct {
+		Word  string // 命中的词 (归一化后)
+		Start int    // 开始位置 (rune下标，归一化后的文本)
+		End   int    // 结束位置 (不含)
+	}
+)
+
+// NewMatcher 构建匹配器，词会先归一化，空词忽略
+func NewMatcher(words []string) *Matcher {
+	m := &Matcher{nodes: []*node{newNode()}}
+	for _, word := range words {
+		m.insert([]rune(Normalize(word)))
+	}
+	m.build()
+	return m
+}
+
+// LoadMatcher 从文件构建匹配器 (一行一个词，#开头是注释)
+func LoadMatcher(path string, extra ...string) (*Matcher, error) {
+	words := append([]string{}, extra...)
+	if len(path) <= 0 {
+		return NewMatcher(words), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if (len(line) <= 0) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewMatcher(words), nil
+}
+
+func newNode() *node {
+	return &node{next: make(map[rune]int)}
+}
+
+// Size 词典里的节点数
+func (m *Matcher) Size() int {
+	return len(m.nodes)
+}
+
+// Contains 文本(归一化后)是否包含敏感词
+func (m *Matcher) Contains(text string) bool {
+	found := false
+	m.scan([]rune(Normalize(text)), func(Hit) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// Find 找出所有命中的词 (归一化后的文本里)
+func (m *Matcher) Find(text string) []Hit {
+	hits := make([]Hit, 0)
+	m.scan([]rune(Normalize(text)), func(hit Hit) bool {
+		hits = append(hits, hit)
+		return true
+	})
+	return hits
+}
+
+// Replace 把命中的部分替换成mask (返回归一化后的文本)
+func (m *Matcher) Replace(text string, mask rune) string {
+	runes := []rune(Normalize(text))
+	m.scan(runes, func(hit Hit) bool {
+		for i := hit.Start; i < hit.End; i++ {
+			runes[i] = mask
+		}
+		return true
+	})
+	return string(runes)
+}
+
+func (m *Matcher) insert(word []rune) {
+	if len(word) <= 0 {
+		return
+	}
+	cur := 0
+	for _, r := range word {
+		next, ok := m.nodes[cur].next[r]
+		if !ok {
+			m.nodes = append(m.nodes, newNode())
+			next = len(m.nodes) - 1
+			m.nodes[cur].next[r] = next
+		}
+		cur = next
+	}
+	m.nodes[cur].output = append(m.nodes[cur].output, len(word))
+}
+
+// build 广度优先建立失败指针，并合并后缀的输出
+func (m *Matcher) build() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok && (next != child) {
+					m.nodes[child].fail = next
+					break
+				} else if fail == 0 {
+					m.nodes[child].fail = 0
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// scan 匹配，fn返回false时停止
+func (m *Matcher) scan(runes []rune, fn func(Hit) bool) {
+	cur := 0
+	for i, r := range runes {
+		for {
+			if next, ok := m.nodes[cur].next[r]; ok {
+				cur = next
+				break
+			} else if cur == 0 {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		for _, length := range m.nodes[cur].output {
+			start := i + 1 - length
+			if !fn(Hit{Word: string(runes[start : i+1]), Start: start, End: i + 1}) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package words
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatcherFind(t *testing.T) {
+	m := NewMatcher([]string{"he", "she", "his", "hers", "", "管理员"})
+	tests := []struct {
+		name string
+		text string
+		want []Hit
+	}{
+		{"经典ushers", "ushers", []Hit{
+			{Word: "she", Start: 1, End: 4},
+			{Word: "he", Start: 2, End: 4},
+			{Word: "hers", Start: 2, End: 6},
+		}},
+		{"失败指针回退", "ahishers", []Hit{
+			{Word: "his", Start: 1, End: 4},
+			{Word: "she", Start: 3, End: 6},
+			{Word: "he", Start: 4, End: 6},
+			{Word: "hers", Start: 4, End: 8},
+		}},
+		{"中文", "我是管理员", []Hit{{Word: "管理员", Start: 2, End: 5}}},
+		{"归一化 全角大写", "ＳＨＥ", []Hit{
+			{Word: "she", Start: 0, End: 3},
+			{Word: "he", Start: 1, End: 3},
+		}},
+		{"归一化 零宽和空格", "管​理 员", []Hit{{Word: "管理员", Start: 0, End: 3}}},
+		{"没有命中", "abc", []Hit{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherContainsReplace(t *testing.T) {
+	m := NewMatcher([]string{"admin", "官方"})
+	tests := []struct {
+		name     string
+		text     string
+		contains bool
+		replaced string
+	}{
+		{"形近字", "аdmin", true, "*****"},
+		{"部分", "xAdminx官方号", true, "x*****x**号"},
+		{"空格也去掉", "adm in", true, "*****"},
+		{"没有", "Adm1n", false, "adm1n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Contains(tt.text); got != tt.contains {
+				t.Errorf("Contains(%q) = %v, want %v", tt.text, got, tt.contains)
+			}
+			if got := m.Replace(tt.text, '*'); got != tt.replaced {
+				t.Errorf("Replace(%q) = %q, want %q", tt.text, got, tt.replaced)
+			}
+		})
+	}
+}
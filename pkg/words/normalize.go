@@ -0,0 +1,42 @@
+package words
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables 常见的跨文字形近字 (西里尔/希腊 -> 拉丁)，NFKC之后、小写之后再映射
+var confusables = map[rune]rune{
+	// 西里尔
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ɡ': 'g',
+	// 希腊
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// 拉丁扩展
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h',
+}
+
+// Normalize 归一化 (判重/敏感词用)
+// NFKC (全角->半角，兼容字符) -> 小写 -> 形近字 -> 去掉不可见字符和空白
+func Normalize(text string) string {
+	text = norm.NFKC.String(text)
+	var builder strings.Builder
+	builder.Grow(len(text))
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) || unicode.IsControl(r) {
+			continue // 空格/零宽字符/控制字符
+		} else if unicode.Is(unicode.Mn, r) {
+			continue // 组合附加符号 (NFKC后剩下的)
+		}
+		r = unicode.ToLower(r)
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}